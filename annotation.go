@@ -0,0 +1,227 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/pkg/errors"
+)
+
+const (
+	annotationQueueSize        = 100
+	annotationMaxAttempts      = 3
+	defaultAnnotationRetryWait = 2 * time.Second
+)
+
+// AnnotationSpan is an annotation covering a period of time (e.g. a
+// deploy or an incident), see StartAnnotation
+type AnnotationSpan struct {
+	m          *CirconusMetrics
+	annotation api.Annotation
+	cid        string // set by the annotation worker once created
+	stopOnce   sync.Once
+}
+
+// annotationJob is a queued annotation create or span update, or a
+// flush marker (done is closed once the jobs queued before it are sent)
+type annotationJob struct {
+	annotation api.Annotation
+	span       *AnnotationSpan
+	stop       bool
+	done       chan struct{}
+}
+
+// Annotate queues an annotation marking the current time (e.g. a deploy,
+// config reload or leader election). Annotations are sent asynchronously,
+// in order, through the check manager api (an API token is required) and
+// retried on failure. relMetrics are the metrics the annotation relates to.
+func (m *CirconusMetrics) Annotate(category, title, description string, relMetrics ...string) {
+	now := uint(time.Now().Unix())
+	m.queueAnnotation(&annotationJob{annotation: newAnnotation(category, title, description, now, relMetrics)})
+}
+
+// StartAnnotation queues an annotation starting now, it is extended to
+// cover the span when Stop is called
+func (m *CirconusMetrics) StartAnnotation(category, title, description string, relMetrics ...string) *AnnotationSpan {
+	now := uint(time.Now().Unix())
+	span := &AnnotationSpan{m: m, annotation: newAnnotation(category, title, description, now, relMetrics)}
+	m.queueAnnotation(&annotationJob{annotation: span.annotation, span: span})
+	return span
+}
+
+// Stop ends the span, the annotation is updated with the stop time.
+// Only the first call has an effect. If the annotation could not be
+// created, the stop time is not sent (no annotation is created by Stop).
+func (s *AnnotationSpan) Stop() {
+	s.stopOnce.Do(func() {
+		a := s.annotation
+		a.Stop = uint(time.Now().Unix())
+		s.m.queueAnnotation(&annotationJob{annotation: a, span: s, stop: true})
+	})
+}
+
+// FlushAnnotations waits until the annotations queued before the call
+// have been sent (or have failed), e.g. before the application exits.
+// It returns ctx.Err() if ctx is done first.
+func (m *CirconusMetrics) FlushAnnotations(ctx context.Context) error {
+	m.closemu.RLock()
+	if m.closed {
+		// the worker exits once the queued annotations are sent
+		done := m.annotationsDone
+		m.closemu.RUnlock()
+		if done == nil {
+			return nil
+		}
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	m.startAnnotationWorker()
+
+	done := make(chan struct{})
+	select {
+	case m.annotations <- &annotationJob{done: done}:
+	case <-ctx.Done():
+		m.closemu.RUnlock()
+		return ctx.Err()
+	}
+	m.closemu.RUnlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newAnnotation returns an annotation starting and stopping at ts
+func newAnnotation(category, title, description string, ts uint, relMetrics []string) api.Annotation {
+	if relMetrics == nil {
+		relMetrics = []string{}
+	}
+	return api.Annotation{
+		Category:       category,
+		Title:          title,
+		Description:    description,
+		RelatedMetrics: relMetrics,
+		Start:          ts,
+		Stop:           ts,
+	}
+}
+
+// queueAnnotation queues job for the annotation worker, started with the
+// first annotation. The job is dropped if the queue is full or the
+// instance has been closed.
+func (m *CirconusMetrics) queueAnnotation(job *annotationJob) {
+	m.closemu.RLock()
+	defer m.closemu.RUnlock()
+
+	if m.closed {
+		m.getLogger().Warn("closed, dropping annotation", "category", job.annotation.Category, "title", job.annotation.Title)
+		return
+	}
+
+	m.startAnnotationWorker()
+
+	select {
+	case m.annotations <- job:
+	default:
+		m.getLogger().Warn("annotation queue full, dropping annotation", "category", job.annotation.Category, "title", job.annotation.Title)
+	}
+}
+
+// startAnnotationWorker creates the queue and starts the annotation
+// worker, once
+func (m *CirconusMetrics) startAnnotationWorker() {
+	m.annotationsOnce.Do(func() {
+		m.annotations = make(chan *annotationJob, annotationQueueSize)
+		m.annotationsDone = make(chan struct{})
+		go m.annotationWorker()
+	})
+}
+
+// closeAnnotations closes the queue, the worker exits once the queued
+// annotations are sent. Caller must hold closemu.
+func (m *CirconusMetrics) closeAnnotations() {
+	m.annotationsOnce.Do(func() {}) // not started, never start it
+	if m.annotations != nil {
+		close(m.annotations)
+	}
+}
+
+// annotationWorker sends queued annotations, one at a time so a span
+// is created before it is updated
+func (m *CirconusMetrics) annotationWorker() {
+	defer close(m.annotationsDone)
+
+	for job := range m.annotations {
+		if job.done != nil {
+			close(job.done)
+			continue
+		}
+		if err := m.sendAnnotation(job); err != nil {
+			m.getLogger().Error("sending annotation", "category", job.annotation.Category, "title", job.annotation.Title, "err", err)
+		}
+	}
+}
+
+// sendAnnotation creates the annotation, or updates it when the job stops a
+// span, retrying up to annotationMaxAttempts times. Client errors (4xx,
+// other than 429) are not retried.
+func (m *CirconusMetrics) sendAnnotation(job *annotationJob) error {
+	if job.stop && job.span.cid == "" {
+		return errors.New("span annotation was not created, not stopping")
+	}
+
+	wait := m.annotationRetryWait
+	if wait == 0 {
+		wait = defaultAnnotationRetryWait
+	}
+
+	var err error
+	for attempt := 1; attempt <= annotationMaxAttempts; attempt++ {
+		if attempt > 1 {
+			m.getLogger().Warn("annotation failed, retrying", "title", job.annotation.Title, "err", err, "attempt", attempt, "wait", wait)
+			time.Sleep(wait)
+		}
+
+		a := job.annotation
+		var result *api.Annotation
+		if job.stop {
+			a.CID = job.span.cid
+			result, err = m.check.UpdateAnnotation(&a)
+		} else {
+			result, err = m.check.CreateAnnotation(&a)
+		}
+		if err == nil {
+			if job.span != nil {
+				job.span.cid = result.CID
+			}
+			return nil
+		}
+		if errors.Cause(err) == checkmgr.ErrAPIDisabled || apiClientError(err) {
+			return err
+		}
+	}
+
+	return errors.Wrapf(err, "giving up after %d attempts", annotationMaxAttempts)
+}
+
+// apiClientError returns true if err is an api 4xx response, other than
+// 429 (too many requests), retrying would not help
+func apiClientError(err error) bool {
+	rerr, ok := errors.Cause(err).(*api.ResponseError)
+	return ok && rerr.StatusCode >= 400 && rerr.StatusCode < 500 && rerr.StatusCode != 429
+}
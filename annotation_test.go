@@ -0,0 +1,234 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/pkg/errors"
+)
+
+// annotationServer records annotation requests, the first create request
+// fails (invalid response, retried)
+func annotationServer() (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var requests []string
+	created := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var a api.Annotation
+		if err := json.Unmarshal(body, &a); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, a.Title))
+		if r.Method == "POST" {
+			created++
+			if created == 1 {
+				w.WriteHeader(200)
+				w.Write([]byte("{"))
+				return
+			}
+			a.CID = fmt.Sprintf("/annotation/%d", created)
+		}
+
+		ret, _ := json.Marshal(a)
+		w.WriteHeader(200)
+		w.Write(ret)
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, requests...)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	t.Log("Testing Annotate")
+
+	server, requests := annotationServer()
+	defer server.Close()
+
+	// check manager not initialized, initialization uses the api with
+	// exponential backoff (see api.EnableExponentialBackoff)
+	check, err := checkmgr.New(&checkmgr.Config{API: api.Config{TokenKey: "1234", URL: server.URL}})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	m := &CirconusMetrics{check: check, annotationRetryWait: 10 * time.Millisecond}
+
+	m.Annotate("deploy", "v1.2.3", "deployed v1.2.3", "1234_requests")
+	span := m.StartAnnotation("incident", "outage", "")
+	span.Stop()
+	span.Stop()
+
+	expect := []string{
+		"POST /annotation v1.2.3",
+		"POST /annotation v1.2.3",
+		"POST /annotation outage",
+		"PUT /annotation/3 outage",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.FlushAnnotations(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	if have := requests(); fmt.Sprint(have) != fmt.Sprint(expect) {
+		t.Fatalf("Expected %v, got %v", expect, have)
+	}
+
+	t.Log("flush, context done")
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := m.FlushAnnotations(ctx); err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got '%v'", err)
+		}
+	}
+}
+
+func TestCloseAnnotations(t *testing.T) {
+	t.Log("Testing Close with queued annotations")
+
+	server, requests := annotationServer()
+	defer server.Close()
+
+	check, err := checkmgr.New(&checkmgr.Config{API: api.Config{TokenKey: "1234", URL: server.URL}})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	m := &CirconusMetrics{check: check, annotationRetryWait: 10 * time.Millisecond}
+
+	t.Log("not started")
+	{
+		closed := &CirconusMetrics{}
+		closed.Close()
+		closed.Close()
+		if err := closed.FlushAnnotations(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		closed.Annotate("deploy", "v1", "")
+		if closed.annotations != nil {
+			t.Fatal("Expected worker not to be started")
+		}
+	}
+
+	m.Annotate("deploy", "v1.2.3", "")
+	m.Close()
+	m.Annotate("deploy", "v1.2.4", "") // dropped
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.FlushAnnotations(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expect := []string{"POST /annotation v1.2.3", "POST /annotation v1.2.3"}
+	if have := requests(); fmt.Sprint(have) != fmt.Sprint(expect) {
+		t.Fatalf("Expected %v, got %v", expect, have)
+	}
+}
+
+func TestSendAnnotation(t *testing.T) {
+	t.Log("Testing sendAnnotation")
+
+	t.Log("check management disabled")
+	{
+		cfg := &Config{}
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+
+		m, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		start := time.Now()
+		err = m.sendAnnotation(&annotationJob{annotation: newAnnotation("deploy", "v1", "", 1, nil)})
+		if errors.Cause(err) != checkmgr.ErrAPIDisabled {
+			t.Fatalf("Expected ErrAPIDisabled, got '%v'", err)
+		}
+		if time.Since(start) >= defaultAnnotationRetryWait {
+			t.Fatal("Expected no retries")
+		}
+	}
+
+	t.Log("retries exhausted")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte("{"))
+		}))
+		defer server.Close()
+
+		check, err := checkmgr.New(&checkmgr.Config{API: api.Config{TokenKey: "1234", URL: server.URL}})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		m := &CirconusMetrics{check: check, annotationRetryWait: time.Millisecond}
+
+		err = m.sendAnnotation(&annotationJob{annotation: newAnnotation("deploy", "v1", "", 1, nil)})
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("client error, not retried")
+	{
+		var mu sync.Mutex
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			w.WriteHeader(400)
+		}))
+		defer server.Close()
+
+		check, err := checkmgr.New(&checkmgr.Config{API: api.Config{TokenKey: "1234", URL: server.URL}})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		m := &CirconusMetrics{check: check, annotationRetryWait: time.Millisecond}
+
+		span := &AnnotationSpan{m: m, annotation: newAnnotation("incident", "outage", "", 1, nil)}
+		if err := m.sendAnnotation(&annotationJob{annotation: span.annotation, span: span}); err == nil {
+			t.Fatal("Expected error")
+		}
+		mu.Lock()
+		if calls != 1 {
+			t.Fatalf("Expected 1 call, got %d", calls)
+		}
+		mu.Unlock()
+
+		t.Log("span not created, stop skipped")
+		if err := m.sendAnnotation(&annotationJob{annotation: span.annotation, span: span, stop: true}); err == nil {
+			t.Fatal("Expected error")
+		}
+		mu.Lock()
+		if calls != 1 {
+			t.Fatalf("Expected no call, got %d", calls-1)
+		}
+		mu.Unlock()
+	}
+}
@@ -79,8 +79,8 @@ func (a *API) FetchAccount(cid CIDType) (*Account, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] account fetch, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("account fetch, received JSON", "json", string(result))
 	}
 
 	account := new(Account)
@@ -127,8 +127,8 @@ func (a *API) UpdateAccount(cfg *Account) (*Account, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] account update, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("account update, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(accountCID, jsonCfg)
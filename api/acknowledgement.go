@@ -56,8 +56,8 @@ func (a *API) FetchAcknowledgement(cid CIDType) (*Acknowledgement, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] acknowledgement fetch, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("acknowledgement fetch, received JSON", "json", string(result))
 	}
 
 	acknowledgement := &Acknowledgement{}
@@ -104,8 +104,8 @@ func (a *API) UpdateAcknowledgement(cfg *Acknowledgement) (*Acknowledgement, err
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] acknowledgement update, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("acknowledgement update, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(acknowledgementCID, jsonCfg)
@@ -137,8 +137,8 @@ func (a *API) CreateAcknowledgement(cfg *Acknowledgement) (*Acknowledgement, err
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] acknowledgement create, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("acknowledgement create, sending JSON", "json", string(jsonCfg))
 	}
 
 	acknowledgement := &Acknowledgement{}
@@ -63,8 +63,8 @@ func (a *API) FetchAlert(cid CIDType) (*Alert, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch alert, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch alert, received JSON", "json", string(result))
 	}
 
 	alert := &Alert{}
@@ -56,8 +56,8 @@ func (a *API) FetchAnnotation(cid CIDType) (*Annotation, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch annotation, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch annotation, received JSON", "json", string(result))
 	}
 
 	annotation := &Annotation{}
@@ -104,8 +104,8 @@ func (a *API) UpdateAnnotation(cfg *Annotation) (*Annotation, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update annotation, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update annotation, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(annotationCID, jsonCfg)
@@ -132,8 +132,8 @@ func (a *API) CreateAnnotation(cfg *Annotation) (*Annotation, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create annotation, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create annotation, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.AnnotationPrefix, jsonCfg)
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/circonus-labs/circonus-gometrics/logging"
 	"github.com/hashicorp/go-retryablehttp"
 )
 
@@ -94,6 +95,9 @@ type Config struct {
 
 	Log   *log.Logger
 	Debug bool
+
+	// Logger, leveled structured logger (default Log, debug messages only when Debug is true)
+	Logger logging.Logger
 }
 
 // API Circonus API
@@ -105,11 +109,29 @@ type API struct {
 	caCert                  *x509.CertPool
 	tlsConfig               *tls.Config
 	Debug                   bool
+	debugmu                 sync.Mutex
 	Log                     *log.Logger
+	logger                  logging.Logger
 	useExponentialBackoff   bool
 	useExponentialBackoffmu sync.Mutex
 }
 
+// ResponseError is returned when the API responds with a non-2xx status code
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error returns the status code and response body
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("[ERROR] %s", e.message())
+}
+
+// message is the error without the [ERROR] prefix, for logging
+func (e *ResponseError) message() string {
+	return fmt.Sprintf("API response code %d: %s", e.StatusCode, e.Body)
+}
+
 // NewClient returns a new Circonus API (alias for New)
 func NewClient(ac *Config) (*API, error) {
 	return New(ac)
@@ -176,10 +198,45 @@ func New(ac *Config) (*API, error) {
 	if a.Log == nil {
 		a.Log = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
+	a.logger = ac.Logger
+	if a.logger == nil {
+		a.logger = logging.NewStdLogger(a.Log, a.Debug)
+	}
 
 	return a, nil
 }
 
+// getLogger returns the logger, falling back to Log when not initialized with New
+func (a *API) getLogger() logging.Logger {
+	if a.logger != nil {
+		return a.logger
+	}
+	a.debugmu.Lock()
+	defer a.debugmu.Unlock()
+	return logging.NewStdLogger(a.Log, a.Debug)
+}
+
+// debugEnabled reports whether debug messages are enabled, see SetDebug
+func (a *API) debugEnabled() bool {
+	a.debugmu.Lock()
+	defer a.debugmu.Unlock()
+	return a.Debug
+}
+
+// SetDebug enables or disables debug messages, returns false if the
+// logger does not support changing it (see logging.DebugSetter)
+func (a *API) SetDebug(debug bool) bool {
+	a.debugmu.Lock()
+	a.Debug = debug
+	a.debugmu.Unlock()
+
+	ds, ok := a.getLogger().(logging.DebugSetter)
+	if ok {
+		ds.SetDebug(debug)
+	}
+	return ok
+}
+
 // EnableExponentialBackoff enables use of exponential backoff for next API call(s)
 // and use exponential backoff for all API calls until exponential backoff is disabled.
 func (a *API) EnableExponentialBackoff() {
@@ -241,7 +298,7 @@ func (a *API) apiRequest(reqMethod string, reqPath string, data []byte) ([]byte,
 			if !a.useExponentialBackoff {
 				break
 			}
-			if strings.Contains(err.Error(), "code 403") {
+			if rerr, ok := err.(*ResponseError); ok && rerr.StatusCode == 403 {
 				break
 			}
 		}
@@ -254,7 +311,7 @@ func (a *API) apiRequest(reqMethod string, reqPath string, data []byte) ([]byte,
 				wait = backoff(backoffs[attempts])
 			}
 			attempts++
-			a.Log.Printf("[WARN] API call failed %s, retrying in %d seconds.\n", err.Error(), uint(wait))
+			a.getLogger().Warn("API call failed, retrying", "err", err, "wait_seconds", uint(wait))
 			time.Sleep(time.Duration(wait) * time.Second)
 		}
 	}
@@ -370,12 +427,9 @@ func (a *API) apiCall(reqMethod string, reqPath string, data []byte) ([]byte, er
 		client.RetryMax = maxRetries
 	}
 
-	// retryablehttp only groks log or no log
-	if a.Debug {
-		client.Logger = a.Log
-	} else {
-		client.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
-	}
+	// retryablehttp only groks a standard logger, forward
+	// its messages (by level) to the leveled logger
+	client.Logger = logging.NewStdLog(a.getLogger())
 
 	client.CheckRetry = retryPolicy
 
@@ -394,12 +448,10 @@ func (a *API) apiCall(reqMethod string, reqPath string, data []byte) ([]byte, er
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg := fmt.Sprintf("API response code %d: %s", resp.StatusCode, string(body))
-		if a.Debug {
-			a.Log.Printf("[DEBUG] %s\n", msg)
-		}
+		rerr := &ResponseError{StatusCode: resp.StatusCode, Body: string(body)}
+		a.getLogger().Debug(rerr.message())
 
-		return nil, fmt.Errorf("[ERROR] %s", msg)
+		return nil, rerr
 	}
 
 	return body, nil
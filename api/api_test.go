@@ -550,6 +550,9 @@ func TestApiRequest(t *testing.T) {
 		if err == nil {
 			t.Fatal("expected error")
 		}
+		if rerr, ok := err.(*ResponseError); !ok || rerr.StatusCode != 403 {
+			t.Fatalf("expected *ResponseError with status code 403, got %T '%v'", err, err)
+		}
 	}
 
 	t.Log("drift retry - bad app")
@@ -568,6 +571,9 @@ func TestApiRequest(t *testing.T) {
 		if err == nil {
 			t.Fatal("expected error")
 		}
+		if rerr, ok := err.(*ResponseError); !ok || rerr.StatusCode != 403 {
+			t.Fatalf("expected *ResponseError with status code 403, got %T '%v'", err, err)
+		}
 	}
 
 	t.Log("exponential backoff - bad app")
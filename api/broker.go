@@ -62,8 +62,8 @@ func (a *API) FetchBroker(cid CIDType) (*Broker, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch broker, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch broker, received JSON", "json", string(result))
 	}
 
 	response := new(Broker)
@@ -51,8 +51,8 @@ func (a *API) FetchCheck(cid CIDType) (*Check, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch check, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch check, received JSON", "json", string(result))
 	}
 
 	check := new(Check)
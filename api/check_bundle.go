@@ -87,8 +87,8 @@ func (a *API) FetchCheckBundle(cid CIDType) (*CheckBundle, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch check bundle, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch check bundle, received JSON", "json", string(result))
 	}
 
 	checkBundle := &CheckBundle{}
@@ -135,8 +135,8 @@ func (a *API) UpdateCheckBundle(cfg *CheckBundle) (*CheckBundle, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update check bundle, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update check bundle, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(bundleCID, jsonCfg)
@@ -163,8 +163,8 @@ func (a *API) CreateCheckBundle(cfg *CheckBundle) (*CheckBundle, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create check bundle, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create check bundle, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.CheckBundlePrefix, jsonCfg)
@@ -44,8 +44,8 @@ func (a *API) FetchCheckBundleMetrics(cid CIDType) (*CheckBundleMetrics, error)
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch check bundle metrics, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch check bundle metrics, received JSON", "json", string(result))
 	}
 
 	metrics := &CheckBundleMetrics{}
@@ -77,8 +77,8 @@ func (a *API) UpdateCheckBundleMetrics(cfg *CheckBundleMetrics) (*CheckBundleMet
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update check bundle metrics, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update check bundle metrics, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(metricsCID, jsonCfg)
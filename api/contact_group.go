@@ -97,8 +97,8 @@ func (a *API) FetchContactGroup(cid CIDType) (*ContactGroup, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch contact group, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch contact group, received JSON", "json", string(result))
 	}
 
 	group := new(ContactGroup)
@@ -145,8 +145,8 @@ func (a *API) UpdateContactGroup(cfg *ContactGroup) (*ContactGroup, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update contact group, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update contact group, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(groupCID, jsonCfg)
@@ -173,8 +173,8 @@ func (a *API) CreateContactGroup(cfg *ContactGroup) (*ContactGroup, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create contact group, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create contact group, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.ContactGroupPrefix, jsonCfg)
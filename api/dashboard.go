@@ -234,8 +234,8 @@ func (a *API) FetchDashboard(cid CIDType) (*Dashboard, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch dashboard, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch dashboard, received JSON", "json", string(result))
 	}
 
 	dashboard := new(Dashboard)
@@ -282,8 +282,8 @@ func (a *API) UpdateDashboard(cfg *Dashboard) (*Dashboard, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update dashboard, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update dashboard, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(dashboardCID, jsonCfg)
@@ -310,8 +310,8 @@ func (a *API) CreateDashboard(cfg *Dashboard) (*Dashboard, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create dashboard, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create dashboard, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.DashboardPrefix, jsonCfg)
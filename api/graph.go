@@ -183,8 +183,8 @@ func (a *API) FetchGraph(cid CIDType) (*Graph, error) {
 	if err != nil {
 		return nil, err
 	}
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch graph, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch graph, received JSON", "json", string(result))
 	}
 
 	graph := new(Graph)
@@ -231,8 +231,8 @@ func (a *API) UpdateGraph(cfg *Graph) (*Graph, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update graph, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update graph, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(graphCID, jsonCfg)
@@ -259,8 +259,8 @@ func (a *API) CreateGraph(cfg *Graph) (*Graph, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update graph, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update graph, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.GraphPrefix, jsonCfg)
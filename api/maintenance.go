@@ -54,8 +54,8 @@ func (a *API) FetchMaintenanceWindow(cid CIDType) (*Maintenance, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch maintenance window, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch maintenance window, received JSON", "json", string(result))
 	}
 
 	window := &Maintenance{}
@@ -102,8 +102,8 @@ func (a *API) UpdateMaintenanceWindow(cfg *Maintenance) (*Maintenance, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update maintenance window, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update maintenance window, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(maintenanceCID, jsonCfg)
@@ -130,8 +130,8 @@ func (a *API) CreateMaintenanceWindow(cfg *Maintenance) (*Maintenance, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create maintenance window, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create maintenance window, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.MaintenancePrefix, jsonCfg)
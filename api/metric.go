@@ -56,8 +56,8 @@ func (a *API) FetchMetric(cid CIDType) (*Metric, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch metric, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch metric, received JSON", "json", string(result))
 	}
 
 	metric := &Metric{}
@@ -104,8 +104,8 @@ func (a *API) UpdateMetric(cfg *Metric) (*Metric, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update metric, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update metric, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(metricCID, jsonCfg)
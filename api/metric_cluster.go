@@ -77,8 +77,8 @@ func (a *API) FetchMetricCluster(cid CIDType, extras string) (*MetricCluster, er
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch metric cluster, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch metric cluster, received JSON", "json", string(result))
 	}
 
 	cluster := &MetricCluster{}
@@ -143,8 +143,8 @@ func (a *API) UpdateMetricCluster(cfg *MetricCluster) (*MetricCluster, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update metric cluster, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update metric cluster, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(clusterCID, jsonCfg)
@@ -171,8 +171,8 @@ func (a *API) CreateMetricCluster(cfg *MetricCluster) (*MetricCluster, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create metric cluster, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create metric cluster, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.MetricClusterPrefix, jsonCfg)
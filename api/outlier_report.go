@@ -55,8 +55,8 @@ func (a *API) FetchOutlierReport(cid CIDType) (*OutlierReport, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch outlier report, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch outlier report, received JSON", "json", string(result))
 	}
 
 	report := &OutlierReport{}
@@ -103,8 +103,8 @@ func (a *API) UpdateOutlierReport(cfg *OutlierReport) (*OutlierReport, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update outlier report, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update outlier report, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(reportCID, jsonCfg)
@@ -131,8 +131,8 @@ func (a *API) CreateOutlierReport(cfg *OutlierReport) (*OutlierReport, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create outlier report, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create outlier report, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.OutlierReportPrefix, jsonCfg)
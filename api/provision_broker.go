@@ -68,8 +68,8 @@ func (a *API) FetchProvisionBroker(cid CIDType) (*ProvisionBroker, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch broker provision request, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch broker provision request, received JSON", "json", string(result))
 	}
 
 	broker := &ProvisionBroker{}
@@ -105,8 +105,8 @@ func (a *API) UpdateProvisionBroker(cid CIDType, cfg *ProvisionBroker) (*Provisi
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update broker provision request, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update broker provision request, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(brokerCID, jsonCfg)
@@ -133,8 +133,8 @@ func (a *API) CreateProvisionBroker(cfg *ProvisionBroker) (*ProvisionBroker, err
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create broker provision request, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create broker provision request, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.ProvisionBrokerPrefix, jsonCfg)
@@ -68,8 +68,8 @@ func (a *API) FetchRuleSet(cid CIDType) (*RuleSet, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch rule set, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch rule set, received JSON", "json", string(result))
 	}
 
 	ruleset := &RuleSet{}
@@ -116,8 +116,8 @@ func (a *API) UpdateRuleSet(cfg *RuleSet) (*RuleSet, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update rule set, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update rule set, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(rulesetCID, jsonCfg)
@@ -144,8 +144,8 @@ func (a *API) CreateRuleSet(cfg *RuleSet) (*RuleSet, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create rule set, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create rule set, sending JSON", "json", string(jsonCfg))
 	}
 
 	resp, err := a.Post(config.RuleSetPrefix, jsonCfg)
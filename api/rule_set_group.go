@@ -65,8 +65,8 @@ func (a *API) FetchRuleSetGroup(cid CIDType) (*RuleSetGroup, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch rule set group, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch rule set group, received JSON", "json", string(result))
 	}
 
 	rulesetGroup := &RuleSetGroup{}
@@ -113,8 +113,8 @@ func (a *API) UpdateRuleSetGroup(cfg *RuleSetGroup) (*RuleSetGroup, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update rule set group, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update rule set group, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(groupCID, jsonCfg)
@@ -141,8 +141,8 @@ func (a *API) CreateRuleSetGroup(cfg *RuleSetGroup) (*RuleSetGroup, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create rule set group, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create rule set group, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.RuleSetGroupPrefix, jsonCfg)
@@ -57,8 +57,8 @@ func (a *API) FetchUser(cid CIDType) (*User, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch user, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch user, received JSON", "json", string(result))
 	}
 
 	user := new(User)
@@ -105,8 +105,8 @@ func (a *API) UpdateUser(cfg *User) (*User, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update user, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update user, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(userCID, jsonCfg)
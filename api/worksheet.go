@@ -68,8 +68,8 @@ func (a *API) FetchWorksheet(cid CIDType) (*Worksheet, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] fetch worksheet, received JSON: %s", string(result))
+	if a.debugEnabled() {
+		a.getLogger().Debug("fetch worksheet, received JSON", "json", string(result))
 	}
 
 	worksheet := new(Worksheet)
@@ -116,8 +116,8 @@ func (a *API) UpdateWorksheet(cfg *Worksheet) (*Worksheet, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] update worksheet, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("update worksheet, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Put(worksheetCID, jsonCfg)
@@ -144,8 +144,8 @@ func (a *API) CreateWorksheet(cfg *Worksheet) (*Worksheet, error) {
 		return nil, err
 	}
 
-	if a.Debug {
-		a.Log.Printf("[DEBUG] create annotation, sending JSON: %s", string(jsonCfg))
+	if a.debugEnabled() {
+		a.getLogger().Debug("create annotation, sending JSON", "json", string(jsonCfg))
 	}
 
 	result, err := a.Post(config.WorksheetPrefix, jsonCfg)
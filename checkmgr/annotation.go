@@ -0,0 +1,33 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checkmgr
+
+import (
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/pkg/errors"
+)
+
+// ErrAPIDisabled is returned by calls which require the api when check
+// management is disabled (no API.TokenKey)
+var ErrAPIDisabled = errors.New("check management disabled, api token required")
+
+// CreateAnnotation creates an annotation using the check manager api handle
+func (cm *CheckManager) CreateAnnotation(annotation *api.Annotation) (*api.Annotation, error) {
+	if !cm.enabled || cm.apih == nil {
+		return nil, errors.Wrap(ErrAPIDisabled, "create annotation")
+	}
+
+	return cm.apih.CreateAnnotation(annotation)
+}
+
+// UpdateAnnotation updates an existing annotation (annotation.CID must be
+// set) using the check manager api handle
+func (cm *CheckManager) UpdateAnnotation(annotation *api.Annotation) (*api.Annotation, error) {
+	if !cm.enabled || cm.apih == nil {
+		return nil, errors.Wrap(ErrAPIDisabled, "update annotation")
+	}
+
+	return cm.apih.UpdateAnnotation(annotation)
+}
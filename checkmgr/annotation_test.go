@@ -0,0 +1,90 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checkmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/pkg/errors"
+)
+
+func testAnnotationServer() *httptest.Server {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var a api.Annotation
+		if err := json.Unmarshal(body, &a); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/annotation":
+			a.CID = "/annotation/123"
+		case r.Method == "PUT" && r.URL.Path == "/annotation/123":
+		default:
+			w.WriteHeader(404)
+			return
+		}
+		ret, _ := json.Marshal(a)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(ret)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(f))
+}
+
+func TestAnnotation(t *testing.T) {
+	t.Log("Testing CreateAnnotation/UpdateAnnotation")
+
+	t.Log("check management disabled")
+	{
+		cfg := &Config{}
+		cfg.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+		cm, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		if _, err := cm.CreateAnnotation(&api.Annotation{}); errors.Cause(err) != ErrAPIDisabled {
+			t.Fatalf("Expected ErrAPIDisabled, got '%v'", err)
+		}
+		if _, err := cm.UpdateAnnotation(&api.Annotation{}); errors.Cause(err) != ErrAPIDisabled {
+			t.Fatalf("Expected ErrAPIDisabled, got '%v'", err)
+		}
+	}
+
+	t.Log("check management enabled")
+	{
+		server := testAnnotationServer()
+		defer server.Close()
+
+		cm, err := New(&Config{API: api.Config{TokenKey: "1234", TokenApp: "abcd", URL: server.URL}})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		a, err := cm.CreateAnnotation(&api.Annotation{Category: "deploy", Title: "v1.2.3", Start: 1, Stop: 1})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if a.CID != "/annotation/123" {
+			t.Fatalf("unexpected cid (%s)", a.CID)
+		}
+
+		a.Stop = 2
+		a, err = cm.UpdateAnnotation(a)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if a.Stop != 2 {
+			t.Fatalf("Expected stop 2, got %d", a.Stop)
+		}
+	}
+}
@@ -129,9 +129,7 @@ func (cm *CheckManager) selectBroker() (*api.Broker, error) {
 	validBrokerKeys := reflect.ValueOf(validBrokers).MapKeys()
 	selectedBroker := validBrokers[validBrokerKeys[rand.Intn(len(validBrokerKeys))].String()]
 
-	if cm.Debug {
-		cm.Log.Printf("[DEBUG] Selected broker '%s'\n", selectedBroker.Name)
-	}
+	cm.getLogger().Debug("selected broker", "broker", selectedBroker.Name)
 
 	return &selectedBroker, nil
 
@@ -178,17 +176,13 @@ func (cm *CheckManager) isValidBroker(broker *api.Broker) bool {
 
 		// broker must be active
 		if detail.Status != statusActive {
-			if cm.Debug {
-				cm.Log.Printf("[DEBUG] Broker '%s' is not active.\n", broker.Name)
-			}
+			cm.getLogger().Debug("broker is not active", "broker", broker.Name)
 			continue
 		}
 
 		// broker must have module loaded for the check type to be used
 		if !cm.brokerSupportsCheckType(cm.checkType, &detail) {
-			if cm.Debug {
-				cm.Log.Printf("[DEBUG] Broker '%s' does not support '%s' checks.\n", broker.Name, cm.checkType)
-			}
+			cm.getLogger().Debug("broker does not support check type", "broker", broker.Name, "check_type", cm.checkType)
 			continue
 		}
 
@@ -209,7 +203,7 @@ func (cm *CheckManager) isValidBroker(broker *api.Broker) bool {
 		}
 
 		if brokerHost == "" {
-			cm.Log.Printf("[WARN] Broker '%s' instance %s has no IP or external host set", broker.Name, detail.CN)
+			cm.getLogger().Warn("broker instance has no IP or external host set", "broker", broker.Name, "cn", detail.CN)
 			continue
 		}
 
@@ -227,14 +221,12 @@ func (cm *CheckManager) isValidBroker(broker *api.Broker) bool {
 				break
 			}
 
-			cm.Log.Printf("[WARN] Broker '%s' unable to connect, %v. Retrying in 2 seconds, attempt %d of %d.", broker.Name, err, attempt, retries)
+			cm.getLogger().Warn("broker unable to connect, retrying in 2 seconds", "broker", broker.Name, "err", err, "attempt", attempt, "retries", retries)
 			time.Sleep(2 * time.Second)
 		}
 
 		if valid {
-			if cm.Debug {
-				cm.Log.Printf("[DEBUG] Broker '%s' is valid\n", broker.Name)
-			}
+			cm.getLogger().Debug("broker is valid", "broker", broker.Name)
 			break
 		}
 	}
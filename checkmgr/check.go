@@ -21,6 +21,13 @@ import (
 
 // UpdateCheck determines if the check needs to be updated (new metrics, tags, etc.)
 func (cm *CheckManager) UpdateCheck(newMetrics map[string]*api.CheckBundleMetric) {
+	if len(cm.shards) > 0 {
+		for i, metrics := range cm.SplitMetrics(newMetrics) {
+			cm.shards[i].UpdateCheck(metrics)
+		}
+		return
+	}
+
 	// only if check manager is enabled
 	if !cm.enabled {
 		return
@@ -40,14 +47,17 @@ func (cm *CheckManager) UpdateCheck(newMetrics map[string]*api.CheckBundleMetric
 	cid := cm.checkBundle.CID
 	checkBundle, err := cm.apih.FetchCheckBundle(api.CIDType(&cid))
 	if err != nil {
-		cm.Log.Printf("[ERROR] unable to fetch up-to-date check bundle %v", err)
+		cm.getLogger().Error("unable to fetch up-to-date check bundle", "err", err)
 		return
 	}
 	cm.cbmu.Lock()
 	cm.checkBundle = checkBundle
 	cm.cbmu.Unlock()
 
-	// check metric_limit and see if it’s 0, if so, don't even bother to try to update the check.
+	// metric_limit -1 is unlimited, 0 is the account default
+	if limit := cm.checkBundle.MetricLimit; limit > 0 && len(cm.checkBundle.Metrics)+len(newMetrics) > limit {
+		cm.getLogger().Warn("check bundle metric limit reached, new metrics may not be activated (see Check.Shards)", "check_bundle", cm.checkBundle.CID, "limit", limit)
+	}
 
 	cm.addNewMetrics(newMetrics)
 
@@ -71,7 +81,7 @@ func (cm *CheckManager) UpdateCheck(newMetrics map[string]*api.CheckBundleMetric
 	if cm.forceCheckUpdate {
 		newCheckBundle, err := cm.apih.UpdateCheckBundle(cm.checkBundle)
 		if err != nil {
-			cm.Log.Printf("[ERROR] updating check bundle %v", err)
+			cm.getLogger().Error("updating check bundle", "err", err)
 			return
 		}
 
@@ -79,7 +89,10 @@ func (cm *CheckManager) UpdateCheck(newMetrics map[string]*api.CheckBundleMetric
 		cm.cbmu.Lock()
 		cm.checkBundle = newCheckBundle
 		cm.cbmu.Unlock()
-		cm.inventoryMetrics()
+		activated := cm.inventoryMetrics()
+		if len(activated) > 0 && cm.onMetricsActivated != nil {
+			cm.onMetricsActivated(activated)
+		}
 	}
 
 }
@@ -92,18 +105,20 @@ func (cm *CheckManager) UpdateCheck(newMetrics map[string]*api.CheckBundleMetric
 // check [bundle] by search
 // create check [bundle]
 func (cm *CheckManager) initializeTrapURL() error {
+	cm.trapmu.Lock()
+	defer cm.trapmu.Unlock()
+
 	if cm.trapURL != "" {
 		return nil
 	}
 
-	cm.trapmu.Lock()
-	defer cm.trapmu.Unlock()
-
 	// special case short-circuit: just send to a url, no check management
 	// up to user to ensure that if url is https that it will work (e.g. not self-signed)
 	if cm.checkSubmissionURL != "" {
 		if !cm.enabled {
-			cm.trapURL = cm.checkSubmissionURL
+			if err := cm.setTrapURL(cm.checkSubmissionURL); err != nil {
+				return err
+			}
 			cm.trapLastUpdate = time.Now()
 			return nil
 		}
@@ -138,9 +153,7 @@ func (cm *CheckManager) initializeTrapURL() error {
 			cm.checkID = api.IDType(id)
 			cm.checkSubmissionURL = ""
 		} else {
-			cm.Log.Printf(
-				"[WARN] SubmissionUrl check to Check ID: unable to convert %s to int %q\n",
-				check.CID, err)
+			cm.getLogger().Warn("SubmissionUrl check to Check ID: unable to convert check id to int", "check", check.CID, "err", err)
 		}
 	} else if cm.checkID > 0 {
 		cid := fmt.Sprintf("/check/%d", cm.checkID)
@@ -208,13 +221,12 @@ func (cm *CheckManager) initializeTrapURL() error {
 	cm.inventoryMetrics()
 
 	// determine the trap url to which metrics should be PUT
+	var trapURL api.URLType
 	if checkBundle.Type == "httptrap" {
 		if turl, found := checkBundle.Config[config.SubmissionURL]; found {
-			cm.trapURL = api.URLType(turl)
+			trapURL = api.URLType(turl)
 		} else {
-			if cm.Debug {
-				cm.Log.Printf("Missing config.%s %+v", config.SubmissionURL, checkBundle)
-			}
+			cm.getLogger().Debug("missing check bundle config", "config", config.SubmissionURL, "check_bundle", checkBundle)
 			return fmt.Errorf("[ERROR] Unable to use check, no %s in config", config.SubmissionURL)
 		}
 	} else {
@@ -226,33 +238,23 @@ func (cm *CheckManager) initializeTrapURL() error {
 		mtevURL = strings.Replace(mtevURL, "mtev_reverse", "https", 1)
 		mtevURL = strings.Replace(mtevURL, "check", "module/httptrap", 1)
 		if rs, found := checkBundle.Config[config.ReverseSecretKey]; found {
-			cm.trapURL = api.URLType(fmt.Sprintf("%s/%s", mtevURL, rs))
+			trapURL = api.URLType(fmt.Sprintf("%s/%s", mtevURL, rs))
 		} else {
-			if cm.Debug {
-				cm.Log.Printf("Missing config.%s %+v", config.ReverseSecretKey, checkBundle)
-			}
+			cm.getLogger().Debug("missing check bundle config", "config", config.ReverseSecretKey, "check_bundle", checkBundle)
 			return fmt.Errorf("[ERROR] Unable to use check, no %s in config", config.ReverseSecretKey)
 		}
 	}
 
 	// used when sending as "ServerName" get around certs not having IP SANS
 	// (cert created with server name as CN but IP used in trap url)
-	cn, err := cm.getBrokerCN(broker, cm.trapURL)
+	cn, err := cm.getBrokerCN(broker, trapURL)
 	if err != nil {
 		return err
 	}
 	cm.trapCN = BrokerCNType(cn)
 
-	if cm.enabled {
-		u, err := url.Parse(string(cm.trapURL))
-		if err != nil {
-			return err
-		}
-		if u.Scheme == "https" {
-			if err := cm.loadCACert(); err != nil {
-				return err
-			}
-		}
+	if err := cm.setTrapURL(trapURL); err != nil {
+		return err
 	}
 
 	cm.trapLastUpdate = time.Now()
@@ -306,6 +308,9 @@ func (cm *CheckManager) createNewCheck() (*api.CheckBundle, *api.Broker, error)
 	if err != nil {
 		return nil, nil, err
 	}
+	if cm.onBrokerSelected != nil {
+		cm.onBrokerSelected(broker)
+	}
 
 	chkcfg := &api.CheckBundle{
 		Brokers:     []string{broker.CID},
@@ -343,6 +348,9 @@ func (cm *CheckManager) createNewCheck() (*api.CheckBundle, *api.Broker, error)
 	if err != nil {
 		return nil, nil, err
 	}
+	if cm.onCheckCreated != nil {
+		cm.onCheckCreated(checkBundle)
+	}
 
 	return checkBundle, broker, nil
 }
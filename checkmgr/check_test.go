@@ -502,3 +502,78 @@ func TestInitializeTrapURL(t *testing.T) {
 	}
 
 }
+
+func TestCheckCallbacks(t *testing.T) {
+	server := testCheckServer()
+	defer server.Close()
+
+	testURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Error parsing temporary url %v", err)
+	}
+
+	hostParts := strings.Split(testURL.Host, ":")
+	hostPort, err := strconv.Atoi(hostParts[1])
+	if err != nil {
+		t.Fatalf("Error converting port to numeric %v", err)
+	}
+
+	testBroker.Details[0].ExternalHost = &hostParts[0]
+	testBroker.Details[0].ExternalPort = uint16(hostPort)
+
+	var selected *api.Broker
+	var created *api.CheckBundle
+	var activated []string
+
+	cm := &CheckManager{
+		enabled:               true,
+		checkDisplayName:      "test_dn",
+		checkInstanceID:       "test_id",
+		checkSearchTag:        api.TagType([]string{"test:test"}),
+		checkTarget:           "test",
+		brokerMaxResponseTime: time.Duration(time.Millisecond * 500),
+		checkType:             "httptrap",
+		onBrokerSelected:      func(broker *api.Broker) { selected = broker },
+		onCheckCreated:        func(checkBundle *api.CheckBundle) { created = checkBundle },
+		onMetricsActivated:    func(names []string) { activated = names },
+	}
+
+	ac := &api.Config{
+		TokenApp: "abcd",
+		TokenKey: "1234",
+		URL:      server.URL,
+	}
+	apih, err := api.NewAPI(ac)
+	if err != nil {
+		t.Errorf("Expected no error, got '%v'", err)
+	}
+	cm.apih = apih
+
+	t.Log("create check")
+	{
+		if _, _, err := cm.createNewCheck(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if selected == nil || selected.CID != testBroker.CID {
+			t.Fatalf("Expected broker %s to be selected, got %+v", testBroker.CID, selected)
+		}
+		if created == nil || created.CID != testCheckBundle.CID {
+			t.Fatalf("Expected check bundle %s to be created, got %+v", testCheckBundle.CID, created)
+		}
+	}
+
+	t.Log("activate metrics")
+	{
+		bundle := testCheckBundle
+		bundle.Metrics = []api.CheckBundleMetric{{Name: "elmo", Type: "numeric", Status: "active"}}
+		cm.checkBundle = &bundle
+		cm.availableMetrics = map[string]bool{"elmo": true}
+
+		cm.UpdateCheck(map[string]*api.CheckBundleMetric{
+			"test`metric": {Name: "test`metric", Type: "numeric", Status: "active"},
+		})
+		if len(activated) != 1 || activated[0] != "test`metric" {
+			t.Fatalf("Expected test`metric to be activated, got %v", activated)
+		}
+	}
+}
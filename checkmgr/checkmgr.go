@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/logging"
 	"github.com/pkg/errors"
 	"github.com/tv42/httpunix"
 )
@@ -49,6 +50,7 @@ const (
 	defaultBrokerMaxResponseTime = "500ms" // 500 milliseconds
 	defaultForceMetricActivation = "false"
 	statusActive                 = "active"
+	trapSockService              = "circonus-agent"
 )
 
 // CheckConfig options for check
@@ -91,6 +93,10 @@ type CheckConfig struct {
 	Type string
 	// Custom check config fields (default: none)
 	CustomConfigFields map[string]string
+	// number of check bundles to spread metrics across, for accounts
+	// with per-check metric limits. Requires check management and
+	// is not supported with SubmissionURL or ID. (default: 1)
+	Shards string
 }
 
 // BrokerConfig options for broker
@@ -112,12 +118,29 @@ type Config struct {
 	Log   *log.Logger
 	Debug bool
 
+	// Logger, leveled structured logger (default Log, debug messages only when Debug is true)
+	Logger logging.Logger
+
 	// Circonus API config
 	API api.Config
 	// Check specific configuration options
 	Check CheckConfig
 	// Broker specific configuration options
 	Broker BrokerConfig
+
+	// Event callbacks (optional), called synchronously from the goroutine
+	// handling the event, they should not block.
+
+	// called once the check has been initialized and metrics can be sent
+	OnReady func()
+	// called when a new check bundle is created
+	OnCheckCreated func(checkBundle *api.CheckBundle)
+	// called when a broker is selected for a new check
+	OnBrokerSelected func(broker *api.Broker)
+	// called with the names of metrics activated by a check bundle update
+	OnMetricsActivated func(names []string)
+	// called after the submission url has been reset (secrets are redacted)
+	OnTrapReset func(oldURL, newURL string)
 }
 
 // CheckTypeType check type
@@ -146,6 +169,8 @@ type CheckManager struct {
 	enabled bool
 	Log     *log.Logger
 	Debug   bool
+	debugmu sync.Mutex
+	logger  logging.Logger
 	apih    *api.API
 
 	initialized   bool
@@ -174,6 +199,7 @@ type CheckManager struct {
 	brokerSelectTag       api.TagType
 	brokerMaxResponseTime time.Duration
 	brokerTLS             *tls.Config
+	brokerTLSmu           sync.RWMutex
 
 	// state
 	checkBundle        *api.CheckBundle
@@ -187,6 +213,22 @@ type CheckManager struct {
 	trapmu             sync.Mutex
 	certPool           *x509.CertPool
 	sockRx             *regexp.Regexp
+
+	// cached per trap url so submission clients can be reused,
+	// cleared when the trap is reset
+	trapTLS           *tls.Config
+	trapSockTransport *httpunix.Transport
+
+	// shards, when metrics are spread across multiple check bundles
+	shards    []*CheckManager
+	shardRing *shardRing
+
+	// event callbacks
+	onReady            func()
+	onCheckCreated     func(checkBundle *api.CheckBundle)
+	onBrokerSelected   func(broker *api.Broker)
+	onMetricsActivated func(names []string)
+	onTrapReset        func(oldURL, newURL string)
 }
 
 // Trap config
@@ -220,6 +262,16 @@ func New(cfg *Config) (*CheckManager, error) {
 	if cm.Log == nil {
 		cm.Log = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
+	cm.logger = cfg.Logger
+	if cm.logger == nil {
+		cm.logger = logging.NewStdLogger(cm.Log, cm.Debug)
+	}
+
+	cm.onReady = cfg.OnReady
+	cm.onCheckCreated = cfg.OnCheckCreated
+	cm.onBrokerSelected = cfg.OnBrokerSelected
+	cm.onMetricsActivated = cfg.OnMetricsActivated
+	cm.onTrapReset = cfg.OnTrapReset
 
 	{
 		rx, err := regexp.Compile(`^http\+unix://(?P<sockfile>.+)/write/(?P<id>.+)$`)
@@ -246,6 +298,7 @@ func New(cfg *Config) (*CheckManager, error) {
 		// initialize api handle
 		cfg.API.Debug = cm.Debug
 		cfg.API.Log = cm.Log
+		cfg.API.Logger = cm.logger
 		apih, err := api.New(&cfg.API)
 		if err != nil {
 			return nil, errors.Wrap(err, "initializing api client")
@@ -359,11 +412,54 @@ func New(cfg *Config) (*CheckManager, error) {
 	cm.availableMetrics = make(map[string]bool)
 	cm.metricTags = make(map[string][]string)
 
+	if err := cm.initShards(cfg); err != nil {
+		return nil, err
+	}
+
 	return cm, nil
 }
 
+// getLogger returns the logger, falling back to Log when not initialized with New
+func (cm *CheckManager) getLogger() logging.Logger {
+	if cm.logger != nil {
+		return cm.logger
+	}
+	cm.debugmu.Lock()
+	defer cm.debugmu.Unlock()
+	return logging.NewStdLogger(cm.Log, cm.Debug)
+}
+
+// SetDebug enables or disables debug messages for the check manager, its
+// api client and shards, returns false if a logger does not support
+// changing it (see logging.DebugSetter)
+func (cm *CheckManager) SetDebug(debug bool) bool {
+	cm.debugmu.Lock()
+	cm.Debug = debug
+	cm.debugmu.Unlock()
+
+	ds, ok := cm.getLogger().(logging.DebugSetter)
+	if ok {
+		ds.SetDebug(debug)
+	}
+	if cm.apih != nil && !cm.apih.SetDebug(debug) {
+		ok = false
+	}
+	for _, shard := range cm.shards {
+		if !shard.SetDebug(debug) {
+			ok = false
+		}
+	}
+	return ok
+}
+
 // Initialize for sending metrics
 func (cm *CheckManager) Initialize() {
+	if len(cm.shards) > 0 {
+		for _, shard := range cm.shards {
+			shard.Initialize()
+		}
+		return
+	}
 
 	// if not managing the check, quicker initialization
 	if !cm.enabled {
@@ -372,8 +468,11 @@ func (cm *CheckManager) Initialize() {
 			cm.initializedmu.Lock()
 			cm.initialized = true
 			cm.initializedmu.Unlock()
+			if cm.onReady != nil {
+				cm.onReady()
+			}
 		} else {
-			cm.Log.Printf("[WARN] error initializing trap %s", err.Error())
+			cm.getLogger().Warn("error initializing trap", "err", err)
 		}
 		return
 	}
@@ -386,8 +485,11 @@ func (cm *CheckManager) Initialize() {
 			cm.initializedmu.Lock()
 			cm.initialized = true
 			cm.initializedmu.Unlock()
+			if cm.onReady != nil {
+				cm.onReady()
+			}
 		} else {
-			cm.Log.Printf("[WARN] error initializing trap %s", err.Error())
+			cm.getLogger().Warn("error initializing trap", "err", err)
 		}
 		cm.apih.DisableExponentialBackoff()
 	}()
@@ -395,6 +497,15 @@ func (cm *CheckManager) Initialize() {
 
 // IsReady reflects if the check has been initialied and metrics can be sent to Circonus
 func (cm *CheckManager) IsReady() bool {
+	if len(cm.shards) > 0 {
+		for _, shard := range cm.shards {
+			if !shard.IsReady() {
+				return false
+			}
+		}
+		return true
+	}
+
 	cm.initializedmu.RLock()
 	defer cm.initializedmu.RUnlock()
 	return cm.initialized
@@ -402,104 +513,203 @@ func (cm *CheckManager) IsReady() bool {
 
 // GetSubmissionURL returns submission url for circonus
 func (cm *CheckManager) GetSubmissionURL() (*Trap, error) {
-	if cm.trapURL == "" {
+	if len(cm.shards) > 0 {
+		return nil, errors.New("get submission url - check is sharded, use the submission url of each shard")
+	}
+
+	cm.trapmu.Lock()
+	trapURL := cm.trapURL
+	trapTLS := cm.trapTLS
+	sockTransport := cm.trapSockTransport
+	cm.trapmu.Unlock()
+
+	if trapURL == "" {
 		return nil, errors.Errorf("get submission url - submission url unavailable")
 	}
 
 	trap := &Trap{}
 
-	u, err := url.Parse(string(cm.trapURL))
+	u, err := url.Parse(string(trapURL))
 	if err != nil {
 		return nil, errors.Wrap(err, "get submission url")
 	}
 	trap.URL = u
 
 	if u.Scheme == "http+unix" {
-		service := "circonus-agent"
-		sockPath := ""
-		metricID := ""
-
-		subNames := cm.sockRx.SubexpNames()
-		matches := cm.sockRx.FindAllStringSubmatch(string(cm.trapURL), -1)
-		for _, match := range matches {
-			for idx, val := range match {
-				switch subNames[idx] {
-				case "sockfile":
-					sockPath = val
-				case "id":
-					metricID = val
-				}
-			}
-		}
-
-		if sockPath == "" || metricID == "" {
-			return nil, errors.Errorf("get submission url - invalid socket url (%s)", cm.trapURL)
+		_, metricID, err := cm.trapSocket(trapURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "get submission url")
 		}
 
-		u, err = url.Parse(fmt.Sprintf("http+unix://%s/write/%s", service, metricID))
+		u, err = url.Parse(fmt.Sprintf("http+unix://%s/write/%s", trapSockService, metricID))
 		if err != nil {
 			return nil, errors.Wrap(err, "get submission url")
 		}
 		trap.URL = u
 
-		trap.SockTransport = &httpunix.Transport{
-			DialTimeout:           100 * time.Millisecond,
-			RequestTimeout:        1 * time.Second,
-			ResponseHeaderTimeout: 1 * time.Second,
+		if sockTransport == nil {
+			return nil, errors.Errorf("get submission url - socket transport unavailable")
 		}
-		trap.SockTransport.RegisterLocation(service, sockPath)
+		trap.SockTransport = sockTransport
 		trap.IsSocket = true
 	}
 
 	if u.Scheme == "https" {
 		// preference user-supplied TLS configuration
-		if cm.brokerTLS != nil {
-			trap.TLS = cm.brokerTLS
+		cm.brokerTLSmu.RLock()
+		brokerTLS := cm.brokerTLS
+		cm.brokerTLSmu.RUnlock()
+		if brokerTLS != nil {
+			trap.TLS = brokerTLS
 			return trap, nil
 		}
 
+		trap.TLS = trapTLS // nil for api.circonus.com
+	}
+
+	return trap, nil
+}
+
+// trapSocket returns the socket path and metric id from an http+unix trap url
+func (cm *CheckManager) trapSocket(trapURL api.URLType) (string, string, error) {
+	sockPath := ""
+	metricID := ""
+
+	subNames := cm.sockRx.SubexpNames()
+	matches := cm.sockRx.FindAllStringSubmatch(string(trapURL), -1)
+	for _, match := range matches {
+		for idx, val := range match {
+			switch subNames[idx] {
+			case "sockfile":
+				sockPath = val
+			case "id":
+				metricID = val
+			}
+		}
+	}
+
+	if sockPath == "" || metricID == "" {
+		return "", "", errors.Errorf("invalid socket url (%s)", trapURL)
+	}
+
+	return sockPath, metricID, nil
+}
+
+// setTrapURL sets the trap url along with the socket transport (http+unix)
+// or tls config (https) used to submit to it, so submission clients can be
+// reused until the trap is reset. Caller must hold trapmu.
+func (cm *CheckManager) setTrapURL(trapURL api.URLType) error {
+	u, err := url.Parse(string(trapURL))
+	if err != nil {
+		return err
+	}
+
+	var sockTransport *httpunix.Transport
+	var trapTLS *tls.Config
+
+	switch u.Scheme {
+	case "http+unix":
+		sockPath, _, err := cm.trapSocket(trapURL)
+		if err != nil {
+			return err
+		}
+		sockTransport = &httpunix.Transport{
+			DialTimeout:           100 * time.Millisecond,
+			RequestTimeout:        1 * time.Second,
+			ResponseHeaderTimeout: 1 * time.Second,
+		}
+		sockTransport.RegisterLocation(trapSockService, sockPath)
+
+	case "https":
 		// api.circonus.com uses a public CA signed certificate
 		// trap.noit.circonus.net uses Circonus CA private certificate
 		// enterprise brokers use private CA certificate
-		if trap.URL.Hostname() == "api.circonus.com" {
-			return trap, nil
-		}
-
-		if cm.certPool == nil {
+		if u.Hostname() != "api.circonus.com" {
 			if err := cm.loadCACert(); err != nil {
-				return nil, errors.Wrap(err, "get submission url")
+				return err
+			}
+			trapTLS = &tls.Config{
+				RootCAs: cm.certPool,
+			}
+			if cm.trapCN != "" {
+				trapTLS.ServerName = string(cm.trapCN)
 			}
 		}
-		t := &tls.Config{
-			RootCAs: cm.certPool,
-		}
-		if cm.trapCN != "" {
-			t.ServerName = string(cm.trapCN)
-		}
-		trap.TLS = t
 	}
 
-	return trap, nil
+	cm.trapURL = trapURL
+	cm.trapSockTransport = sockTransport
+	cm.trapTLS = trapTLS
+
+	return nil
+}
+
+// SetBrokerTLSConfig replaces the user-supplied broker tls config (see
+// BrokerConfig.TLSConfig), nil reverts to the broker CA certificate. Used
+// for submissions from the next call to GetSubmissionURL.
+func (cm *CheckManager) SetBrokerTLSConfig(tlsConfig *tls.Config) {
+	cm.brokerTLSmu.Lock()
+	cm.brokerTLS = tlsConfig
+	cm.brokerTLSmu.Unlock()
+
+	for _, shard := range cm.shards {
+		shard.SetBrokerTLSConfig(tlsConfig)
+	}
 }
 
 // ResetTrap URL, force request to the API for the submission URL and broker ca cert
 func (cm *CheckManager) ResetTrap() error {
-	if cm.trapURL == "" {
+	if len(cm.shards) > 0 {
+		for _, shard := range cm.shards {
+			if err := shard.ResetTrap(); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 
+	cm.trapmu.Lock()
+	oldURL := cm.trapURL
+	if oldURL == "" {
+		cm.trapmu.Unlock()
+		return nil
+	}
 	cm.trapURL = ""
 	cm.certPool = nil // force re-fetching CA cert (if custom TLS config not supplied)
-	return cm.initializeTrapURL()
+	cm.trapTLS = nil
+	cm.trapSockTransport = nil
+	cm.trapmu.Unlock()
+
+	if err := cm.initializeTrapURL(); err != nil {
+		return err
+	}
+
+	if cm.onTrapReset != nil {
+		cm.trapmu.Lock()
+		newURL := cm.trapURL
+		cm.trapmu.Unlock()
+		cm.onTrapReset(redactTrapURL(string(oldURL)), redactTrapURL(string(newURL)))
+	}
+
+	return nil
 }
 
 // RefreshTrap check when the last time the URL was reset, reset if needed
 func (cm *CheckManager) RefreshTrap() error {
-	if cm.trapURL == "" {
+	if len(cm.shards) > 0 {
+		for _, shard := range cm.shards {
+			if err := shard.RefreshTrap(); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 
-	if time.Since(cm.trapLastUpdate) >= cm.trapMaxURLAge {
+	cm.trapmu.Lock()
+	expired := cm.trapURL != "" && time.Since(cm.trapLastUpdate) >= cm.trapMaxURLAge
+	cm.trapmu.Unlock()
+
+	if expired {
 		return cm.ResetTrap()
 	}
 
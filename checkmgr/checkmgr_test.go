@@ -18,6 +18,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -312,6 +313,72 @@ func TestNewCheckManager(t *testing.T) {
 		if trap.TLS == nil {
 			t.Fatalf("Expected a x509 cert pool, found nil")
 		}
+
+		t.Log("\ttls config cached until trap reset")
+		{
+			trap2, err := cm.GetSubmissionURL()
+			if err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			if trap2.TLS != trap.TLS {
+				t.Fatal("Expected same tls config")
+			}
+
+			if err := cm.ResetTrap(); err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+
+			trap3, err := cm.GetSubmissionURL()
+			if err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			if trap3.TLS == trap.TLS {
+				t.Fatal("Expected new tls config after reset")
+			}
+		}
+	}
+
+	t.Log("no API Token, Submission URL (http+unix) only, concurrent")
+	{
+		cfg := &Config{}
+		cfg.Check.SubmissionURL = "http+unix:///tmp/cgm-test.sock/write/test"
+
+		cm, err := NewCheckManager(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.Initialize()
+
+		for !cm.IsReady() {
+			t.Log("\twaiting for cm to init")
+			time.Sleep(1 * time.Second)
+		}
+
+		var wg sync.WaitGroup
+		traps := make([]*Trap, 8)
+		for i := range traps {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				trap, err := cm.GetSubmissionURL()
+				if err != nil {
+					t.Errorf("Expected no error, got '%v'", err)
+					return
+				}
+				traps[i] = trap
+			}(i)
+		}
+		wg.Wait()
+
+		for _, trap := range traps {
+			if trap == nil || trap.SockTransport == nil || trap.SockTransport != traps[0].SockTransport {
+				t.Fatal("Expected same socket transport")
+			}
+			if trap.URL.String() != "http+unix://circonus-agent/write/test" {
+				t.Fatalf("unexpected url (%s)", trap.URL)
+			}
+		}
 	}
 
 	t.Log("Defaults")
@@ -435,5 +502,50 @@ func TestNewCheckManager(t *testing.T) {
 				t.Fatalf("Expected '%s' got '%s'", server.URL, trap.URL.String())
 			}
 		}
+
+		t.Log("test SetBrokerTLSConfig")
+		{
+			tlsConfig := &tls.Config{RootCAs: cp, ServerName: "127.0.0.1"}
+			cm.SetBrokerTLSConfig(tlsConfig)
+			trap, err := cm.GetSubmissionURL()
+			if err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			if trap.TLS != tlsConfig {
+				t.Fatal("Expected updated tls config")
+			}
+		}
+	}
+}
+
+func TestCheckManagerCallbacks(t *testing.T) {
+	t.Log("Testing OnReady and OnTrapReset")
+
+	ready := make(chan struct{}, 1)
+	var oldURL, newURL string
+
+	cfg := &Config{}
+	cfg.Check.SubmissionURL = "http://127.0.0.1:56104/write/test"
+	cfg.OnReady = func() { ready <- struct{}{} }
+	cfg.OnTrapReset = func(o, n string) { oldURL, newURL = o, n }
+
+	cm, err := NewCheckManager(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	cm.Initialize()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected OnReady to be called")
+	}
+
+	if err := cm.ResetTrap(); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if oldURL != cfg.Check.SubmissionURL || newURL != cfg.Check.SubmissionURL {
+		t.Fatalf("unexpected trap reset urls (%s, %s)", oldURL, newURL)
 	}
 }
@@ -5,11 +5,17 @@
 package checkmgr
 
 import (
+	"sort"
+
 	"github.com/circonus-labs/circonus-gometrics/api"
 )
 
 // IsMetricActive checks whether a given metric name is currently active(enabled)
 func (cm *CheckManager) IsMetricActive(name string) bool {
+	if len(cm.shards) > 0 {
+		return cm.shardFor(name).IsMetricActive(name)
+	}
+
 	cm.availableMetricsmu.Lock()
 	defer cm.availableMetricsmu.Unlock()
 
@@ -18,6 +24,10 @@ func (cm *CheckManager) IsMetricActive(name string) bool {
 
 // ActivateMetric determines if a given metric should be activated
 func (cm *CheckManager) ActivateMetric(name string) bool {
+	if len(cm.shards) > 0 {
+		return cm.shardFor(name).ActivateMetric(name)
+	}
+
 	cm.availableMetricsmu.Lock()
 	defer cm.availableMetricsmu.Unlock()
 
@@ -36,6 +46,10 @@ func (cm *CheckManager) ActivateMetric(name string) bool {
 
 // AddMetricTags updates check bundle metrics with tags
 func (cm *CheckManager) AddMetricTags(metricName string, tags []string, appendTags bool) bool {
+	if len(cm.shards) > 0 {
+		return cm.shardFor(metricName).AddMetricTags(metricName, tags, appendTags)
+	}
+
 	tagsUpdated := false
 
 	if appendTags && len(tags) == 0 {
@@ -88,8 +102,8 @@ func (cm *CheckManager) AddMetricTags(metricName string, tags []string, appendTa
 		cm.metricTags[metricName] = currentTags
 	}
 
-	if cm.Debug && action != "" {
-		cm.Log.Printf("[DEBUG] %s metric tag(s) %s %v\n", action, metricName, tags)
+	if action != "" {
+		cm.getLogger().Debug(action+" metric tag(s)", "metric", metricName, "tags", tags)
 	}
 
 	return tagsUpdated
@@ -131,15 +145,26 @@ func (cm *CheckManager) addNewMetrics(newMetrics map[string]*api.CheckBundleMetr
 	return updatedCheckBundle
 }
 
-// inventoryMetrics creates list of active metrics in check bundle
-func (cm *CheckManager) inventoryMetrics() {
+// inventoryMetrics creates list of active metrics in check bundle,
+// returns the (sorted) names of metrics which were not previously active
+func (cm *CheckManager) inventoryMetrics() []string {
 	availableMetrics := make(map[string]bool)
 	for _, metric := range cm.checkBundle.Metrics {
 		availableMetrics[metric.Name] = metric.Status == "active"
 	}
 	cm.availableMetricsmu.Lock()
+	var activated []string
+	for name, active := range availableMetrics {
+		if active && !cm.availableMetrics[name] {
+			activated = append(activated, name)
+		}
+	}
 	cm.availableMetrics = availableMetrics
 	cm.availableMetricsmu.Unlock()
+
+	sort.Strings(activated)
+
+	return activated
 }
 
 // countNewTags returns a count of new tags which do not exist in the current list of tags
@@ -0,0 +1,172 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checkmgr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/internal/strhash"
+	"github.com/pkg/errors"
+)
+
+// Sharding spreads metrics across several check bundles, for accounts which
+// enforce per-check metric limits. Each shard is a complete check manager
+// with its own check bundle and trap url. Shards share the search tag(s) and
+// are numbered with an additional cgm_shard:<n> tag (and instance id suffix)
+// so each one can be found again on restart. Metric names are placed on a
+// shard using consistent hashing, changing the number of shards moves as few
+// metrics as possible.
+
+const (
+	defaultCheckShards = "1"
+	shardTagCategory   = "cgm_shard"
+	shardVirtualNodes  = 128 // points on the hash ring per shard
+)
+
+// shardRing is a consistent hash ring mapping metric names to shards
+type shardRing struct {
+	points []uint32
+	owners map[uint32]int
+}
+
+// newShardRing returns a hash ring for n shards
+func newShardRing(n int) *shardRing {
+	r := &shardRing{
+		points: make([]uint32, 0, n*shardVirtualNodes),
+		owners: make(map[uint32]int, n*shardVirtualNodes),
+	}
+
+	for shard := 0; shard < n; shard++ {
+		for v := 0; v < shardVirtualNodes; v++ {
+			p := shardHash(fmt.Sprintf("%s:%d:%d", shardTagCategory, shard+1, v))
+			if _, exists := r.owners[p]; exists {
+				continue // collision, first shard keeps the point
+			}
+			r.owners[p] = shard
+			r.points = append(r.points, p)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	return r
+}
+
+// shard returns the index of the shard which owns a metric name
+func (r *shardRing) shard(name string) int {
+	h := shardHash(name)
+	idx := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if idx == len(r.points) {
+		idx = 0
+	}
+	return r.owners[r.points[idx]]
+}
+
+// shardHash returns a well distributed 32-bit hash of a string
+func shardHash(s string) uint32 {
+	return uint32(strhash.Sum64(s) >> 32)
+}
+
+// initShards creates the shard check managers when more than one shard is configured
+func (cm *CheckManager) initShards(cfg *Config) error {
+	setting := defaultCheckShards
+	if cfg.Check.Shards != "" {
+		setting = cfg.Check.Shards
+	}
+	n, err := strconv.Atoi(setting)
+	if err != nil {
+		return errors.Wrap(err, "parsing shards")
+	}
+	if n < 1 {
+		return errors.Errorf("invalid shards (%d), must be >= 1", n)
+	}
+	if n == 1 {
+		return nil
+	}
+
+	if !cm.enabled {
+		return errors.New("invalid shards, check management must be enabled (API token required)")
+	}
+	if cm.checkSubmissionURL != "" || cm.checkID != 0 {
+		return errors.New("invalid shards, not supported with a specific submission url or check id")
+	}
+
+	// the sharded check is ready once every shard is ready
+	onReady := cfg.OnReady
+	if onReady != nil {
+		pending := int32(n)
+		onReady = func() {
+			if atomic.AddInt32(&pending, -1) == 0 {
+				cfg.OnReady()
+			}
+		}
+	}
+
+	cm.shards = make([]*CheckManager, n)
+	for i := 0; i < n; i++ {
+		scfg := *cfg
+		scfg.Debug = cm.Debug
+		scfg.Log = cm.Log
+		scfg.Logger = cm.logger
+		scfg.Check.Shards = "1"
+		scfg.OnReady = onReady
+		scfg.Check.InstanceID = fmt.Sprintf("%s:shard%d", cm.checkInstanceID, i+1)
+		scfg.Check.TargetHost = string(cm.checkTarget)
+		scfg.Check.DisplayName = fmt.Sprintf("%s (shard %d of %d)", cm.checkDisplayName, i+1, n)
+		tags := make([]string, len(cm.checkSearchTag), len(cm.checkSearchTag)+1)
+		copy(tags, cm.checkSearchTag)
+		scfg.Check.SearchTag = strings.Join(append(tags, fmt.Sprintf("%s:%d", shardTagCategory, i+1)), ",")
+
+		shard, err := New(&scfg)
+		if err != nil {
+			return errors.Wrapf(err, "creating shard %d", i+1)
+		}
+		cm.shards[i] = shard
+	}
+
+	cm.shardRing = newShardRing(n)
+
+	return nil
+}
+
+// Shards returns the check managers for each shard, nil if the check is not sharded
+func (cm *CheckManager) Shards() []*CheckManager {
+	return cm.shards
+}
+
+// ShardIndex returns the index (in Shards) of the shard a metric is sent to
+func (cm *CheckManager) ShardIndex(metricName string) int {
+	if cm.shardRing == nil {
+		return 0
+	}
+	return cm.shardRing.shard(metricName)
+}
+
+// shardFor returns the shard check manager for a metric
+func (cm *CheckManager) shardFor(metricName string) *CheckManager {
+	return cm.shards[cm.ShardIndex(metricName)]
+}
+
+// SplitMetrics partitions new metrics by shard index
+func (cm *CheckManager) SplitMetrics(newMetrics map[string]*api.CheckBundleMetric) []map[string]*api.CheckBundleMetric {
+	if len(cm.shards) == 0 {
+		return []map[string]*api.CheckBundleMetric{newMetrics}
+	}
+
+	parts := make([]map[string]*api.CheckBundleMetric, len(cm.shards))
+	for i := range parts {
+		parts[i] = make(map[string]*api.CheckBundleMetric)
+	}
+	for name, metric := range newMetrics {
+		parts[cm.ShardIndex(name)][name] = metric
+	}
+
+	return parts
+}
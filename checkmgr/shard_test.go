@@ -0,0 +1,268 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checkmgr
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+)
+
+func TestShardRing(t *testing.T) {
+	t.Log("Testing shard ring distribution")
+	{
+		r := newShardRing(4)
+		counts := make([]int, 4)
+		for i := 0; i < 10000; i++ {
+			counts[r.shard(fmt.Sprintf("metric%d", i))]++
+		}
+		for shard, n := range counts {
+			if n < 1500 || n > 3500 {
+				t.Fatalf("Expected roughly even distribution, shard %d has %d of 10000 (%v)", shard, n, counts)
+			}
+		}
+	}
+
+	t.Log("Testing shard ring stability")
+	{
+		r := newShardRing(4)
+		for i := 0; i < 100; i++ {
+			name := fmt.Sprintf("metric%d", i)
+			if r.shard(name) != r.shard(name) {
+				t.Fatalf("Expected %s to map to the same shard", name)
+			}
+		}
+	}
+
+	t.Log("Testing shard ring consistency (adding a shard)")
+	{
+		r4 := newShardRing(4)
+		r5 := newShardRing(5)
+		moved := 0
+		for i := 0; i < 10000; i++ {
+			name := fmt.Sprintf("metric%d", i)
+			s4 := r4.shard(name)
+			s5 := r5.shard(name)
+			if s4 != s5 {
+				if s5 != 4 {
+					t.Fatalf("Expected %s to move only to the new shard, moved %d -> %d", name, s4, s5)
+				}
+				moved++
+			}
+		}
+		if moved > 3500 {
+			t.Fatalf("Expected roughly 1/5 of metrics to move, %d of 10000 moved", moved)
+		}
+	}
+}
+
+func TestNewSharded(t *testing.T) {
+	t.Log("Testing New with shards")
+
+	apiCfg := api.Config{TokenKey: "1234", TokenApp: "abcd", URL: "http://127.0.0.1:1"}
+
+	t.Log("invalid setting")
+	{
+		for _, setting := range []string{"foo", "0", "-1"} {
+			cfg := &Config{API: apiCfg}
+			cfg.Check.Shards = setting
+			if _, err := New(cfg); err == nil {
+				t.Fatalf("Expected error for %s", setting)
+			}
+		}
+	}
+
+	t.Log("check management disabled")
+	{
+		cfg := &Config{}
+		cfg.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+		cfg.Check.Shards = "2"
+		if _, err := New(cfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("specific check")
+	{
+		cfg := &Config{API: apiCfg}
+		cfg.Check.ID = "1234"
+		cfg.Check.Shards = "2"
+		if _, err := New(cfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("not sharded")
+	{
+		cfg := &Config{API: apiCfg}
+		cm, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if cm.Shards() != nil {
+			t.Fatal("Expected no shards")
+		}
+		if cm.ShardIndex("foo") != 0 {
+			t.Fatal("Expected shard index 0")
+		}
+	}
+
+	t.Log("sharded")
+	{
+		cfg := &Config{API: apiCfg}
+		cfg.Check.InstanceID = "test:app"
+		cfg.Check.DisplayName = "test app"
+		cfg.Check.SearchTag = "service:test"
+		cfg.Check.Shards = "3"
+		cm, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(cm.Shards()) != 3 {
+			t.Fatalf("Expected 3 shards, got %d", len(cm.Shards()))
+		}
+		for i, shard := range cm.Shards() {
+			if string(shard.checkInstanceID) != fmt.Sprintf("test:app:shard%d", i+1) {
+				t.Fatalf("unexpected instance id (%s)", shard.checkInstanceID)
+			}
+			if string(shard.checkTarget) != "test:app" {
+				t.Fatalf("unexpected target (%s)", shard.checkTarget)
+			}
+			if string(shard.checkDisplayName) != fmt.Sprintf("test app (shard %d of 3)", i+1) {
+				t.Fatalf("unexpected display name (%s)", shard.checkDisplayName)
+			}
+			expectTags := fmt.Sprintf("service:test,cgm_shard:%d", i+1)
+			if strings.Join(shard.checkSearchTag, ",") != expectTags {
+				t.Fatalf("Expected %s, got %v", expectTags, shard.checkSearchTag)
+			}
+			if shard.Shards() != nil {
+				t.Fatal("Expected shard to not be sharded")
+			}
+		}
+
+		if _, err := cm.GetSubmissionURL(); err == nil {
+			t.Fatal("Expected error, sharded check has no single submission url")
+		}
+
+		newMetrics := map[string]*api.CheckBundleMetric{}
+		for i := 0; i < 30; i++ {
+			name := fmt.Sprintf("metric%d", i)
+			newMetrics[name] = &api.CheckBundleMetric{Name: name}
+		}
+		parts := cm.SplitMetrics(newMetrics)
+		if len(parts) != 3 {
+			t.Fatalf("Expected 3 parts, got %d", len(parts))
+		}
+		total := 0
+		for i, part := range parts {
+			for name := range part {
+				if cm.ShardIndex(name) != i {
+					t.Fatalf("Expected %s in shard %d", name, cm.ShardIndex(name))
+				}
+			}
+			total += len(part)
+		}
+		if total != 30 {
+			t.Fatalf("Expected 30 metrics, got %d", total)
+		}
+	}
+}
+
+func TestShardedCheck(t *testing.T) {
+	server := testCheckServer()
+	defer server.Close()
+
+	t.Log("Testing sharded check initialization and delegation")
+
+	testURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Error parsing temporary url %v", err)
+	}
+	hostParts := strings.Split(testURL.Host, ":")
+	hostPort, err := strconv.Atoi(hostParts[1])
+	if err != nil {
+		t.Fatalf("Error converting port to numeric %v", err)
+	}
+	testBroker.Details[0].ExternalHost = &hostParts[0]
+	testBroker.Details[0].ExternalPort = uint16(hostPort)
+
+	cfg := &Config{
+		API: api.Config{TokenKey: "1234", TokenApp: "abcd", URL: server.URL},
+	}
+	cfg.Check.InstanceID = "test:sharded"
+	cfg.Check.SearchTag = "foo:bar"
+	cfg.Check.Shards = "2"
+	cfg.Broker.MaxResponseTime = "50ms"
+
+	cm, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	cm.Initialize()
+
+	for i := 0; i < 100 && !cm.IsReady(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !cm.IsReady() {
+		t.Fatal("Expected sharded check to be ready")
+	}
+
+	for _, shard := range cm.Shards() {
+		if _, err := shard.GetSubmissionURL(); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	// "elmo" is active in the test check bundle, created for each shard
+	if !cm.IsMetricActive("elmo") {
+		t.Fatal("Expected elmo to be active")
+	}
+	if cm.ActivateMetric("elmo") {
+		t.Fatal("Expected elmo to not need activation")
+	}
+	if !cm.ActivateMetric("new`metric") {
+		t.Fatal("Expected new metric to need activation")
+	}
+
+	if !cm.AddMetricTags("foo", []string{"cat:tag"}, false) {
+		t.Fatal("Expected tags to be added")
+	}
+	if _, ok := cm.shardFor("foo").metricTags["foo"]; !ok {
+		t.Fatal("Expected tags to be queued on the shard for the metric")
+	}
+}
+
+func TestShardedOnReady(t *testing.T) {
+	t.Log("Testing OnReady for a sharded check")
+
+	calls := 0
+	cfg := &Config{
+		API: api.Config{TokenKey: "1234", TokenApp: "abcd", URL: "http://127.0.0.1:1"},
+	}
+	cfg.Check.Shards = "3"
+	cfg.OnReady = func() { calls++ }
+
+	cm, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	for i, shard := range cm.Shards() {
+		shard.onReady()
+		expect := 0
+		if i == len(cm.Shards())-1 {
+			expect = 1
+		}
+		if calls != expect {
+			t.Fatalf("Expected %d calls after shard %d ready, got %d", expect, i+1, calls)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checkmgr
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// Status of the check manager
+type Status struct {
+	// check has been initialized and metrics can be sent
+	Ready bool `json:"ready"`
+	// check bundle cid (e.g. /check_bundle/123), blank when check management is disabled
+	CheckBundleCID string `json:"check_bundle_cid,omitempty"`
+	// submission url with the secret redacted
+	TrapURL string `json:"trap_url,omitempty"`
+	// common name of the broker the check is on
+	BrokerCN string `json:"broker_cn,omitempty"`
+	// number of active and inactive (disabled in UI) metrics in the check bundle
+	ActiveMetrics   int `json:"active_metrics"`
+	InactiveMetrics int `json:"inactive_metrics"`
+	// status of each shard, when metrics are spread across multiple check bundles
+	Shards []Status `json:"shards,omitempty"`
+}
+
+// Status returns the current state of the check manager, trap details
+// are only available once the check has been initialized
+func (cm *CheckManager) Status() Status {
+	if len(cm.shards) > 0 {
+		s := Status{Ready: true, Shards: make([]Status, len(cm.shards))}
+		for i, shard := range cm.shards {
+			s.Shards[i] = shard.Status()
+			if !s.Shards[i].Ready {
+				s.Ready = false
+			}
+			s.ActiveMetrics += s.Shards[i].ActiveMetrics
+			s.InactiveMetrics += s.Shards[i].InactiveMetrics
+		}
+		return s
+	}
+
+	s := Status{Ready: cm.IsReady()}
+	if !s.Ready {
+		return s
+	}
+
+	cm.trapmu.Lock()
+	trapURL, trapCN := cm.trapURL, cm.trapCN
+	cm.trapmu.Unlock()
+
+	s.TrapURL = redactTrapURL(string(trapURL))
+	s.BrokerCN = string(trapCN)
+
+	cm.cbmu.Lock()
+	if cm.checkBundle != nil {
+		s.CheckBundleCID = cm.checkBundle.CID
+	}
+	cm.cbmu.Unlock()
+
+	cm.availableMetricsmu.Lock()
+	for _, active := range cm.availableMetrics {
+		if active {
+			s.ActiveMetrics++
+		} else {
+			s.InactiveMetrics++
+		}
+	}
+	cm.availableMetricsmu.Unlock()
+
+	return s
+}
+
+// redactTrapURL removes secrets from a submission url. The httptrap secret
+// is the last element of a broker trap url (/module/httptrap/<uuid>/<secret>),
+// user info and query parameters are removed from any url.
+func redactTrapURL(trapURL string) string {
+	if trapURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(trapURL)
+	if err != nil {
+		return redacted
+	}
+
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+
+	parts := strings.Split(strings.TrimSuffix(u.Path, "/"), "/")
+	if len(parts) == 5 && parts[1] == "module" && parts[2] == "httptrap" {
+		parts[4] = redacted
+		u.Path = strings.Join(parts, "/")
+		u.RawPath = ""
+	}
+
+	return u.String()
+}
@@ -36,13 +36,14 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/circonus-labs/circonus-gometrics/api"
 	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/circonus-labs/circonus-gometrics/logging"
 	"github.com/pkg/errors"
 )
 
@@ -52,8 +53,9 @@ const (
 
 // Metric defines an individual metric
 type Metric struct {
-	Type  string      `json:"_type"`
-	Value interface{} `json:"_value"`
+	Type      string      `json:"_type"`
+	Value     interface{} `json:"_value"`
+	Timestamp uint64      `json:"_ts,omitempty"` // milliseconds since epoch, default submission time
 }
 
 // Metrics holds host metrics
@@ -61,12 +63,69 @@ type Metrics map[string]Metric
 
 // Config options for circonus-gometrics
 type Config struct {
-	Log             *log.Logger
-	Debug           bool
-	ResetCounters   string // reset/delete counters on flush (default true)
-	ResetGauges     string // reset/delete gauges on flush (default true)
-	ResetHistograms string // reset/delete histograms on flush (default true)
-	ResetText       string // reset/delete text on flush (default true)
+	Log                 *log.Logger
+	Debug               bool
+	Logger              logging.Logger // leveled structured logger (default Log, debug messages only when Debug is true)
+	ResetCounters       string // reset/delete counters on flush (default true)
+	ResetUpDownCounters string // reset/delete up-down counters on flush (default false)
+	ResetGauges         string // reset/delete gauges on flush (default true)
+	ResetHistograms     string // reset/delete histograms on flush (default true)
+	ResetText           string // reset/delete text on flush (default true)
+	TimerUnits          string // units timers record durations in s|ms|us (default s)
+	TopKOutput          string // submit top-k metrics as counters|text (default counters)
+	HistogramEncoding   string // submit histograms as dec|b64 (default dec)
+
+	// timestamped metrics are grouped by their timestamp truncated to this
+	// resolution, one submission is made per group (default 1s)
+	TimestampResolution string
+
+	// compress submission payloads none|gzip|deflate (default none)
+	SubmitCompression string
+	// minimum payload size, in bytes, to compress (default 1024)
+	SubmitCompressionThreshold string
+
+	// maximum number of metrics in a single submission, larger
+	// metric sets are split into multiple submissions (default 10000)
+	SubmitMaxMetrics string
+	// maximum size, in bytes (before compression), of a single submission (default 4194304)
+	SubmitMaxBytes string
+	// maximum number of concurrent submissions when split (default 4)
+	SubmitWorkers string
+
+	// retry and timeout policy for metric submissions
+	SubmitPolicy SubmitPolicy
+
+	// additional destinations metrics are submitted to
+	Destinations []Destination
+
+	// record metrics about cgm itself (flush duration, submission
+	// latency, retries, etc.) "(true|false)" (default false)
+	SelfMetrics string
+	// prefix for self metric names (default cgm)
+	SelfMetricsPrefix string
+
+	// publish expvar variables as metrics at each flush "(true|false)" (default false)
+	Expvar string
+	// prefix for expvar metric names (default expvar)
+	ExpvarPrefix string
+	// regular expressions matched against expvar names (e.g. memstats`HeapAlloc),
+	// only matching include and not matching exclude are published (default all)
+	ExpvarInclude string
+	ExpvarExclude string
+
+	// push metrics to graphite at each flush, tcp://host:port or udp://host:port (default none)
+	GraphiteURL string
+	// push metrics to influxdb at each flush, http(s)://host:port/write?db=name (default none)
+	InfluxURL string
+
+	// Submission callbacks (optional), called synchronously from the
+	// goroutine submitting to the destination, they should not block.
+	// Check manager callbacks (e.g. OnReady) are in CheckManager.
+
+	// called after metrics have been submitted to a destination
+	OnSubmitSuccess func(result SubmitResult)
+	// called when metrics could not be submitted to a destination
+	OnSubmitError func(err error)
 
 	// API, Check and Broker configuration options
 	CheckManager checkmgr.Config
@@ -87,16 +146,54 @@ type CirconusMetrics struct {
 	Log   *log.Logger
 	Debug bool
 
-	resetCounters   bool
-	resetGauges     bool
-	resetHistograms bool
-	resetText       bool
-	flushInterval   time.Duration
-	flushing        bool
-	flushmu         sync.Mutex
-	packagingmu     sync.Mutex
-	check           *checkmgr.CheckManager
-	lastMetrics     *prevMetrics
+	logger logging.Logger
+
+	resetCounters       bool
+	resetUpDownCounters bool
+	resetGauges         bool
+	resetHistograms     bool
+	resetText           bool
+	flushInterval       time.Duration
+	flushStop           chan struct{}
+	flushLoopmu         sync.Mutex
+	timerUnits          time.Duration
+	topKAsText          bool
+	histogramB64        bool
+	timestampResolution time.Duration
+	compression         string
+	compressionMinSize  int
+	maxSubmitMetrics    int
+	maxSubmitBytes      int
+	submitWorkers       int
+	submitPolicy        *submitPolicy
+	settingsmu          sync.RWMutex // flushInterval, submitPolicy and Debug, see Reconfigure
+	self                *selfStats // nil when self metrics are disabled
+	selfMetricsPrefix   string
+	expvar              *expvarBridge
+	sinks               []pushSink
+	sinkQueues          []chan sinkBatch // one per sink, see queueSinks
+	sinksOnce           sync.Once
+	onSubmitSuccess     func(result SubmitResult)
+	onSubmitError       func(err error)
+	flushing            bool
+	flushmu             sync.Mutex
+	packagingmu         sync.Mutex
+	check               *checkmgr.CheckManager
+	lastMetrics         *prevMetrics
+
+	// queued annotations, see Annotate
+	annotations         chan *annotationJob
+	annotationsDone     chan struct{} // closed when the worker exits, see Close
+	annotationsOnce     sync.Once
+	annotationRetryWait time.Duration
+
+	// background workers are stopped, see Close
+	closed  bool
+	closemu sync.RWMutex
+
+	// submission destinations, primary is the check from Config.CheckManager
+	primary      *destination
+	destinations []*destination
 
 	counters map[string]uint64
 	cm       sync.Mutex
@@ -104,8 +201,15 @@ type CirconusMetrics struct {
 	counterFuncs map[string]func() uint64
 	cfm          sync.Mutex
 
-	gauges map[string]interface{}
-	gm     sync.Mutex
+	upDownCounters map[string]int64
+	udm            sync.Mutex
+
+	floatCounters map[string]float64
+	fcm           sync.Mutex
+
+	gauges    map[string]interface{}
+	gaugeAggs map[string]*gaugeAggregate
+	gm        sync.Mutex
 
 	gaugeFuncs map[string]func() int64
 	gfm        sync.Mutex
@@ -118,6 +222,18 @@ type CirconusMetrics struct {
 
 	textFuncs map[string]func() string
 	tfm       sync.Mutex
+
+	uniques map[string]*Unique
+	um      sync.Mutex
+
+	topKs map[string]*TopK
+	tkm   sync.Mutex
+
+	rules map[string]*ruleState
+	rlm   sync.Mutex
+
+	timestamped map[uint64]*timestampedMetrics
+	tsm         sync.Mutex
 }
 
 // NewCirconusMetrics returns a CirconusMetrics instance
@@ -133,14 +249,21 @@ func New(cfg *Config) (*CirconusMetrics, error) {
 	}
 
 	cm := &CirconusMetrics{
-		counters:     make(map[string]uint64),
-		counterFuncs: make(map[string]func() uint64),
-		gauges:       make(map[string]interface{}),
-		gaugeFuncs:   make(map[string]func() int64),
-		histograms:   make(map[string]*Histogram),
-		text:         make(map[string]string),
-		textFuncs:    make(map[string]func() string),
-		lastMetrics:  &prevMetrics{},
+		counters:       make(map[string]uint64),
+		counterFuncs:   make(map[string]func() uint64),
+		upDownCounters: make(map[string]int64),
+		floatCounters:  make(map[string]float64),
+		gauges:         make(map[string]interface{}),
+		gaugeAggs:      make(map[string]*gaugeAggregate),
+		gaugeFuncs:     make(map[string]func() int64),
+		histograms:     make(map[string]*Histogram),
+		text:           make(map[string]string),
+		textFuncs:      make(map[string]func() string),
+		uniques:        make(map[string]*Unique),
+		topKs:          make(map[string]*TopK),
+		rules:          make(map[string]*ruleState),
+		timestamped:    make(map[uint64]*timestampedMetrics),
+		lastMetrics:    &prevMetrics{},
 	}
 
 	// Logging
@@ -154,6 +277,11 @@ func New(cfg *Config) (*CirconusMetrics, error) {
 		if cm.Log == nil {
 			cm.Log = log.New(ioutil.Discard, "", log.LstdFlags)
 		}
+
+		cm.logger = cfg.Logger
+		if cm.logger == nil {
+			cm.logger = logging.NewStdLogger(cm.Log, cm.Debug)
+		}
 	}
 
 	// Flush Interval
@@ -181,6 +309,15 @@ func New(cfg *Config) (*CirconusMetrics, error) {
 		cm.resetCounters = setting
 	}
 
+	cm.resetUpDownCounters = false
+	if cfg.ResetUpDownCounters != "" {
+		setting, err := strconv.ParseBool(cfg.ResetUpDownCounters)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing reset up-down counters")
+		}
+		cm.resetUpDownCounters = setting
+	}
+
 	cm.resetGauges = true
 	if cfg.ResetGauges != "" {
 		setting, err := strconv.ParseBool(cfg.ResetGauges)
@@ -208,30 +345,248 @@ func New(cfg *Config) (*CirconusMetrics, error) {
 		cm.resetText = setting
 	}
 
+	// timer units
+	{
+		tu := defaultTimerUnits
+		if cfg.TimerUnits != "" {
+			tu = cfg.TimerUnits
+		}
+
+		units, err := parseTimerUnits(tu)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing timer units")
+		}
+		cm.timerUnits = units
+	}
+
+	// top-k output
+	{
+		tko := defaultTopKOutput
+		if cfg.TopKOutput != "" {
+			tko = cfg.TopKOutput
+		}
+
+		switch tko {
+		case topKOutputCounters:
+			cm.topKAsText = false
+		case topKOutputText:
+			cm.topKAsText = true
+		default:
+			return nil, errors.Errorf("parsing top-k output: invalid setting (%s)", tko)
+		}
+	}
+
+	// histogram encoding
+	{
+		he := defaultHistogramEncoding
+		if cfg.HistogramEncoding != "" {
+			he = cfg.HistogramEncoding
+		}
+
+		switch he {
+		case histogramEncodingDec:
+			cm.histogramB64 = false
+		case histogramEncodingB64:
+			cm.histogramB64 = true
+		default:
+			return nil, errors.Errorf("parsing histogram encoding: invalid setting (%s)", he)
+		}
+	}
+
+	// timestamp resolution
+	{
+		tr := defaultTimestampResolution
+		if cfg.TimestampResolution != "" {
+			tr = cfg.TimestampResolution
+		}
+
+		dur, err := time.ParseDuration(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing timestamp resolution")
+		}
+		if dur < time.Millisecond {
+			return nil, errors.Errorf("parsing timestamp resolution: must be >= 1ms (%s)", tr)
+		}
+		cm.timestampResolution = dur
+	}
+
+	// submission compression
+	{
+		sc := defaultSubmitCompression
+		if cfg.SubmitCompression != "" {
+			sc = cfg.SubmitCompression
+		}
+
+		switch sc {
+		case compressionNone, compressionGzip, compressionDeflate:
+			cm.compression = sc
+		default:
+			return nil, errors.Errorf("parsing submit compression: invalid setting (%s)", sc)
+		}
+
+		ct := defaultSubmitCompressionThreshold
+		if cfg.SubmitCompressionThreshold != "" {
+			ct = cfg.SubmitCompressionThreshold
+		}
+
+		size, err := strconv.Atoi(ct)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing submit compression threshold")
+		}
+		cm.compressionMinSize = size
+	}
+
+	// submission splitting
+	{
+		mm := defaultSubmitMaxMetrics
+		if cfg.SubmitMaxMetrics != "" {
+			mm = cfg.SubmitMaxMetrics
+		}
+		maxMetrics, err := strconv.Atoi(mm)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing submit max metrics")
+		}
+		cm.maxSubmitMetrics = maxMetrics
+
+		mb := defaultSubmitMaxBytes
+		if cfg.SubmitMaxBytes != "" {
+			mb = cfg.SubmitMaxBytes
+		}
+		maxBytes, err := strconv.Atoi(mb)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing submit max bytes")
+		}
+		cm.maxSubmitBytes = maxBytes
+
+		sw := defaultSubmitWorkers
+		if cfg.SubmitWorkers != "" {
+			sw = cfg.SubmitWorkers
+		}
+		workers, err := strconv.Atoi(sw)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing submit workers")
+		}
+		if workers < 1 {
+			return nil, errors.Errorf("parsing submit workers: invalid setting (%d)", workers)
+		}
+		cm.submitWorkers = workers
+	}
+
+	// self metrics
+	{
+		sm := defaultSelfMetrics
+		if cfg.SelfMetrics != "" {
+			sm = cfg.SelfMetrics
+		}
+		enabled, err := strconv.ParseBool(sm)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing self metrics")
+		}
+		if enabled {
+			cm.self = newSelfStats()
+		}
+
+		cm.selfMetricsPrefix = defaultSelfMetricsPrefix
+		if cfg.SelfMetricsPrefix != "" {
+			cm.selfMetricsPrefix = cfg.SelfMetricsPrefix
+		}
+	}
+
+	// expvar bridge
+	{
+		ev := defaultExpvar
+		if cfg.Expvar != "" {
+			ev = cfg.Expvar
+		}
+		enabled, err := strconv.ParseBool(ev)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing expvar")
+		}
+
+		if enabled {
+			prefix := defaultExpvarPrefix
+			if cfg.ExpvarPrefix != "" {
+				prefix = cfg.ExpvarPrefix
+			}
+			bridge, err := newExpvarBridge(prefix, cfg.ExpvarInclude, cfg.ExpvarExclude)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing expvar patterns")
+			}
+			cm.expvar = bridge
+		}
+	}
+
+	// push sinks
+	if cfg.GraphiteURL != "" {
+		sink, err := parseGraphiteURL(cfg.GraphiteURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing graphite url")
+		}
+		cm.sinks = append(cm.sinks, sink)
+	}
+	if cfg.InfluxURL != "" {
+		sink, err := parseInfluxURL(cfg.InfluxURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing influx url")
+		}
+		cm.sinks = append(cm.sinks, sink)
+	}
+
+	// submission callbacks
+	cm.onSubmitSuccess = cfg.OnSubmitSuccess
+	cm.onSubmitError = cfg.OnSubmitError
+
+	// submission retry and timeout policy
+	{
+		policy, err := newSubmitPolicy(cfg.SubmitPolicy, cm.flushInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing submit policy")
+		}
+		cm.submitPolicy = policy
+	}
+
 	// check manager
 	{
 		cfg.CheckManager.Debug = cm.Debug
 		cfg.CheckManager.Log = cm.Log
+		cfg.CheckManager.Logger = cm.logger
 
 		check, err := checkmgr.New(&cfg.CheckManager)
 		if err != nil {
 			return nil, errors.Wrap(err, "creating new check manager")
 		}
 		cm.check = check
+		cm.primary = newCheckDestination("", check)
+	}
+
+	// additional destinations
+	for i, dcfg := range cfg.Destinations {
+		if dcfg.Name == "" {
+			dcfg.Name = strconv.Itoa(i)
+		}
+		d, err := newDestination(dcfg, cm.Debug, cm.Log, cm.logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "creating destination %d", i)
+		}
+		cm.destinations = append(cm.destinations, d)
+	}
+
+	if cm.self != nil {
+		cm.primary.enableSelfStats()
+		for _, d := range cm.destinations {
+			d.enableSelfStats()
+		}
 	}
 
 	// start background initialization
 	cm.check.Initialize()
+	for _, d := range cm.destinations {
+		d.check.Initialize()
+	}
 
 	// if automatic flush is enabled, start it.
 	// NOTE: submit will jettison metrics until initialization has completed.
-	if cm.flushInterval > time.Duration(0) {
-		go func() {
-			for range time.NewTicker(cm.flushInterval).C {
-				cm.Flush()
-			}
-		}()
-	}
+	cm.startFlushLoop(cm.flushInterval)
 
 	return cm, nil
 }
@@ -241,89 +596,180 @@ func (m *CirconusMetrics) Start() {
 	// nop
 }
 
+// Close stops automatic flushes and the background workers (the annotation
+// and push sink workers). Annotations and sink pushes already queued are
+// still sent, call Flush and FlushAnnotations after Close to send the
+// remaining metrics (pushed to the sinks directly) and wait for the
+// annotations. Annotations queued after Close are dropped.
+func (m *CirconusMetrics) Close() {
+	m.closemu.Lock()
+	if m.closed {
+		m.closemu.Unlock()
+		return
+	}
+	m.closed = true
+	m.closeAnnotations()
+	m.closeSinks()
+	m.closemu.Unlock()
+
+	m.startFlushLoop(0)
+}
+
+// isClosed returns true once Close has been called
+func (m *CirconusMetrics) isClosed() bool {
+	m.closemu.RLock()
+	defer m.closemu.RUnlock()
+	return m.closed
+}
+
+// startFlushLoop (re)starts automatic flushes every interval, stopping
+// the previous flush loop. Automatic flushes are disabled when interval
+// is 0 or the instance has been closed.
+func (m *CirconusMetrics) startFlushLoop(interval time.Duration) {
+	m.flushLoopmu.Lock()
+	defer m.flushLoopmu.Unlock()
+
+	if m.flushStop != nil {
+		close(m.flushStop)
+		m.flushStop = nil
+	}
+
+	if interval <= time.Duration(0) || m.isClosed() {
+		return
+	}
+
+	stop := make(chan struct{})
+	m.flushStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Flush()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
 // Ready returns true or false indicating if the check is ready to accept metrics
 func (m *CirconusMetrics) Ready() bool {
 	return m.check.IsReady()
 }
 
-func (m *CirconusMetrics) packageMetrics() (map[string]*api.CheckBundleMetric, Metrics) {
+// getLogger returns the logger, falling back to Log when not initialized with New
+func (m *CirconusMetrics) getLogger() logging.Logger {
+	if m.logger != nil {
+		return m.logger
+	}
+	m.settingsmu.RLock()
+	defer m.settingsmu.RUnlock()
+	return logging.NewStdLogger(m.Log, m.Debug)
+}
+
+// packageMetrics returns all of the current metrics and those active (or to
+// be activated) in the primary check
+func (m *CirconusMetrics) packageMetrics() (Metrics, Metrics) {
 
 	m.packagingmu.Lock()
 	defer m.packagingmu.Unlock()
 
-	if m.Debug {
-		m.Log.Println("[DEBUG] Packaging metrics")
-	}
+	m.getLogger().Debug("packaging metrics")
+
+	start := time.Now()
+
+	m.collectExpvars()
 
 	counters, gauges, histograms, text := m.snapshot()
-	newMetrics := make(map[string]*api.CheckBundleMetric)
-	output := make(Metrics, len(counters)+len(gauges)+len(histograms)+len(text))
+	upDownCounters := m.snapUpDownCounters()
+	floatCounters := m.snapFloatCounters()
+	uniques := m.snapUniques()
+	topKs := m.snapTopKs()
+
+	if m.self != nil {
+		defer func() {
+			m.selfDuration(m.self, selfPackageDuration, time.Since(start))
+			m.selfGauge(m.self, selfPackagedMetrics+"counter", len(counters)+len(upDownCounters)+len(floatCounters))
+			m.selfGauge(m.self, selfPackagedMetrics+"gauge", len(gauges))
+			m.selfGauge(m.self, selfPackagedMetrics+"histogram", len(histograms))
+			m.selfGauge(m.self, selfPackagedMetrics+"text", len(text))
+			m.selfGauge(m.self, selfPackagedMetrics+"unique", len(uniques))
+			m.selfGauge(m.self, selfPackagedMetrics+"topk", len(topKs))
+		}()
+	}
+	output := make(Metrics, len(counters)+len(upDownCounters)+len(floatCounters)+len(gauges)+len(histograms)+len(text)+len(uniques)+len(topKs))
 	for name, value := range counters {
-		send := m.check.IsMetricActive(name)
-		if !send && m.check.ActivateMetric(name) {
-			send = true
-			newMetrics[name] = &api.CheckBundleMetric{
-				Name:   name,
-				Type:   "numeric",
-				Status: "active",
-			}
-		}
-		if send {
-			output[name] = Metric{Type: "L", Value: value}
-		}
+		output[name] = Metric{Type: "L", Value: value}
+	}
+
+	for name, value := range upDownCounters {
+		output[name] = Metric{Type: "l", Value: value}
+	}
+
+	for name, value := range floatCounters {
+		output[name] = Metric{Type: "n", Value: value}
 	}
 
 	for name, value := range gauges {
-		send := m.check.IsMetricActive(name)
-		if !send && m.check.ActivateMetric(name) {
-			send = true
-			newMetrics[name] = &api.CheckBundleMetric{
-				Name:   name,
-				Type:   "numeric",
-				Status: "active",
-			}
-		}
-		if send {
-			output[name] = Metric{Type: m.getGaugeType(value), Value: value}
-		}
+		output[name] = Metric{Type: m.getGaugeType(value), Value: value}
 	}
 
 	for name, value := range histograms {
-		send := m.check.IsMetricActive(name)
-		if !send && m.check.ActivateMetric(name) {
-			send = true
-			newMetrics[name] = &api.CheckBundleMetric{
-				Name:   name,
-				Type:   "histogram",
-				Status: "active",
-			}
-		}
-		if send {
-			output[name] = Metric{Type: "n", Value: value.DecStrings()}
+		metric, err := m.histogramMetric(value)
+		if err != nil {
+			m.getLogger().Warn("encoding histogram", "metric", name, "err", err)
+			continue
 		}
+		output[name] = metric
 	}
 
 	for name, value := range text {
-		send := m.check.IsMetricActive(name)
-		if !send && m.check.ActivateMetric(name) {
-			send = true
-			newMetrics[name] = &api.CheckBundleMetric{
-				Name:   name,
-				Type:   "text",
-				Status: "active",
+		output[name] = Metric{Type: "s", Value: value}
+	}
+
+	for name, value := range uniques {
+		output[name] = Metric{Type: "L", Value: value}
+	}
+
+	for name, items := range topKs {
+		if m.topKAsText {
+			value, err := topKText(items)
+			if err != nil {
+				m.getLogger().Warn("encoding top-k", "metric", name, "err", err)
+				continue
 			}
-		}
-		if send {
 			output[name] = Metric{Type: "s", Value: value}
+			continue
 		}
+
+		// named by rank so the number of metrics is bounded by k
+		for i, item := range items {
+			rankName := name + topKItemSeparator + strconv.Itoa(i+1)
+			output[rankName] = Metric{Type: "L", Value: item.Count}
+			itemName := rankName + topKItemSeparator + topKItemSuffix
+			output[itemName] = Metric{Type: "s", Value: item.Item}
+		}
+	}
+
+	// FlushMetrics and the text outputs (e.g. PromOutput) only include metrics
+	// active (or to be activated) in the primary check, each destination
+	// decides activation in its own check when submitting
+	active := output
+	if m.primary != nil {
+		active, _ = m.primary.activeMetrics(output)
 	}
 
 	m.lastMetrics.metricsmu.Lock()
-	defer m.lastMetrics.metricsmu.Unlock()
-	m.lastMetrics.metrics = &output
+	m.lastMetrics.metrics = &active
 	m.lastMetrics.ts = time.Now()
+	m.lastMetrics.metricsmu.Unlock()
+
+	m.evaluateRules(output, histograms)
 
-	return newMetrics, output
+	return output, active
 }
 
 // PromOutput returns lines of metrics in prom format
@@ -346,6 +792,8 @@ func (m *CirconusMetrics) PromOutput() (*bytes.Buffer, error) {
 			if strings.HasPrefix(fmt.Sprintf("%v", metric.Value), "[H[") {
 				continue // circonus histogram != prom "histogram" (aka percentile)
 			}
+		case "h":
+			continue // circonus histogram (b64 encoded)
 		case "s":
 			continue // text metrics unsupported
 		}
@@ -371,13 +819,13 @@ func (m *CirconusMetrics) FlushMetrics() *Metrics {
 	m.flushing = true
 	m.flushmu.Unlock()
 
-	_, output := m.packageMetrics()
+	_, active := m.packageMetrics()
 
 	m.flushmu.Lock()
 	m.flushing = false
 	m.flushmu.Unlock()
 
-	return &output
+	return &active
 }
 
 // Flush metrics kicks off the process of sending metrics to Circonus
@@ -385,22 +833,46 @@ func (m *CirconusMetrics) Flush() {
 	m.flushmu.Lock()
 	if m.flushing {
 		m.flushmu.Unlock()
+		m.selfCount(m.self, selfFlushesSkipped, 1)
 		return
 	}
 
 	m.flushing = true
 	m.flushmu.Unlock()
 
-	newMetrics, output := m.packageMetrics()
+	start := time.Now()
+
+	output, _ := m.packageMetrics()
+
+	if len(output) > 0 || m.self != nil {
+		m.submit(output, true)
+		m.pushSinks(output)
+	} else {
+		m.getLogger().Debug("no metrics to send, skipping")
+	}
 
-	if len(output) > 0 {
-		m.submit(output, newMetrics)
+	// timestamped metrics are sent as one submission per timestamp, oldest first,
+	// they are held (not flushed) until every destination check is initialized
+	var groups map[uint64]Metrics
+	if m.timestampedReady() {
+		groups = m.packageTimestampedMetrics()
 	} else {
-		if m.Debug {
-			m.Log.Println("[DEBUG] No metrics to send, skipping")
+		m.getLogger().Debug("check not initialized yet, holding timestamped metrics")
+	}
+	if len(groups) > 0 {
+		timestamps := make([]uint64, 0, len(groups))
+		for ts := range groups {
+			timestamps = append(timestamps, ts)
+		}
+		sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+		for _, ts := range timestamps {
+			m.submit(groups[ts], false)
+			m.pushSinks(groups[ts])
 		}
 	}
 
+	m.selfDuration(m.self, selfFlushDuration, time.Since(start))
+
 	m.flushmu.Lock()
 	m.flushing = false
 	m.flushmu.Unlock()
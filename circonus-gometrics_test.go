@@ -382,6 +382,37 @@ func TestFlush(t *testing.T) {
 	}
 }
 
+func TestClose(t *testing.T) {
+	t.Log("Testing Close")
+
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "10ms"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	cm.Close()
+	cm.Close()
+
+	cm.flushLoopmu.Lock()
+	stopped := cm.flushStop == nil
+	cm.flushLoopmu.Unlock()
+	if !stopped {
+		t.Fatal("Expected flush loop to be stopped")
+	}
+
+	cm.startFlushLoop(10 * time.Millisecond)
+	cm.flushLoopmu.Lock()
+	stopped = cm.flushStop == nil
+	cm.flushLoopmu.Unlock()
+	if !stopped {
+		t.Fatal("Expected flush loop not to restart after Close")
+	}
+}
+
 func TestPackageMetrics(t *testing.T) {
 	cfg := &Config{}
 	cfg.CheckManager.Check.SubmissionURL = "none"
@@ -395,11 +426,66 @@ func TestPackageMetrics(t *testing.T) {
 		}
 
 		cm.flushing = false
-		newMetrics, output := cm.packageMetrics()
-		if len(newMetrics) != 0 && len(output) != 0 {
+		output, active := cm.packageMetrics()
+		if len(output) != 0 || len(active) != 0 {
 			t.Fatal("expected 0 metrics")
 		}
 	}
+
+	t.Log("Metrics not active in the check")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/check/1234":
+				fmt.Fprintln(w, `{"_cid":"/check/1234","_active":true,"_check_bundle":"/check_bundle/1234","_broker":"/broker/1234"}`)
+			case "/check_bundle/1234":
+				fmt.Fprintln(w, `{"_cid":"/check_bundle/1234","type":"httptrap","status":"active","brokers":["/broker/1234"],`+
+					`"config":{"submission_url":"http://127.0.0.1:1/module/httptrap/1234/blah"},`+
+					`"metrics":[{"name":"foo","type":"numeric","status":"active"},{"name":"bar","type":"numeric","status":"available"}]}`)
+			case "/broker/1234":
+				fmt.Fprintln(w, `{"_cid":"/broker/1234","_details":[{"cn":"127.0.0.1","ipaddress":"127.0.0.1","status":"active"}]}`)
+			default:
+				w.WriteHeader(404)
+				fmt.Fprintf(w, "not found %s\n", r.URL.Path)
+			}
+		}))
+		defer server.Close()
+
+		acfg := &Config{}
+		acfg.Interval = "0"
+		acfg.CheckManager.API.TokenKey = "1234"
+		acfg.CheckManager.API.URL = server.URL
+		acfg.CheckManager.Check.ID = "1234"
+
+		cm, err := NewCirconusMetrics(acfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for !cm.check.IsReady() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cm.Set("foo", 1) // active
+		cm.Set("bar", 1) // disabled in the check
+		cm.Set("baz", 1) // new, to be activated
+
+		output, active := cm.packageMetrics()
+		if len(output) != 3 {
+			t.Fatalf("expected 3 metrics, got %v", output)
+		}
+		if _, ok := active["bar"]; ok || len(active) != 2 {
+			t.Fatalf("expected foo and baz, got %v", active)
+		}
+		if last := *cm.lastMetrics.metrics; len(last) != len(active) {
+			t.Fatalf("expected last metrics to be active metrics, got %v", last)
+		}
+
+		cm.Set("bar", 1)
+		if metrics := cm.FlushMetrics(); len(*metrics) != 0 {
+			t.Fatalf("expected 0 metrics, got %v", *metrics)
+		}
+	}
 }
 
 func TestFlushMetrics(t *testing.T) {
@@ -0,0 +1,61 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import "fmt"
+
+// A FloatCounter is a monotonically increasing 64-bit float.
+//
+// Use a float counter to accumulate fractional quantities (e.g., byte-seconds,
+// cost). Float counters are submitted as doubles and follow the same reset
+// semantics as counters (see Config.ResetCounters).
+
+// AddFloat updates a float counter by supplied value, negative (and NaN)
+// values are rejected as a float counter only increases
+func (m *CirconusMetrics) AddFloat(metric string, val float64) {
+	if !validFloat(val) {
+		m.getLogger().Warn("rejecting float counter value", "metric", metric, "value", val)
+		return
+	}
+	m.fcm.Lock()
+	defer m.fcm.Unlock()
+	m.floatCounters[metric] += val
+}
+
+// SetFloat sets a float counter to a specific value, negative (and NaN)
+// values are rejected as they are for AddFloat
+func (m *CirconusMetrics) SetFloat(metric string, val float64) {
+	if !validFloat(val) {
+		m.getLogger().Warn("rejecting float counter value", "metric", metric, "value", val)
+		return
+	}
+	m.fcm.Lock()
+	defer m.fcm.Unlock()
+	m.floatCounters[metric] = val
+}
+
+// RemoveFloat removes the named float counter
+func (m *CirconusMetrics) RemoveFloat(metric string) {
+	m.fcm.Lock()
+	defer m.fcm.Unlock()
+	delete(m.floatCounters, metric)
+}
+
+// validFloat reports whether val is usable as a float counter value
+func validFloat(val float64) bool {
+	return val >= 0 // false for NaN
+}
+
+// GetFloatTest returns the current value for a float counter. (note: it is a function specifically for "testing", disable automatic submission during testing.)
+func (m *CirconusMetrics) GetFloatTest(metric string) (float64, error) {
+	m.fcm.Lock()
+	defer m.fcm.Unlock()
+
+	if val, ok := m.floatCounters[metric]; ok {
+		return val, nil
+	}
+
+	return 0, fmt.Errorf("Float counter metric '%s' not found", metric)
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAddFloat(t *testing.T) {
+	t.Log("Testing counter_float.AddFloat")
+
+	cm := &CirconusMetrics{floatCounters: make(map[string]float64)}
+
+	cm.AddFloat("foo", 1.25)
+	cm.AddFloat("foo", 0.5)
+
+	val, err := cm.GetFloatTest("foo")
+	if err != nil {
+		t.Errorf("Expected no error %v", err)
+	}
+	if val != 1.75 {
+		t.Errorf("Expected 1.75, found %f", val)
+	}
+
+	t.Log("negative and NaN values are ignored")
+	{
+		cm.AddFloat("foo", -1)
+		cm.AddFloat("foo", math.NaN())
+
+		val, err := cm.GetFloatTest("foo")
+		if err != nil {
+			t.Errorf("Expected no error %v", err)
+		}
+		if val != 1.75 {
+			t.Errorf("Expected 1.75, found %f", val)
+		}
+
+		cm.AddFloat("baz", -1)
+		if _, ok := cm.floatCounters["baz"]; ok {
+			t.Error("Expected baz not to be created")
+		}
+	}
+
+	_, err = cm.GetFloatTest("bar")
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestSetFloat(t *testing.T) {
+	t.Log("Testing counter_float.SetFloat")
+
+	cm := &CirconusMetrics{floatCounters: make(map[string]float64)}
+
+	cm.AddFloat("foo", 1.25)
+	cm.SetFloat("foo", 0.5)
+
+	val, ok := cm.floatCounters["foo"]
+	if !ok {
+		t.Errorf("Expected to find foo")
+	}
+
+	if val != 0.5 {
+		t.Errorf("Expected 0.5, found %f", val)
+	}
+
+	t.Log("negative and NaN values are rejected")
+	{
+		cm.SetFloat("foo", -1)
+		cm.SetFloat("foo", math.NaN())
+
+		if val := cm.floatCounters["foo"]; val != 0.5 {
+			t.Errorf("Expected 0.5, found %f", val)
+		}
+
+		cm.SetFloat("baz", math.NaN())
+		if _, ok := cm.floatCounters["baz"]; ok {
+			t.Error("Expected baz not to be created")
+		}
+	}
+}
+
+func TestRemoveFloat(t *testing.T) {
+	t.Log("Testing counter_float.RemoveFloat")
+
+	cm := &CirconusMetrics{floatCounters: make(map[string]float64)}
+
+	cm.AddFloat("foo", 1)
+	cm.RemoveFloat("foo")
+
+	if _, ok := cm.floatCounters["foo"]; ok {
+		t.Errorf("Expected not to find foo")
+	}
+}
+
+func TestFlushFloat(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	cm.AddFloat("foo", 2.5)
+
+	metrics := cm.FlushMetrics()
+	if m, mok := (*metrics)["foo"]; !mok {
+		t.Fatalf("'foo' not found in %v", metrics)
+	} else if m.Type != "n" {
+		t.Fatalf("'Type' not correct %v", m)
+	} else if m.Value.(float64) != 2.5 {
+		t.Fatalf("'Value' not correct %v", m)
+	}
+
+	metrics = cm.FlushMetrics()
+	if _, mok := (*metrics)["foo"]; mok {
+		t.Fatalf("'foo' should have been reset %v", metrics)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import "fmt"
+
+// An UpDown counter is a signed, 64-bit integer which accepts positive
+// and negative deltas.
+//
+// Use an up-down counter to track a level which changes through increments
+// and decrements (e.g., active sessions, queue depth). Up-down counters are
+// submitted as signed 64-bit integers and, by default, retain their value
+// across flushes (see Config.ResetUpDownCounters).
+
+// IncrementUpDown increments an up-down counter by 1
+func (m *CirconusMetrics) IncrementUpDown(metric string) {
+	m.AddUpDown(metric, 1)
+}
+
+// DecrementUpDown decrements an up-down counter by 1
+func (m *CirconusMetrics) DecrementUpDown(metric string) {
+	m.AddUpDown(metric, -1)
+}
+
+// AddUpDown updates an up-down counter by supplied (signed) delta
+func (m *CirconusMetrics) AddUpDown(metric string, delta int64) {
+	m.udm.Lock()
+	defer m.udm.Unlock()
+	m.upDownCounters[metric] += delta
+}
+
+// SetUpDown sets an up-down counter to a specific value
+func (m *CirconusMetrics) SetUpDown(metric string, val int64) {
+	m.udm.Lock()
+	defer m.udm.Unlock()
+	m.upDownCounters[metric] = val
+}
+
+// RemoveUpDown removes the named up-down counter
+func (m *CirconusMetrics) RemoveUpDown(metric string) {
+	m.udm.Lock()
+	defer m.udm.Unlock()
+	delete(m.upDownCounters, metric)
+}
+
+// GetUpDownTest returns the current value for an up-down counter. (note: it is a function specifically for "testing", disable automatic submission during testing.)
+func (m *CirconusMetrics) GetUpDownTest(metric string) (int64, error) {
+	m.udm.Lock()
+	defer m.udm.Unlock()
+
+	if val, ok := m.upDownCounters[metric]; ok {
+		return val, nil
+	}
+
+	return 0, fmt.Errorf("UpDown counter metric '%s' not found", metric)
+}
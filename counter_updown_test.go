@@ -0,0 +1,144 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"testing"
+)
+
+func TestAddUpDown(t *testing.T) {
+	t.Log("Testing counter_updown.AddUpDown")
+
+	cm := &CirconusMetrics{upDownCounters: make(map[string]int64)}
+
+	cm.AddUpDown("foo", 5)
+	cm.AddUpDown("foo", -7)
+
+	val, ok := cm.upDownCounters["foo"]
+	if !ok {
+		t.Errorf("Expected to find foo")
+	}
+
+	if val != -2 {
+		t.Errorf("Expected -2, found %d", val)
+	}
+}
+
+func TestIncrementDecrementUpDown(t *testing.T) {
+	t.Log("Testing counter_updown.IncrementUpDown/DecrementUpDown")
+
+	cm := &CirconusMetrics{upDownCounters: make(map[string]int64)}
+
+	cm.IncrementUpDown("foo")
+	cm.IncrementUpDown("foo")
+	cm.DecrementUpDown("foo")
+
+	val, err := cm.GetUpDownTest("foo")
+	if err != nil {
+		t.Errorf("Expected no error %v", err)
+	}
+	if val != 1 {
+		t.Errorf("Expected 1, found %d", val)
+	}
+
+	_, err = cm.GetUpDownTest("bar")
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestSetUpDown(t *testing.T) {
+	t.Log("Testing counter_updown.SetUpDown")
+
+	cm := &CirconusMetrics{upDownCounters: make(map[string]int64)}
+
+	cm.SetUpDown("foo", -30)
+
+	val, ok := cm.upDownCounters["foo"]
+	if !ok {
+		t.Errorf("Expected to find foo")
+	}
+
+	if val != -30 {
+		t.Errorf("Expected -30, found %d", val)
+	}
+}
+
+func TestRemoveUpDown(t *testing.T) {
+	t.Log("Testing counter_updown.RemoveUpDown")
+
+	cm := &CirconusMetrics{upDownCounters: make(map[string]int64)}
+
+	cm.IncrementUpDown("foo")
+
+	if _, ok := cm.upDownCounters["foo"]; !ok {
+		t.Errorf("Expected to find foo")
+	}
+
+	cm.RemoveUpDown("foo")
+
+	if _, ok := cm.upDownCounters["foo"]; ok {
+		t.Errorf("Expected not to find foo")
+	}
+}
+
+func TestFlushUpDown(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	t.Log("retained by default")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.AddUpDown("foo", -3)
+
+		metrics := cm.FlushMetrics()
+		if m, mok := (*metrics)["foo"]; !mok {
+			t.Fatalf("'foo' not found in %v", metrics)
+		} else if m.Type != "l" {
+			t.Fatalf("'Type' not correct %v", m)
+		} else if m.Value.(int64) != -3 {
+			t.Fatalf("'Value' not correct %v", m)
+		}
+
+		cm.AddUpDown("foo", 1)
+
+		metrics = cm.FlushMetrics()
+		if m := (*metrics)["foo"]; m.Value.(int64) != -2 {
+			t.Fatalf("'Value' not correct %v", m)
+		}
+	}
+
+	t.Log("reset")
+	{
+		rcfg := *cfg
+		rcfg.ResetUpDownCounters = "true"
+		cm, err := NewCirconusMetrics(&rcfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.AddUpDown("foo", -3)
+		cm.FlushMetrics()
+
+		metrics := cm.FlushMetrics()
+		if _, mok := (*metrics)["foo"]; mok {
+			t.Fatalf("'foo' found in %v", metrics)
+		}
+	}
+
+	t.Log("bad reset setting")
+	{
+		rcfg := *cfg
+		rcfg.ResetUpDownCounters = "yes"
+		if _, err := NewCirconusMetrics(&rcfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/circonus-labs/circonus-gometrics/logging"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/tv42/httpunix"
+)
+
+// Destinations allow the same metrics to be submitted to several places at
+// once (e.g., two Circonus accounts during a migration and a local
+// circonus-agent). Each destination has its own check manager, submission
+// client and, optionally, a metric filter. Metrics are activated in (and
+// only active metrics are sent to) each destination check independently.
+// Destinations are submitted to concurrently with independent readiness,
+// retries and error reporting so one dead destination does not block the
+// others.
+
+// Destination defines an additional place metrics are submitted to
+type Destination struct {
+	// name used to identify the destination in log messages (default position in Config.Destinations)
+	Name string
+
+	// static submission url (e.g. a circonus-agent), shortcut for
+	// setting CheckManager.Check.SubmissionURL
+	SubmissionURL string
+
+	// API, Check and Broker configuration options for the destination
+	CheckManager checkmgr.Config
+
+	// regular expression, only metrics with names matching are
+	// submitted to the destination (default all metrics)
+	MetricFilter string
+}
+
+// destination submission state
+type destination struct {
+	name     string
+	check    *checkmgr.CheckManager
+	filter   *regexp.Regexp
+	filtermu sync.RWMutex
+
+	// long-lived submission client, see trapHTTPClient
+	trapClient      *http.Client
+	trapRetryClient *retryablehttp.Client
+	trapClientURL   string
+	trapClientTLS   *tls.Config
+	trapClientSock  *httpunix.Transport
+	trapClientmu    sync.Mutex
+
+	// result of the most recent submissions, see Status
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+	wasReady    bool // check has been ready (initialized) at least once
+	statusmu    sync.Mutex
+
+	// one per check shard, when the check is sharded (see checkmgr.CheckConfig.Shards)
+	shards []*destination
+
+	// self metrics for submissions to the destination, nil when disabled
+	self *selfStats
+}
+
+// newCheckDestination returns a destination for a check manager, with
+// a destination for each shard of a sharded check
+func newCheckDestination(name string, check *checkmgr.CheckManager) *destination {
+	d := &destination{name: name, check: check}
+
+	for i, shard := range check.Shards() {
+		shardName := fmt.Sprintf("shard %d", i+1)
+		if name != "" {
+			shardName = name + " " + shardName
+		}
+		d.shards = append(d.shards, &destination{name: shardName, check: shard})
+	}
+
+	return d
+}
+
+// newDestination creates a destination and its check manager, the
+// check manager is not initialized
+func newDestination(cfg Destination, debug bool, stdLog *log.Logger, logger logging.Logger) (*destination, error) {
+	var filter *regexp.Regexp
+
+	if cfg.MetricFilter != "" {
+		rx, err := regexp.Compile(cfg.MetricFilter)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing metric filter")
+		}
+		filter = rx
+	}
+
+	if cfg.SubmissionURL != "" {
+		cfg.CheckManager.Check.SubmissionURL = cfg.SubmissionURL
+	}
+
+	cfg.CheckManager.Debug = debug
+	cfg.CheckManager.Log = stdLog
+	cfg.CheckManager.Logger = logger
+
+	check, err := checkmgr.New(&cfg.CheckManager)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating new check manager")
+	}
+	d := newCheckDestination(cfg.Name, check)
+	d.filter = filter
+
+	return d, nil
+}
+
+// enableSelfStats records self metrics for submissions to the destination,
+// shards record to the destination's self metrics
+func (d *destination) enableSelfStats() {
+	d.self = newSelfStats()
+	for _, shard := range d.shards {
+		shard.self = d.self
+	}
+}
+
+// logger returns l with the destination identified in every message,
+// messages for the primary destination are not annotated
+func (d *destination) logger(l logging.Logger) logging.Logger {
+	if d.name == "" {
+		return l
+	}
+	return logging.With(l, "destination", d.name)
+}
+
+// recordSubmit records the result of a submission
+func (d *destination) recordSubmit(err error) {
+	d.statusmu.Lock()
+	defer d.statusmu.Unlock()
+
+	if err != nil {
+		d.lastFailure = time.Now()
+		d.lastError = err.Error()
+		return
+	}
+	d.lastSuccess = time.Now()
+}
+
+// checkReady returns whether the destination check is ready and whether
+// it has been ready before (i.e. not ready is a failure, rather than the
+// check not being initialized yet)
+func (d *destination) checkReady() (bool, bool) {
+	ready := d.check.IsReady()
+
+	d.statusmu.Lock()
+	defer d.statusmu.Unlock()
+
+	wasReady := d.wasReady
+	if ready {
+		d.wasReady = true
+	}
+
+	return ready, wasReady
+}
+
+// splitMetrics partitions metrics by check shard
+func (d *destination) splitMetrics(output Metrics) []Metrics {
+	outputs := make([]Metrics, len(d.shards))
+	for i := range outputs {
+		outputs[i] = make(Metrics)
+	}
+	for name, metric := range output {
+		outputs[d.check.ShardIndex(name)][name] = metric
+	}
+
+	return outputs
+}
+
+// activeMetrics returns the metrics active in the destination check and
+// those activated by this submission, which are to be added to the check
+func (d *destination) activeMetrics(output Metrics) (Metrics, map[string]*api.CheckBundleMetric) {
+	newMetrics := make(map[string]*api.CheckBundleMetric)
+	active := output
+	copied := false
+
+	for name, metric := range output {
+		if d.check.IsMetricActive(name) {
+			continue
+		}
+		if d.check.ActivateMetric(name) {
+			newMetrics[name] = &api.CheckBundleMetric{
+				Name:   name,
+				Type:   checkMetricType(metric),
+				Status: "active",
+			}
+			continue
+		}
+		if !copied {
+			active = make(Metrics, len(output))
+			for n, m := range output {
+				active[n] = m
+			}
+			copied = true
+		}
+		delete(active, name)
+	}
+
+	return active, newMetrics
+}
+
+// checkMetricType returns the check bundle metric type for a metric
+func checkMetricType(metric Metric) string {
+	switch metric.Type {
+	case "s":
+		return "text"
+	case "h":
+		return "histogram"
+	}
+	if _, ok := metric.Value.([]string); ok {
+		return "histogram" // dec encoded
+	}
+	return "numeric"
+}
+
+// getFilter returns the metric filter, nil when all metrics are submitted
+func (d *destination) getFilter() *regexp.Regexp {
+	d.filtermu.RLock()
+	defer d.filtermu.RUnlock()
+	return d.filter
+}
+
+// setFilter replaces the metric filter, see CirconusMetrics.Reconfigure
+func (d *destination) setFilter(filter *regexp.Regexp) {
+	d.filtermu.Lock()
+	d.filter = filter
+	d.filtermu.Unlock()
+}
+
+// resetTrapClient discards the submission client (and those of shards), a
+// new client is built for the next submission. Requests in progress complete
+// with the previous client.
+func (d *destination) resetTrapClient() {
+	d.trapClientmu.Lock()
+	if d.trapClient != nil {
+		if t, ok := d.trapClient.Transport.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+		d.trapClient = nil
+		d.trapRetryClient = nil
+	}
+	d.trapClientmu.Unlock()
+
+	for _, shard := range d.shards {
+		shard.resetTrapClient()
+	}
+}
+
+// filterMetrics returns the metrics to submit to the destination
+func (d *destination) filterMetrics(output Metrics) Metrics {
+	filter := d.getFilter()
+	if filter == nil {
+		return output
+	}
+
+	filtered := make(Metrics)
+	for name, metric := range output {
+		if filter.MatchString(name) {
+			filtered[name] = metric
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,277 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/circonus-labs/circonus-gometrics/logging"
+)
+
+// destinationLog returns a message as logged by a destination's logger
+func destinationLog(d *destination) string {
+	var buf bytes.Buffer
+	d.logger(logging.NewStdLogger(log.New(&buf, "", 0), false)).Info("test")
+	return strings.TrimSpace(buf.String())
+}
+
+// recordingBroker returns a broker which records the metric names received
+func recordingBroker() (*httptest.Server, func() map[string]bool) {
+	var mu sync.Mutex
+	received := make(map[string]bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var metrics Metrics
+		if err := json.Unmarshal(body, &metrics); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		for name := range metrics {
+			received[name] = true
+		}
+		mu.Unlock()
+		w.WriteHeader(200)
+		fmt.Fprintf(w, `{"stats":%d}`, len(metrics))
+	}))
+
+	return server, func() map[string]bool {
+		mu.Lock()
+		defer mu.Unlock()
+		r := make(map[string]bool, len(received))
+		for k, v := range received {
+			r[k] = v
+		}
+		return r
+	}
+}
+
+func TestNewDestination(t *testing.T) {
+	t.Log("Testing destination.newDestination")
+
+	t.Log("invalid filter")
+	{
+		_, err := newDestination(Destination{SubmissionURL: "http://127.0.0.1/", MetricFilter: "["}, false, nil, nil)
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("static submission url")
+	{
+		d, err := newDestination(Destination{Name: "agent", SubmissionURL: "http://127.0.0.1:2609/write/test"}, false, nil, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		d.check.Initialize()
+		trap, err := d.check.GetSubmissionURL()
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if trap.URL.String() != "http://127.0.0.1:2609/write/test" {
+			t.Fatalf("unexpected url (%s)", trap.URL.String())
+		}
+		if msg := destinationLog(d); msg != "[INFO] test destination=agent" {
+			t.Fatalf("unexpected log message (%s)", msg)
+		}
+	}
+
+	t.Log("no check manager config")
+	{
+		if _, err := newDestination(Destination{}, false, nil, nil); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
+
+func TestDestinationFilter(t *testing.T) {
+	t.Log("Testing destination.filterMetrics")
+
+	d, err := newDestination(Destination{SubmissionURL: "http://127.0.0.1/", MetricFilter: "^foo"}, false, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	output := Metrics{"foo": Metric{Type: "n", Value: 1}, "foo`bar": Metric{Type: "n", Value: 1}, "bar": Metric{Type: "n", Value: 1}}
+	filtered := d.filterMetrics(output)
+	if len(filtered) != 2 {
+		t.Fatalf("Expected 2 metrics, got %d", len(filtered))
+	}
+	if _, ok := filtered["bar"]; ok {
+		t.Fatal("Expected bar to be filtered")
+	}
+
+	unfiltered, err := newDestination(Destination{SubmissionURL: "http://127.0.0.1/"}, false, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(unfiltered.filterMetrics(output)) != 3 {
+		t.Fatal("Expected all metrics")
+	}
+}
+
+func TestDestinationActiveMetrics(t *testing.T) {
+	t.Log("Testing destination.activeMetrics")
+
+	d, err := newDestination(Destination{SubmissionURL: "http://127.0.0.1/"}, false, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	output := Metrics{
+		"counter": {Type: "L", Value: uint64(1)},
+		"text":    {Type: "s", Value: "foo"},
+		"hist":    {Type: "n", Value: []string{"H[1.0e+00]=1"}},
+		"hist64":  {Type: "h", Value: "AAEKAAAB"},
+	}
+
+	active, newMetrics := d.activeMetrics(output)
+	if len(active) != len(output) {
+		t.Fatalf("Expected %d active metrics, got %d", len(output), len(active))
+	}
+
+	expect := map[string]string{"counter": "numeric", "text": "text", "hist": "histogram", "hist64": "histogram"}
+	if len(newMetrics) != len(expect) {
+		t.Fatalf("Expected %d new metrics, got %v", len(expect), newMetrics)
+	}
+	for name, metricType := range expect {
+		if m, ok := newMetrics[name]; !ok || m.Type != metricType || m.Status != "active" {
+			t.Fatalf("Expected %s to be activated as %s, got %+v", name, metricType, m)
+		}
+	}
+}
+
+func TestFlushDestinations(t *testing.T) {
+	t.Log("Testing flush to multiple destinations")
+
+	primary, primaryReceived := recordingBroker()
+	defer primary.Close()
+
+	agent, agentReceived := recordingBroker()
+	defer agent.Close()
+
+	// a destination which never responds in time
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+	}))
+	defer dead.Close()
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = primary.URL
+	cfg.SubmitPolicy.Timeout = "500ms"
+	cfg.Destinations = []Destination{
+		{Name: "agent", SubmissionURL: agent.URL, MetricFilter: "^foo"},
+		{Name: "dead", SubmissionURL: dead.URL},
+	}
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(cm.destinations) != 2 {
+		t.Fatalf("Expected 2 destinations, got %d", len(cm.destinations))
+	}
+
+	for !cm.check.IsReady() || !cm.destinations[0].check.IsReady() || !cm.destinations[1].check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cm.Increment("foo")
+	cm.Increment("bar")
+
+	start := time.Now()
+	cm.Flush()
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Fatalf("Expected dead destination to be bounded by the submit timeout, took %s", elapsed)
+	}
+
+	p := primaryReceived()
+	if !p["foo"] || !p["bar"] {
+		t.Fatalf("Expected primary to receive foo and bar, got %v", p)
+	}
+
+	a := agentReceived()
+	if !a["foo"] || a["bar"] {
+		t.Fatalf("Expected agent to receive only foo, got %v", a)
+	}
+}
+
+func TestNewDestinations(t *testing.T) {
+	t.Log("Testing New with invalid destination")
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Destinations = []Destination{{SubmissionURL: "http://127.0.0.1/", MetricFilter: "["}}
+
+	if _, err := NewCirconusMetrics(cfg); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestShardedDestination(t *testing.T) {
+	t.Log("Testing destination for a sharded check")
+
+	cfg := &checkmgr.Config{}
+	cfg.API.TokenKey = "1234"
+	cfg.API.TokenApp = "abcd"
+	cfg.API.URL = "http://127.0.0.1:1"
+	cfg.Check.Shards = "3"
+
+	check, err := checkmgr.New(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	d := newCheckDestination("", check)
+	if len(d.shards) != 3 {
+		t.Fatalf("Expected 3 shard destinations, got %d", len(d.shards))
+	}
+	if msg := destinationLog(d); msg != "[INFO] test" {
+		t.Fatalf("unexpected log message (%s)", msg)
+	}
+	if msg := destinationLog(d.shards[1]); msg != `[INFO] test destination="shard 2"` {
+		t.Fatalf("unexpected log message (%s)", msg)
+	}
+
+	output := testChunkMetrics(60)
+
+	outputs := d.splitMetrics(output)
+	if len(outputs) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(outputs))
+	}
+	total := 0
+	for i, part := range outputs {
+		if len(part) == 0 {
+			t.Fatalf("Expected metrics in shard %d", i)
+		}
+		for name := range part {
+			if check.ShardIndex(name) != i {
+				t.Fatalf("Expected %s in shard %d", name, check.ShardIndex(name))
+			}
+		}
+		total += len(part)
+	}
+	if total != 60 {
+		t.Fatalf("Expected 60 metrics, got %d", total)
+	}
+	named := newCheckDestination("acct2", check)
+	if msg := destinationLog(named.shards[0]); msg != `[INFO] test destination="acct2 shard 1"` {
+		t.Fatalf("unexpected log message (%s)", msg)
+	}
+}
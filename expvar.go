@@ -0,0 +1,148 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"regexp"
+)
+
+// The expvar bridge publishes variables from the expvar package as metrics,
+// collected each time metrics are packaged (at flush). Variables are named
+// <prefix>`<name>, maps and json values are flattened with a backtick
+// between each level (e.g. expvar`memstats`HeapAlloc).
+//
+//   *expvar.Int, *expvar.Float and json numbers are gauges
+//   *expvar.String and json strings are text
+//   json booleans are gauges (0 or 1)
+//   *expvar.Map and json objects are flattened
+//
+// json arrays and nulls are skipped.
+
+const (
+	defaultExpvar       = "false"
+	defaultExpvarPrefix = "expvar"
+)
+
+// expvarBridge settings, see Config.Expvar
+type expvarBridge struct {
+	prefix  string
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// newExpvarBridge returns the expvar bridge settings
+func newExpvarBridge(prefix, include, exclude string) (*expvarBridge, error) {
+	b := &expvarBridge{prefix: prefix}
+
+	if include != "" {
+		rx, err := regexp.Compile(include)
+		if err != nil {
+			return nil, err
+		}
+		b.include = rx
+	}
+
+	if exclude != "" {
+		rx, err := regexp.Compile(exclude)
+		if err != nil {
+			return nil, err
+		}
+		b.exclude = rx
+	}
+
+	return b, nil
+}
+
+// collectExpvars records the current value of every published expvar
+func (m *CirconusMetrics) collectExpvars() {
+	if m.expvar == nil {
+		return
+	}
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		m.expvar.collect(m, kv.Key, kv.Value)
+	})
+}
+
+// collect records a variable, name is without the prefix
+func (b *expvarBridge) collect(m *CirconusMetrics, name string, v expvar.Var) {
+	switch t := v.(type) {
+	case *expvar.Int:
+		if b.match(name) {
+			m.Gauge(b.metricName(name), t.Value())
+		}
+	case *expvar.Float:
+		if b.match(name) {
+			m.Gauge(b.metricName(name), t.Value())
+		}
+	case *expvar.String:
+		if b.match(name) {
+			m.SetText(b.metricName(name), t.Value())
+		}
+	case *expvar.Map:
+		t.Do(func(kv expvar.KeyValue) {
+			b.collect(m, name+"`"+kv.Key, kv.Value)
+		})
+	default:
+		dec := json.NewDecoder(bytes.NewBufferString(v.String()))
+		dec.UseNumber()
+		var val interface{}
+		if err := dec.Decode(&val); err != nil {
+			m.getLogger().Debug("skipping expvar, invalid json", "name", name, "err", err)
+			return
+		}
+		b.collectJSON(m, name, val)
+	}
+}
+
+// collectJSON records a decoded json value, objects are flattened
+func (b *expvarBridge) collectJSON(m *CirconusMetrics, name string, val interface{}) {
+	if obj, ok := val.(map[string]interface{}); ok {
+		for key, v := range obj {
+			b.collectJSON(m, name+"`"+key, v)
+		}
+		return
+	}
+
+	if !b.match(name) {
+		return
+	}
+
+	switch t := val.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			m.Gauge(b.metricName(name), i)
+		} else if f, err := t.Float64(); err == nil {
+			m.Gauge(b.metricName(name), f)
+		}
+	case string:
+		m.SetText(b.metricName(name), t)
+	case bool:
+		if t {
+			m.Gauge(b.metricName(name), 1)
+		} else {
+			m.Gauge(b.metricName(name), 0)
+		}
+	}
+}
+
+// match returns true if the variable should be recorded
+func (b *expvarBridge) match(name string) bool {
+	if b.include != nil && !b.include.MatchString(name) {
+		return false
+	}
+	if b.exclude != nil && b.exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// metricName returns the full metric name for a variable
+func (b *expvarBridge) metricName(name string) string {
+	return b.prefix + "`" + name
+}
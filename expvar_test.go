@@ -0,0 +1,96 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"expvar"
+	"testing"
+)
+
+func init() {
+	expvar.NewInt("cgmtest_int").Set(42)
+	expvar.NewFloat("cgmtest_float").Set(1.5)
+	expvar.NewString("cgmtest_string").Set("hello")
+
+	mv := expvar.NewMap("cgmtest_map")
+	mv.Add("requests", 3)
+	mv.Add("skip_me", 1)
+	inner := new(expvar.Map).Init()
+	inner.AddFloat("ratio", 0.25)
+	mv.Set("inner", inner)
+
+	expvar.Publish("cgmtest_func", expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"version": "1.2.3",
+			"up":      true,
+			"stats":   map[string]interface{}{"alloc": 1024, "load": 0.5},
+			"list":    []int{1, 2, 3},
+			"none":    nil,
+		}
+	}))
+}
+
+func TestExpvar(t *testing.T) {
+	t.Log("Testing expvar bridge")
+
+	t.Log("invalid pattern")
+	{
+		cfg := &Config{Interval: "0", Expvar: "true", ExpvarInclude: "^(cgm"}
+		cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+		if _, err := New(cfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("disabled")
+	{
+		cfg := &Config{Interval: "0"}
+		cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+		m, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if metrics := m.FlushMetrics(); len(*metrics) != 0 {
+			t.Fatalf("Expected no metrics, got %v", *metrics)
+		}
+	}
+
+	t.Log("enabled")
+	{
+		cfg := &Config{Interval: "0", Expvar: "true", ExpvarInclude: "^cgmtest_", ExpvarExclude: "skip"}
+		cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+		m, err := New(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		metrics := *m.FlushMetrics()
+
+		expect := map[string]Metric{
+			"expvar`cgmtest_int":              {Type: "l", Value: int64(42)},
+			"expvar`cgmtest_float":            {Type: "n", Value: 1.5},
+			"expvar`cgmtest_string":           {Type: "s", Value: "hello"},
+			"expvar`cgmtest_map`requests":     {Type: "l", Value: int64(3)},
+			"expvar`cgmtest_map`inner`ratio":  {Type: "n", Value: 0.25},
+			"expvar`cgmtest_func`version":     {Type: "s", Value: "1.2.3"},
+			"expvar`cgmtest_func`up":          {Type: "i", Value: 1},
+			"expvar`cgmtest_func`stats`alloc": {Type: "l", Value: int64(1024)},
+			"expvar`cgmtest_func`stats`load":  {Type: "n", Value: 0.5},
+		}
+
+		if len(metrics) != len(expect) {
+			t.Fatalf("Expected %d metrics, got %d (%v)", len(expect), len(metrics), metrics)
+		}
+		for name, want := range expect {
+			have, ok := metrics[name]
+			if !ok {
+				t.Fatalf("Expected metric %s, got %v", name, metrics)
+			}
+			if have.Type != want.Type || have.Value != want.Value {
+				t.Fatalf("Expected %s to be %+v, got %+v", name, want, have)
+			}
+		}
+	}
+}
@@ -23,6 +23,7 @@ func (m *CirconusMetrics) SetGauge(metric string, val interface{}) {
 	m.gm.Lock()
 	defer m.gm.Unlock()
 	m.gauges[metric] = val
+	m.aggregateGauge(metric)
 }
 
 // AddGauge adds value to existing gauge
@@ -30,6 +31,8 @@ func (m *CirconusMetrics) AddGauge(metric string, val interface{}) {
 	m.gm.Lock()
 	defer m.gm.Unlock()
 
+	defer m.aggregateGauge(metric)
+
 	v, ok := m.gauges[metric]
 	if !ok {
 		m.gauges[metric] = val
@@ -0,0 +1,152 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Gauge aggregation controls how the values a gauge is set to within a
+// flush interval are represented when the gauge is submitted.
+//
+// By default only the last value set survives to the flush. Setting an
+// aggregation mode on a gauge retains the min, max, sum and count of the
+// values set during the interval so that spikes between flushes are not
+// lost. Aggregated gauges are submitted as doubles.
+
+// GaugeAggregation defines how gauge values are aggregated within a flush interval
+type GaugeAggregation string
+
+// Gauge aggregation modes
+const (
+	GaugeAggregationLast GaugeAggregation = "last" // last value set (default)
+	GaugeAggregationMin  GaugeAggregation = "min"  // minimum value set
+	GaugeAggregationMax  GaugeAggregation = "max"  // maximum value set
+	GaugeAggregationMean GaugeAggregation = "mean" // mean of values set
+	GaugeAggregationSum  GaugeAggregation = "sum"  // sum of values set
+	GaugeAggregationAll  GaugeAggregation = "all"  // all of the above, as <name>`<mode> metrics
+)
+
+// gaugeAggregationSuffixes are the modes emitted for GaugeAggregationAll
+var gaugeAggregationSuffixes = []GaugeAggregation{
+	GaugeAggregationLast,
+	GaugeAggregationMin,
+	GaugeAggregationMax,
+	GaugeAggregationMean,
+	GaugeAggregationSum,
+}
+
+type gaugeAggregate struct {
+	mode  GaugeAggregation
+	last  float64
+	min   float64
+	max   float64
+	sum   float64
+	count uint64
+}
+
+// SetGaugeAggregation sets the aggregation mode for a gauge
+func (m *CirconusMetrics) SetGaugeAggregation(metric string, mode GaugeAggregation) error {
+	switch mode {
+	case GaugeAggregationLast, GaugeAggregationMin, GaugeAggregationMax,
+		GaugeAggregationMean, GaugeAggregationSum, GaugeAggregationAll:
+	default:
+		return errors.Errorf("invalid gauge aggregation mode (%s)", mode)
+	}
+
+	m.gm.Lock()
+	defer m.gm.Unlock()
+
+	if m.gaugeAggs == nil {
+		m.gaugeAggs = make(map[string]*gaugeAggregate)
+	}
+
+	if mode == GaugeAggregationLast {
+		delete(m.gaugeAggs, metric)
+		return nil
+	}
+
+	agg := &gaugeAggregate{mode: mode}
+	if v, ok := m.gauges[metric]; ok {
+		if f, ok := toFloat64(v); ok {
+			agg.record(f)
+		}
+	}
+	m.gaugeAggs[metric] = agg
+
+	return nil
+}
+
+// RemoveGaugeAggregation reverts a gauge to the default (last) aggregation mode
+func (m *CirconusMetrics) RemoveGaugeAggregation(metric string) {
+	m.gm.Lock()
+	defer m.gm.Unlock()
+	delete(m.gaugeAggs, metric)
+}
+
+// aggregateGauge records the current value of a gauge if it has an
+// aggregation mode, caller must hold the gauge lock
+func (m *CirconusMetrics) aggregateGauge(metric string) {
+	agg, ok := m.gaugeAggs[metric]
+	if !ok {
+		return
+	}
+	if f, ok := toFloat64(m.gauges[metric]); ok {
+		agg.record(f)
+	}
+}
+
+// record adds a value to the aggregate
+func (a *gaugeAggregate) record(v float64) {
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.last = v
+	a.sum += v
+	a.count++
+}
+
+// value returns the aggregated value for a mode
+func (a *gaugeAggregate) value(mode GaugeAggregation) float64 {
+	switch mode {
+	case GaugeAggregationMin:
+		return a.min
+	case GaugeAggregationMax:
+		return a.max
+	case GaugeAggregationMean:
+		return a.sum / float64(a.count)
+	case GaugeAggregationSum:
+		return a.sum
+	}
+	return a.last
+}
+
+// snapshot adds the aggregated value(s) of the gauge to g and resets the
+// aggregate for the next interval. If the gauge was not set during the
+// interval (e.g. retained because gauges are not reset) the current value
+// of the gauge is used.
+func (a *gaugeAggregate) snapshot(metric string, current interface{}, g map[string]interface{}) {
+	if a.count == 0 {
+		f, ok := toFloat64(current)
+		if !ok {
+			g[metric] = current
+			return
+		}
+		a.record(f)
+	}
+
+	if a.mode == GaugeAggregationAll {
+		for _, mode := range gaugeAggregationSuffixes {
+			g[metric+"`"+string(mode)] = a.value(mode)
+		}
+	} else {
+		g[metric] = a.value(a.mode)
+	}
+
+	*a = gaugeAggregate{mode: a.mode}
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"testing"
+)
+
+func TestSetGaugeAggregation(t *testing.T) {
+	t.Log("Testing gauge_aggregation.SetGaugeAggregation")
+
+	cm := &CirconusMetrics{gauges: make(map[string]interface{})}
+
+	if err := cm.SetGaugeAggregation("foo", "median"); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	if err := cm.SetGaugeAggregation("foo", GaugeAggregationMax); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := cm.gaugeAggs["foo"]; !ok {
+		t.Fatal("Expected to find foo")
+	}
+
+	if err := cm.SetGaugeAggregation("foo", GaugeAggregationLast); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := cm.gaugeAggs["foo"]; ok {
+		t.Fatal("Expected not to find foo")
+	}
+
+	if err := cm.SetGaugeAggregation("foo", GaugeAggregationMin); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cm.RemoveGaugeAggregation("foo")
+	if _, ok := cm.gaugeAggs["foo"]; ok {
+		t.Fatal("Expected not to find foo")
+	}
+}
+
+func TestSnapGaugesAggregation(t *testing.T) {
+	t.Log("Testing util.snapGauges with aggregation")
+
+	tests := []struct {
+		mode     GaugeAggregation
+		expected float64
+	}{
+		{GaugeAggregationMin, 1},
+		{GaugeAggregationMax, 10},
+		{GaugeAggregationMean, 5},
+		{GaugeAggregationSum, 15},
+	}
+
+	for _, test := range tests {
+		cm := &CirconusMetrics{gauges: make(map[string]interface{}), resetGauges: true}
+
+		if err := cm.SetGaugeAggregation("foo", test.mode); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		cm.SetGauge("foo", 10)
+		cm.SetGauge("foo", 1)
+		cm.SetGauge("foo", 4)
+
+		g := cm.snapGauges()
+		if v, ok := g["foo"]; !ok {
+			t.Fatalf("%s: Expected to find foo", test.mode)
+		} else if v.(float64) != test.expected {
+			t.Fatalf("%s: Expected %v, got %v", test.mode, test.expected, v)
+		}
+
+		g = cm.snapGauges()
+		if len(g) != 0 {
+			t.Fatalf("%s: Expected 0 gauges, got %v", test.mode, g)
+		}
+	}
+}
+
+func TestSnapGaugesAggregationAll(t *testing.T) {
+	t.Log("Testing util.snapGauges with all aggregation")
+
+	cm := &CirconusMetrics{gauges: make(map[string]interface{})}
+
+	if err := cm.SetGaugeAggregation("foo", GaugeAggregationAll); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cm.SetGauge("foo", int64(2))
+	cm.AddGauge("foo", int64(4))
+
+	expected := map[string]float64{
+		"foo`last": 6,
+		"foo`min":  2,
+		"foo`max":  6,
+		"foo`mean": 4,
+		"foo`sum":  8,
+	}
+
+	g := cm.snapGauges()
+	if len(g) != len(expected) {
+		t.Fatalf("Expected %d gauges, got %v", len(expected), g)
+	}
+	for n, e := range expected {
+		if v, ok := g[n]; !ok {
+			t.Fatalf("Expected to find %s", n)
+		} else if v.(float64) != e {
+			t.Fatalf("%s expected %v, got %v", n, e, v)
+		}
+	}
+
+	t.Log("retained gauge, no new values")
+	g = cm.snapGauges()
+	if v := g["foo`mean"]; v.(float64) != 6 {
+		t.Fatalf("Expected 6, got %v", v)
+	}
+}
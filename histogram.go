@@ -5,12 +5,19 @@
 package circonusgometrics
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 
 	"github.com/circonus-labs/circonusllhist"
 )
 
+const (
+	defaultHistogramEncoding = histogramEncodingDec
+	histogramEncodingDec     = "dec" // json array of H[bin]=count strings
+	histogramEncodingB64     = "b64" // base64 encoded circonusllhist binary
+)
+
 // Histogram measures the distribution of a stream of values.
 type Histogram struct {
 	name string
@@ -99,3 +106,18 @@ func (h *Histogram) RecordValue(v float64) {
 	h.hist.RecordValue(v)
 	h.rw.Unlock()
 }
+
+// histogramMetric returns the submission representation of a histogram,
+// either a list of decimal bin strings or the base64 encoded binary form
+func (m *CirconusMetrics) histogramMetric(hist *circonusllhist.Histogram) (Metric, error) {
+	if !m.histogramB64 {
+		return Metric{Type: "n", Value: hist.DecStrings()}, nil
+	}
+
+	var b bytes.Buffer
+	if err := hist.SerializeB64(&b); err != nil {
+		return Metric{}, err
+	}
+
+	return Metric{Type: "h", Value: b.String()}, nil
+}
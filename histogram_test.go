@@ -5,8 +5,13 @@
 package circonusgometrics
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/circonus-labs/circonusllhist"
 )
 
 func TestTiming(t *testing.T) {
@@ -245,3 +250,107 @@ func TestHistRecordValue(t *testing.T) {
 		t.Fatalf("Expected non-nil")
 	}
 }
+
+func TestHistogramMetric(t *testing.T) {
+	t.Log("Testing histogram.histogramMetric")
+
+	hist := circonusllhist.New()
+	for i := 0; i < 10000; i++ {
+		hist.RecordValue(float64(i) / 1000)
+	}
+
+	t.Log("dec")
+	{
+		cm := &CirconusMetrics{}
+		metric, err := cm.histogramMetric(hist)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if metric.Type != "n" {
+			t.Fatalf("expected type 'n', got '%s'", metric.Type)
+		}
+		if !reflect.DeepEqual(metric.Value, hist.DecStrings()) {
+			t.Fatalf("expected dec strings, got %v", metric.Value)
+		}
+	}
+
+	t.Log("b64, round trip")
+	{
+		cm := &CirconusMetrics{histogramB64: true}
+		metric, err := cm.histogramMetric(hist)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if metric.Type != "h" {
+			t.Fatalf("expected type 'h', got '%s'", metric.Type)
+		}
+
+		data, err := base64.StdEncoding.DecodeString(metric.Value.(string))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		decoded, err := circonusllhist.Deserialize(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(decoded.DecStrings(), hist.DecStrings()) {
+			t.Fatalf("expected round trip to match\n%v\n%v", decoded.DecStrings(), hist.DecStrings())
+		}
+
+		dec, err := json.Marshal(Metric{Type: "n", Value: hist.DecStrings()})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		b64, err := json.Marshal(metric)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(b64) >= len(dec)/2 {
+			t.Fatalf("expected b64 payload (%d) to be less than half of dec payload (%d)", len(b64), len(dec))
+		}
+	}
+}
+
+func TestFlushHistogramEncoding(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	t.Log("b64")
+	{
+		hcfg := *cfg
+		hcfg.HistogramEncoding = "b64"
+		cm, err := NewCirconusMetrics(&hcfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.RecordValue("foo", 30.28)
+
+		metrics := cm.FlushMetrics()
+		if m, mok := (*metrics)["foo"]; !mok {
+			t.Fatalf("'foo' not found in %v", metrics)
+		} else if m.Type != "h" {
+			t.Fatalf("'Type' not correct %v", m)
+		} else if _, ok := m.Value.(string); !ok {
+			t.Fatalf("'Value' not correct %v", m)
+		}
+
+		b, err := cm.PromOutput()
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if b.Len() != 0 {
+			t.Fatalf("Expected histogram to be skipped, got '%s'", b.String())
+		}
+	}
+
+	t.Log("invalid")
+	{
+		hcfg := *cfg
+		hcfg.HistogramEncoding = "binary"
+		if _, err := NewCirconusMetrics(&hcfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package strhash provides the well distributed string hash shared by the
+// unique (hyperloglog) metrics and the check shard hash ring.
+package strhash
+
+import "hash/fnv"
+
+// Sum64 returns a well distributed 64-bit hash of a string
+func Sum64(s string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	h := f.Sum64()
+
+	// fnv alone clusters short and similar (e.g. numbered) strings,
+	// finalize with the murmur3 64-bit mixer
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
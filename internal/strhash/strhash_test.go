@@ -0,0 +1,41 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package strhash
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSum64(t *testing.T) {
+	t.Log("Testing strhash.Sum64")
+
+	if Sum64("foo") != Sum64("foo") {
+		t.Fatal("Expected same hash for same string")
+	}
+	if Sum64("foo") == Sum64("bar") {
+		t.Fatal("Expected different hashes")
+	}
+
+	t.Log("numbered strings are well distributed")
+	{
+		// each bit of the hash should be set for roughly half of the values
+		const n = 10000
+		var counts [64]int
+		for i := 0; i < n; i++ {
+			h := Sum64(fmt.Sprintf("metric%d", i))
+			for b := 0; b < 64; b++ {
+				if h&(1<<uint(b)) != 0 {
+					counts[b]++
+				}
+			}
+		}
+		for b, c := range counts {
+			if c < n*45/100 || c > n*55/100 {
+				t.Fatalf("Expected bit %d set in ~50%% of hashes, got %d/%d", b, c, n)
+			}
+		}
+	}
+}
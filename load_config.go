@@ -0,0 +1,475 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LoadConfig builds a Config from configuration files (json, yaml or toml)
+// and environment variables, so services do not need to map them by hand.
+//
+// Precedence, lowest to highest:
+//
+//  1. LoadOptions.Config (e.g. application defaults)
+//  2. configuration files, in the order listed (later files override earlier)
+//  3. environment variables (CIRCONUS_*, see OPTIONS.md)
+//
+// Every value is validated before LoadConfig returns, all problems found
+// are reported together in a *ConfigError.
+
+const defaultEnvPrefix = "CIRCONUS_"
+
+// LoadOptions controls where LoadConfig reads configuration from
+type LoadOptions struct {
+	// configuration files, format is determined by extension
+	// (.json, .yaml, .yml or .toml)
+	Files []string
+	// prefix for environment variables (default CIRCONUS_)
+	EnvPrefix string
+	// do not read environment variables
+	IgnoreEnv bool
+	// base configuration, files and environment variables override
+	// its settings. Settings which cannot be expressed in a file
+	// (Log, Logger, callbacks, Destinations, etc.) are kept as is.
+	Config *Config
+}
+
+// ConfigError reports every problem found loading a configuration
+type ConfigError struct {
+	Errors []error
+}
+
+// Error returns all errors, one per line
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d configuration error(s):\n%s", len(e.Errors), strings.Join(msgs, "\n"))
+}
+
+// configValue is a setting from a file or environment variable, any scalar
+// (string, number, bool) is accepted and kept as a string, blank is unset
+type configValue string
+
+// UnmarshalJSON accepts any json scalar
+func (v *configValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*v = ""
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = configValue(s)
+	case len(data) > 0 && (data[0] == '{' || data[0] == '['):
+		return errors.Errorf("expected a value, got %s", data)
+	default:
+		*v = configValue(data)
+	}
+	return nil
+}
+
+// UnmarshalYAML accepts any yaml scalar
+func (v *configValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	return v.set(raw)
+}
+
+// UnmarshalTOML accepts any toml scalar
+func (v *configValue) UnmarshalTOML(raw interface{}) error {
+	return v.set(raw)
+}
+
+func (v *configValue) set(raw interface{}) error {
+	switch t := raw.(type) {
+	case nil:
+		*v = ""
+	case string:
+		*v = configValue(t)
+	case bool, int, int64, uint64, float64:
+		*v = configValue(fmt.Sprint(t))
+	default:
+		return errors.Errorf("expected a value, got %v", raw)
+	}
+	return nil
+}
+
+// fileConfig is the file and environment variable configuration schema.
+// Each setting has the same key in every file format, env is the name of
+// the environment variable (without prefix) and check how it is validated
+// (see checkSetting).
+type fileConfig struct {
+	Debug                      configValue `json:"debug" yaml:"debug" toml:"debug" env:"DEBUG" check:"bool"`
+	Interval                   configValue `json:"interval" yaml:"interval" toml:"interval" env:"INTERVAL" check:"duration"`
+	ResetCounters              configValue `json:"reset_counters" yaml:"reset_counters" toml:"reset_counters" env:"RESET_COUNTERS" check:"bool"`
+	ResetUpDownCounters        configValue `json:"reset_up_down_counters" yaml:"reset_up_down_counters" toml:"reset_up_down_counters" env:"RESET_UP_DOWN_COUNTERS" check:"bool"`
+	ResetGauges                configValue `json:"reset_gauges" yaml:"reset_gauges" toml:"reset_gauges" env:"RESET_GAUGES" check:"bool"`
+	ResetHistograms            configValue `json:"reset_histograms" yaml:"reset_histograms" toml:"reset_histograms" env:"RESET_HISTOGRAMS" check:"bool"`
+	ResetText                  configValue `json:"reset_text" yaml:"reset_text" toml:"reset_text" env:"RESET_TEXT" check:"bool"`
+	TimerUnits                 configValue `json:"timer_units" yaml:"timer_units" toml:"timer_units" env:"TIMER_UNITS" check:"timer_units"`
+	TopKOutput                 configValue `json:"topk_output" yaml:"topk_output" toml:"topk_output" env:"TOPK_OUTPUT" check:"counters|text"`
+	HistogramEncoding          configValue `json:"histogram_encoding" yaml:"histogram_encoding" toml:"histogram_encoding" env:"HISTOGRAM_ENCODING" check:"dec|b64"`
+	TimestampResolution        configValue `json:"timestamp_resolution" yaml:"timestamp_resolution" toml:"timestamp_resolution" env:"TIMESTAMP_RESOLUTION" check:"resolution"`
+	SubmitCompression          configValue `json:"submit_compression" yaml:"submit_compression" toml:"submit_compression" env:"SUBMIT_COMPRESSION" check:"none|gzip|deflate"`
+	SubmitCompressionThreshold configValue `json:"submit_compression_threshold" yaml:"submit_compression_threshold" toml:"submit_compression_threshold" env:"SUBMIT_COMPRESSION_THRESHOLD" check:"int"`
+	SubmitMaxMetrics           configValue `json:"submit_max_metrics" yaml:"submit_max_metrics" toml:"submit_max_metrics" env:"SUBMIT_MAX_METRICS" check:"int"`
+	SubmitMaxBytes             configValue `json:"submit_max_bytes" yaml:"submit_max_bytes" toml:"submit_max_bytes" env:"SUBMIT_MAX_BYTES" check:"int"`
+	SubmitWorkers              configValue `json:"submit_workers" yaml:"submit_workers" toml:"submit_workers" env:"SUBMIT_WORKERS" check:"count"`
+	SelfMetrics                configValue `json:"self_metrics" yaml:"self_metrics" toml:"self_metrics" env:"SELF_METRICS" check:"bool"`
+	SelfMetricsPrefix          configValue `json:"self_metrics_prefix" yaml:"self_metrics_prefix" toml:"self_metrics_prefix" env:"SELF_METRICS_PREFIX"`
+	Expvar                     configValue `json:"expvar" yaml:"expvar" toml:"expvar" env:"EXPVAR" check:"bool"`
+	ExpvarPrefix               configValue `json:"expvar_prefix" yaml:"expvar_prefix" toml:"expvar_prefix" env:"EXPVAR_PREFIX"`
+	ExpvarInclude              configValue `json:"expvar_include" yaml:"expvar_include" toml:"expvar_include" env:"EXPVAR_INCLUDE" check:"regexp"`
+	ExpvarExclude              configValue `json:"expvar_exclude" yaml:"expvar_exclude" toml:"expvar_exclude" env:"EXPVAR_EXCLUDE" check:"regexp"`
+	GraphiteURL                configValue `json:"graphite_url" yaml:"graphite_url" toml:"graphite_url" env:"GRAPHITE_URL" check:"graphite_url"`
+	InfluxURL                  configValue `json:"influx_url" yaml:"influx_url" toml:"influx_url" env:"INFLUX_URL" check:"influx_url"`
+
+	SubmitPolicy struct {
+		MaxAttempts      configValue `json:"max_attempts" yaml:"max_attempts" toml:"max_attempts" env:"SUBMIT_MAX_ATTEMPTS" check:"count"`
+		RetryWaitMin     configValue `json:"retry_wait_min" yaml:"retry_wait_min" toml:"retry_wait_min" env:"SUBMIT_RETRY_WAIT_MIN" check:"duration"`
+		RetryWaitMax     configValue `json:"retry_wait_max" yaml:"retry_wait_max" toml:"retry_wait_max" env:"SUBMIT_RETRY_WAIT_MAX" check:"duration"`
+		Backoff          configValue `json:"backoff" yaml:"backoff" toml:"backoff" env:"SUBMIT_BACKOFF" check:"exponential|linear"`
+		Jitter           configValue `json:"jitter" yaml:"jitter" toml:"jitter" env:"SUBMIT_JITTER" check:"bool"`
+		AttemptTimeout   configValue `json:"attempt_timeout" yaml:"attempt_timeout" toml:"attempt_timeout" env:"SUBMIT_ATTEMPT_TIMEOUT" check:"duration"`
+		Timeout          configValue `json:"timeout" yaml:"timeout" toml:"timeout" env:"SUBMIT_TIMEOUT" check:"duration"`
+		DialTimeout      configValue `json:"dial_timeout" yaml:"dial_timeout" toml:"dial_timeout" env:"SUBMIT_DIAL_TIMEOUT" check:"duration"`
+		RetryStatusCodes configValue `json:"retry_status_codes" yaml:"retry_status_codes" toml:"retry_status_codes" env:"SUBMIT_RETRY_STATUS_CODES" check:"status_codes"`
+	} `json:"submit_policy" yaml:"submit_policy" toml:"submit_policy"`
+
+	API struct {
+		URL       configValue `json:"url" yaml:"url" toml:"url" env:"API_URL"`
+		TokenKey  configValue `json:"token_key" yaml:"token_key" toml:"token_key" env:"API_TOKEN"`
+		TokenApp  configValue `json:"token_app" yaml:"token_app" toml:"token_app" env:"API_APP"`
+		AccountID configValue `json:"account_id" yaml:"account_id" toml:"account_id" env:"API_ACCOUNT_ID"`
+		tlsFiles  `yaml:",inline" env:"API_"`
+	} `json:"api" yaml:"api" toml:"api"`
+
+	Check struct {
+		SubmissionURL         configValue `json:"submission_url" yaml:"submission_url" toml:"submission_url" env:"SUBMISSION_URL"`
+		ID                    configValue `json:"id" yaml:"id" toml:"id" env:"CHECK_ID" check:"uint"`
+		InstanceID            configValue `json:"instance_id" yaml:"instance_id" toml:"instance_id" env:"CHECK_INSTANCE_ID"`
+		TargetHost            configValue `json:"target_host" yaml:"target_host" toml:"target_host" env:"CHECK_TARGET_HOST"`
+		DisplayName           configValue `json:"display_name" yaml:"display_name" toml:"display_name" env:"CHECK_DISPLAY_NAME"`
+		SearchTag             configValue `json:"search_tag" yaml:"search_tag" toml:"search_tag" env:"CHECK_SEARCH_TAG"`
+		Secret                configValue `json:"secret" yaml:"secret" toml:"secret" env:"CHECK_SECRET"`
+		Tags                  configValue `json:"tags" yaml:"tags" toml:"tags" env:"CHECK_TAGS"`
+		MaxURLAge             configValue `json:"max_url_age" yaml:"max_url_age" toml:"max_url_age" env:"CHECK_MAX_URL_AGE" check:"duration"`
+		ForceMetricActivation configValue `json:"force_metric_activation" yaml:"force_metric_activation" toml:"force_metric_activation" env:"CHECK_FORCE_METRIC_ACTIVATION" check:"bool"`
+		Type                  configValue `json:"type" yaml:"type" toml:"type" env:"CHECK_TYPE"`
+		Shards                configValue `json:"shards" yaml:"shards" toml:"shards" env:"CHECK_SHARDS" check:"count"`
+	} `json:"check" yaml:"check" toml:"check"`
+
+	Broker struct {
+		ID              configValue `json:"id" yaml:"id" toml:"id" env:"BROKER_ID" check:"uint"`
+		SelectTag       configValue `json:"select_tag" yaml:"select_tag" toml:"select_tag" env:"BROKER_SELECT_TAG"`
+		MaxResponseTime configValue `json:"max_response_time" yaml:"max_response_time" toml:"max_response_time" env:"BROKER_MAX_RESPONSE_TIME" check:"duration"`
+		tlsFiles        `yaml:",inline" env:"BROKER_"`
+	} `json:"broker" yaml:"broker" toml:"broker"`
+}
+
+// tlsFiles are the paths to a ca certificate and client certificate/key
+// (pem encoded), env names are prefixed with the env tag of the embedding field
+type tlsFiles struct {
+	CAFile   configValue `json:"ca_file" yaml:"ca_file" toml:"ca_file" env:"CA_FILE"`
+	CertFile configValue `json:"cert_file" yaml:"cert_file" toml:"cert_file" env:"CERT_FILE"`
+	KeyFile  configValue `json:"key_file" yaml:"key_file" toml:"key_file" env:"KEY_FILE"`
+}
+
+// LoadConfig returns a Config built from opts, see LoadOptions for precedence
+func LoadConfig(opts *LoadOptions) (*Config, error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+
+	fc := &fileConfig{}
+	var errs []error
+
+	for _, file := range opts.Files {
+		if err := fc.loadFile(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if !opts.IgnoreEnv {
+		prefix := opts.EnvPrefix
+		if prefix == "" {
+			prefix = defaultEnvPrefix
+		}
+		fc.loadEnv(prefix)
+	}
+
+	errs = append(errs, fc.validate()...)
+
+	cfg := &Config{}
+	if opts.Config != nil {
+		*cfg = *opts.Config
+	}
+
+	if err := fc.apply(cfg); err != nil {
+		errs = append(errs, err.Errors...)
+	}
+
+	if len(errs) > 0 {
+		return nil, &ConfigError{Errors: errs}
+	}
+
+	return cfg, nil
+}
+
+// loadFile merges the settings from a configuration file
+func (fc *fileConfig) loadFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrap(err, "reading config file")
+	}
+
+	loaded := &fileConfig{}
+
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(loaded)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, loaded)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), loaded)
+		if err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				keys := make([]string, len(undecoded))
+				for i, key := range undecoded {
+					keys[i] = key.String()
+				}
+				err = errors.Errorf("unknown setting(s) %s", strings.Join(keys, ", "))
+			}
+		}
+	default:
+		return errors.Errorf("config file %s: unsupported format (%s), expected .json, .yaml, .yml or .toml", file, ext)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "parsing config file %s", file)
+	}
+
+	fc.merge(loaded)
+
+	return nil
+}
+
+// loadEnv sets values from environment variables
+func (fc *fileConfig) loadEnv(prefix string) {
+	walkConfigValues(reflect.ValueOf(fc).Elem(), "", "", func(key, env, check string, v *configValue) {
+		if val, ok := os.LookupEnv(prefix + env); ok && val != "" {
+			*v = configValue(val)
+		}
+	})
+}
+
+// merge overrides settings with those set in other
+func (fc *fileConfig) merge(other *fileConfig) {
+	var values []configValue
+	walkConfigValues(reflect.ValueOf(other).Elem(), "", "", func(key, env, check string, v *configValue) {
+		values = append(values, *v)
+	})
+	i := 0
+	walkConfigValues(reflect.ValueOf(fc).Elem(), "", "", func(key, env, check string, v *configValue) {
+		if values[i] != "" {
+			*v = values[i]
+		}
+		i++
+	})
+}
+
+// validate checks every setting, returning all errors found
+func (fc *fileConfig) validate() []error {
+	var errs []error
+
+	walkConfigValues(reflect.ValueOf(fc).Elem(), "", "", func(key, env, check string, v *configValue) {
+		val := string(*v)
+		if val == "" {
+			return
+		}
+
+		if err := checkSetting(check, val); err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid %s (%s) %q", key, env, val))
+		}
+	})
+
+	return errs
+}
+
+// walkConfigValues calls fn for each setting, with its key
+// (e.g. check.max_url_age), environment variable name and check
+func walkConfigValues(v reflect.Value, keyPrefix, envPrefix string, fn func(key, env, check string, v *configValue)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			key := keyPrefix
+			if !field.Anonymous {
+				key += field.Tag.Get("json") + "."
+			}
+			walkConfigValues(fv, key, envPrefix+field.Tag.Get("env"), fn)
+			continue
+		}
+
+		fn(keyPrefix+field.Tag.Get("json"), envPrefix+field.Tag.Get("env"), field.Tag.Get("check"), fv.Addr().Interface().(*configValue))
+	}
+}
+
+// apply sets the loaded settings on cfg
+func (fc *fileConfig) apply(cfg *Config) *ConfigError {
+	set := func(dst *string, v configValue) {
+		if v != "" {
+			*dst = string(v)
+		}
+	}
+
+	if fc.Debug != "" {
+		cfg.Debug, _ = strconv.ParseBool(string(fc.Debug)) // validated
+	}
+	set(&cfg.Interval, fc.Interval)
+	set(&cfg.ResetCounters, fc.ResetCounters)
+	set(&cfg.ResetUpDownCounters, fc.ResetUpDownCounters)
+	set(&cfg.ResetGauges, fc.ResetGauges)
+	set(&cfg.ResetHistograms, fc.ResetHistograms)
+	set(&cfg.ResetText, fc.ResetText)
+	set(&cfg.TimerUnits, fc.TimerUnits)
+	set(&cfg.TopKOutput, fc.TopKOutput)
+	set(&cfg.HistogramEncoding, fc.HistogramEncoding)
+	set(&cfg.TimestampResolution, fc.TimestampResolution)
+	set(&cfg.SubmitCompression, fc.SubmitCompression)
+	set(&cfg.SubmitCompressionThreshold, fc.SubmitCompressionThreshold)
+	set(&cfg.SubmitMaxMetrics, fc.SubmitMaxMetrics)
+	set(&cfg.SubmitMaxBytes, fc.SubmitMaxBytes)
+	set(&cfg.SubmitWorkers, fc.SubmitWorkers)
+	set(&cfg.SelfMetrics, fc.SelfMetrics)
+	set(&cfg.SelfMetricsPrefix, fc.SelfMetricsPrefix)
+	set(&cfg.Expvar, fc.Expvar)
+	set(&cfg.ExpvarPrefix, fc.ExpvarPrefix)
+	set(&cfg.ExpvarInclude, fc.ExpvarInclude)
+	set(&cfg.ExpvarExclude, fc.ExpvarExclude)
+	set(&cfg.GraphiteURL, fc.GraphiteURL)
+	set(&cfg.InfluxURL, fc.InfluxURL)
+
+	sp := &fc.SubmitPolicy
+	set(&cfg.SubmitPolicy.MaxAttempts, sp.MaxAttempts)
+	set(&cfg.SubmitPolicy.RetryWaitMin, sp.RetryWaitMin)
+	set(&cfg.SubmitPolicy.RetryWaitMax, sp.RetryWaitMax)
+	set(&cfg.SubmitPolicy.Backoff, sp.Backoff)
+	set(&cfg.SubmitPolicy.Jitter, sp.Jitter)
+	set(&cfg.SubmitPolicy.AttemptTimeout, sp.AttemptTimeout)
+	set(&cfg.SubmitPolicy.Timeout, sp.Timeout)
+	set(&cfg.SubmitPolicy.DialTimeout, sp.DialTimeout)
+	set(&cfg.SubmitPolicy.RetryStatusCodes, sp.RetryStatusCodes)
+
+	apiCfg := &cfg.CheckManager.API
+	set(&apiCfg.URL, fc.API.URL)
+	set(&apiCfg.TokenKey, fc.API.TokenKey)
+	set(&apiCfg.TokenApp, fc.API.TokenApp)
+	set(&apiCfg.TokenAccountID, fc.API.AccountID)
+
+	check := &cfg.CheckManager.Check
+	set(&check.SubmissionURL, fc.Check.SubmissionURL)
+	set(&check.ID, fc.Check.ID)
+	set(&check.InstanceID, fc.Check.InstanceID)
+	set(&check.TargetHost, fc.Check.TargetHost)
+	set(&check.DisplayName, fc.Check.DisplayName)
+	set(&check.SearchTag, fc.Check.SearchTag)
+	set(&check.Secret, fc.Check.Secret)
+	set(&check.Tags, fc.Check.Tags)
+	set(&check.MaxURLAge, fc.Check.MaxURLAge)
+	set(&check.ForceMetricActivation, fc.Check.ForceMetricActivation)
+	set(&check.Type, fc.Check.Type)
+	set(&check.Shards, fc.Check.Shards)
+
+	broker := &cfg.CheckManager.Broker
+	set(&broker.ID, fc.Broker.ID)
+	set(&broker.SelectTag, fc.Broker.SelectTag)
+	set(&broker.MaxResponseTime, fc.Broker.MaxResponseTime)
+
+	var errs []error
+
+	if tlsConfig, err := fc.API.tlsConfig(apiCfg.TLSConfig); err != nil {
+		errs = append(errs, errors.Wrap(err, "api tls"))
+	} else {
+		apiCfg.TLSConfig = tlsConfig
+	}
+
+	if tlsConfig, err := fc.Broker.tlsConfig(broker.TLSConfig); err != nil {
+		errs = append(errs, errors.Wrap(err, "broker tls"))
+	} else {
+		broker.TLSConfig = tlsConfig
+	}
+
+	if len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+
+	return nil
+}
+
+// tlsConfig returns base with the ca and client certificates loaded,
+// base is returned unchanged when no files are set
+func (f *tlsFiles) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	if f.CAFile == "" && f.CertFile == "" && f.KeyFile == "" {
+		return base, nil
+	}
+
+	var tlsConfig *tls.Config
+	if base != nil {
+		tlsConfig = base.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+
+	if f.CAFile != "" {
+		pem, err := ioutil.ReadFile(string(f.CAFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading ca file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in ca file %s", f.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if f.CertFile != "" || f.KeyFile != "" {
+		if f.CertFile == "" || f.KeyFile == "" {
+			return nil, errors.New("cert_file and key_file must both be set for a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(string(f.CertFile), string(f.KeyFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,288 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestFile writes a file in dir, returning its path
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("writing %s: %v", file, err)
+	}
+	return file
+}
+
+// writeTestCert writes a self-signed certificate and key, returning their paths
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+
+	certFile := writeTestFile(t, dir, "cert.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	keyFile := writeTestFile(t, dir, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})))
+
+	return certFile, keyFile
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Log("Testing LoadConfig")
+
+	dir, err := ioutil.TempDir("", "cgm-config")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Log("nil options")
+	{
+		cfg, err := LoadConfig(nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if cfg == nil {
+			t.Fatal("Expected config")
+		}
+	}
+
+	jsonFile := writeTestFile(t, dir, "cgm.json", `{
+		"debug": true,
+		"interval": "20s",
+		"submit_workers": 2,
+		"submit_policy": {"max_attempts": 3},
+		"api": {"token_key": "json-key", "token_app": "json-app"},
+		"check": {"instance_id": "json-id", "search_tag": "service:json"}
+	}`)
+
+	yamlFile := writeTestFile(t, dir, "cgm.yaml", `
+interval: 30s
+reset_counters: no
+check:
+  instance_id: yaml-id
+broker:
+  max_response_time: 1s
+`)
+
+	tomlFile := writeTestFile(t, dir, "cgm.toml", `
+histogram_encoding = "b64"
+submit_max_metrics = 500
+
+[check]
+shards = 2
+force_metric_activation = true
+
+[submit_policy]
+jitter = true
+`)
+
+	t.Log("files, in order")
+	{
+		cfg, err := LoadConfig(&LoadOptions{Files: []string{jsonFile, yamlFile, tomlFile}, IgnoreEnv: true})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		expect := map[string]string{
+			"interval":                cfg.Interval,
+			"reset_counters":          cfg.ResetCounters,
+			"histogram_encoding":      cfg.HistogramEncoding,
+			"submit_max_metrics":      cfg.SubmitMaxMetrics,
+			"submit_workers":          cfg.SubmitWorkers,
+			"max_attempts":            cfg.SubmitPolicy.MaxAttempts,
+			"jitter":                  cfg.SubmitPolicy.Jitter,
+			"token_key":               cfg.CheckManager.API.TokenKey,
+			"token_app":               cfg.CheckManager.API.TokenApp,
+			"instance_id":             cfg.CheckManager.Check.InstanceID,
+			"search_tag":              cfg.CheckManager.Check.SearchTag,
+			"shards":                  cfg.CheckManager.Check.Shards,
+			"force_metric_activation": cfg.CheckManager.Check.ForceMetricActivation,
+			"max_response_time":       cfg.CheckManager.Broker.MaxResponseTime,
+		}
+		for key, val := range map[string]string{
+			"interval":                "30s",
+			"reset_counters":          "false",
+			"histogram_encoding":      "b64",
+			"submit_max_metrics":      "500",
+			"submit_workers":          "2",
+			"max_attempts":            "3",
+			"jitter":                  "true",
+			"token_key":               "json-key",
+			"token_app":               "json-app",
+			"instance_id":             "yaml-id",
+			"search_tag":              "service:json",
+			"shards":                  "2",
+			"force_metric_activation": "true",
+			"max_response_time":       "1s",
+		} {
+			if expect[key] != val {
+				t.Fatalf("Expected %s to be '%s', got '%s'", key, val, expect[key])
+			}
+		}
+		if !cfg.Debug {
+			t.Fatal("Expected debug")
+		}
+	}
+
+	t.Log("environment overrides files and base config")
+	{
+		os.Setenv("TESTCGM_API_TOKEN", "env-key")
+		os.Setenv("TESTCGM_CHECK_INSTANCE_ID", "env-id")
+		os.Setenv("TESTCGM_SUBMIT_TIMEOUT", "5s")
+		os.Setenv("TESTCGM_DEBUG", "false")
+		defer func() {
+			os.Unsetenv("TESTCGM_API_TOKEN")
+			os.Unsetenv("TESTCGM_CHECK_INSTANCE_ID")
+			os.Unsetenv("TESTCGM_SUBMIT_TIMEOUT")
+			os.Unsetenv("TESTCGM_DEBUG")
+		}()
+
+		base := &Config{Interval: "1m", TimerUnits: "ms"}
+		base.CheckManager.API.TokenApp = "base-app"
+
+		cfg, err := LoadConfig(&LoadOptions{Files: []string{jsonFile}, EnvPrefix: "TESTCGM_", Config: base})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if cfg.CheckManager.API.TokenKey != "env-key" || cfg.CheckManager.Check.InstanceID != "env-id" || cfg.SubmitPolicy.Timeout != "5s" || cfg.Debug {
+			t.Fatalf("Expected environment settings, got %+v", cfg)
+		}
+		if cfg.Interval != "20s" || cfg.CheckManager.API.TokenApp != "json-app" {
+			t.Fatalf("Expected file settings, got %+v", cfg)
+		}
+		if cfg.TimerUnits != "ms" {
+			t.Fatalf("Expected base setting, got %s", cfg.TimerUnits)
+		}
+		if base.Interval != "1m" {
+			t.Fatal("Expected base config to be unchanged")
+		}
+	}
+
+	t.Log("timer units, same values as Validate")
+	{
+		for _, units := range []string{"s", "ms", "us", "µs"} {
+			unitsFile := writeTestFile(t, dir, "units.yaml", "timer_units: "+units+"\n")
+			cfg, err := LoadConfig(&LoadOptions{Files: []string{unitsFile}, IgnoreEnv: true})
+			if err != nil {
+				t.Fatalf("Expected no error for %s, got '%v'", units, err)
+			}
+			cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Expected no validation error for %s, got '%v'", units, err)
+			}
+		}
+	}
+
+	t.Log("all errors reported")
+	{
+		badFile := writeTestFile(t, dir, "bad.yaml", `
+interval: soon
+reset_gauges: maybe
+timer_units: hours
+check:
+  id: abc
+submit_policy:
+  retry_status_codes: 500-abc
+`)
+		_, err := LoadConfig(&LoadOptions{Files: []string{badFile, filepath.Join(dir, "missing.json"), writeTestFile(t, dir, "cgm.ini", "")}, IgnoreEnv: true})
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		cerr, ok := err.(*ConfigError)
+		if !ok {
+			t.Fatalf("Expected *ConfigError, got %T", err)
+		}
+		if len(cerr.Errors) != 7 {
+			t.Fatalf("Expected 7 errors, got %d: %v", len(cerr.Errors), err)
+		}
+		for _, key := range []string{"interval", "reset_gauges", "timer_units", "check.id", "submit_policy.retry_status_codes", "missing.json", "cgm.ini"} {
+			if !strings.Contains(err.Error(), key) {
+				t.Fatalf("Expected error for %s, got '%v'", key, err)
+			}
+		}
+	}
+
+	t.Log("unknown settings")
+	{
+		for name, content := range map[string]string{
+			"unknown.json": `{"check": {"instanceid": "x"}}`,
+			"unknown.yaml": "chek:\n  instance_id: x\n",
+			"unknown.toml": "[check]\ninstanceid = \"x\"\n",
+		} {
+			if _, err := LoadConfig(&LoadOptions{Files: []string{writeTestFile(t, dir, name, content)}, IgnoreEnv: true}); err == nil {
+				t.Fatalf("Expected error for %s", name)
+			}
+		}
+	}
+
+	t.Log("tls files")
+	{
+		certFile, keyFile := writeTestCert(t, dir)
+		tlsFile := writeTestFile(t, dir, "tls.toml", `
+[api]
+ca_file = "`+certFile+`"
+
+[broker]
+ca_file = "`+certFile+`"
+cert_file = "`+certFile+`"
+key_file = "`+keyFile+`"
+`)
+		cfg, err := LoadConfig(&LoadOptions{Files: []string{tlsFile}, IgnoreEnv: true})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if cfg.CheckManager.API.TLSConfig == nil || cfg.CheckManager.API.TLSConfig.RootCAs == nil {
+			t.Fatal("Expected api tls config with ca")
+		}
+		broker := cfg.CheckManager.Broker.TLSConfig
+		if broker == nil || broker.RootCAs == nil || len(broker.Certificates) != 1 {
+			t.Fatal("Expected broker tls config with ca and client certificate")
+		}
+
+		os.Setenv("TESTCGM_BROKER_CERT_FILE", filepath.Join(dir, "missing.pem"))
+		os.Setenv("TESTCGM_API_CA_FILE", keyFile)
+		defer func() {
+			os.Unsetenv("TESTCGM_BROKER_CERT_FILE")
+			os.Unsetenv("TESTCGM_API_CA_FILE")
+		}()
+		_, err = LoadConfig(&LoadOptions{Files: []string{tlsFile}, EnvPrefix: "TESTCGM_"})
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if cerr := err.(*ConfigError); len(cerr.Errors) != 2 {
+			t.Fatalf("Expected 2 errors, got '%v'", err)
+		}
+	}
+}
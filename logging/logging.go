@@ -0,0 +1,260 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logging provides the leveled, structured logging interface used by
+// circonus-gometrics, checkmgr and api, along with adapters for the standard
+// library logger, log/slog (go1.21+) and a no-op logger.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Logger is a leveled, structured logger. keyvals are alternating
+// key, value pairs (e.g. "metric", name, "err", err).
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// DebugSetter is implemented by loggers which can enable or disable debug
+// messages at runtime (e.g. NewStdLogger), see CirconusMetrics.Reconfigure
+type DebugSetter interface {
+	SetDebug(debug bool)
+}
+
+// Level of a log message
+type Level int
+
+// Log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the level as used in message prefixes (e.g. "WARN")
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// stdLogger adapts a *log.Logger
+type stdLogger struct {
+	l     *log.Logger
+	debug int32 // 1 when debug messages are written, accessed atomically
+}
+
+// NewStdLogger returns a Logger writing to a standard library logger.
+// Messages are formatted as `[LEVEL] msg key=value ...`, debug messages
+// are only written when debug is true. A nil logger discards everything.
+func NewStdLogger(l *log.Logger, debug bool) Logger {
+	if l == nil {
+		return NewNopLogger()
+	}
+	s := &stdLogger{l: l}
+	s.SetDebug(debug)
+	return s
+}
+
+// SetDebug enables or disables debug messages
+func (s *stdLogger) SetDebug(debug bool) {
+	var v int32
+	if debug {
+		v = 1
+	}
+	atomic.StoreInt32(&s.debug, v)
+}
+
+func (s *stdLogger) Debug(msg string, keyvals ...interface{}) {
+	if atomic.LoadInt32(&s.debug) == 1 {
+		s.log(LevelDebug, msg, keyvals)
+	}
+}
+
+func (s *stdLogger) Info(msg string, keyvals ...interface{}) {
+	s.log(LevelInfo, msg, keyvals)
+}
+
+func (s *stdLogger) Warn(msg string, keyvals ...interface{}) {
+	s.log(LevelWarn, msg, keyvals)
+}
+
+func (s *stdLogger) Error(msg string, keyvals ...interface{}) {
+	s.log(LevelError, msg, keyvals)
+}
+
+func (s *stdLogger) log(level Level, msg string, keyvals []interface{}) {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(level.String())
+	b.WriteString("] ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteString(" ")
+		b.WriteString(formatValue(keyvals[i]))
+		b.WriteString("=")
+		if i+1 < len(keyvals) {
+			b.WriteString(formatValue(keyvals[i+1]))
+		} else {
+			b.WriteString(`"(MISSING)"`)
+		}
+	}
+	s.l.Println(b.String())
+}
+
+// formatValue renders a key or value, quoting it if it contains spaces,
+// quotes, an equals sign or control characters
+func formatValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case error:
+		if t == nil {
+			return "<nil>"
+		}
+		s = t.Error()
+	case fmt.Stringer:
+		s = t.String()
+	default:
+		s = fmt.Sprintf("%+v", v)
+	}
+
+	if s == "" {
+		return `""`
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// nopLogger discards everything
+type nopLogger struct{}
+
+// NewNopLogger returns a Logger which discards all messages
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(msg string, keyvals ...interface{}) {}
+func (nopLogger) Info(msg string, keyvals ...interface{})  {}
+func (nopLogger) Warn(msg string, keyvals ...interface{})  {}
+func (nopLogger) Error(msg string, keyvals ...interface{}) {}
+
+// withLogger adds fields to every message
+type withLogger struct {
+	l      Logger
+	fields []interface{}
+}
+
+// With returns a Logger which adds keyvals to every message logged
+func With(l Logger, keyvals ...interface{}) Logger {
+	if len(keyvals) == 0 {
+		return l
+	}
+	if w, ok := l.(*withLogger); ok {
+		fields := make([]interface{}, 0, len(w.fields)+len(keyvals))
+		fields = append(fields, w.fields...)
+		return &withLogger{l: w.l, fields: append(fields, keyvals...)}
+	}
+	return &withLogger{l: l, fields: keyvals}
+}
+
+func (w *withLogger) Debug(msg string, keyvals ...interface{}) {
+	w.l.Debug(msg, w.merge(keyvals)...)
+}
+
+func (w *withLogger) Info(msg string, keyvals ...interface{}) {
+	w.l.Info(msg, w.merge(keyvals)...)
+}
+
+func (w *withLogger) Warn(msg string, keyvals ...interface{}) {
+	w.l.Warn(msg, w.merge(keyvals)...)
+}
+
+func (w *withLogger) Error(msg string, keyvals ...interface{}) {
+	w.l.Error(msg, w.merge(keyvals)...)
+}
+
+func (w *withLogger) merge(keyvals []interface{}) []interface{} {
+	all := make([]interface{}, 0, len(w.fields)+len(keyvals))
+	all = append(all, w.fields...)
+	return append(all, keyvals...)
+}
+
+// levelWriter forwards lines written by a *log.Logger to a Logger
+type levelWriter struct {
+	l Logger
+}
+
+// NewStdLog returns a *log.Logger which forwards each line to l, for
+// libraries which only accept a standard logger (e.g. retryablehttp).
+// The level is taken from a leading `[LEVEL]` prefix, if present
+// (DEBUG, INFO, WARN, ERR/ERROR), otherwise lines are logged at debug.
+func NewStdLog(l Logger) *log.Logger {
+	return log.New(&levelWriter{l: l}, "", 0)
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		level, msg := parseLevel(line)
+		switch level {
+		case LevelInfo:
+			w.l.Info(msg)
+		case LevelWarn:
+			w.l.Warn(msg)
+		case LevelError:
+			w.l.Error(msg)
+		default:
+			w.l.Debug(msg)
+		}
+	}
+	return len(p), nil
+}
+
+// parseLevel extracts a leading [LEVEL] prefix from a line
+func parseLevel(line string) (Level, string) {
+	if !strings.HasPrefix(line, "[") {
+		return LevelDebug, line
+	}
+	end := strings.Index(line, "]")
+	if end < 0 {
+		return LevelDebug, line
+	}
+
+	msg := strings.TrimSpace(line[end+1:])
+	switch strings.ToUpper(line[1:end]) {
+	case "DEBUG":
+		return LevelDebug, msg
+	case "INFO":
+		return LevelInfo, msg
+	case "WARN", "WARNING":
+		return LevelWarn, msg
+	case "ERR", "ERROR":
+		return LevelError, msg
+	default:
+		return LevelDebug, line
+	}
+}
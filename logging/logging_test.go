@@ -0,0 +1,172 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+// recordingLogger records messages as "LEVEL msg keyvals"
+type recordingLogger struct {
+	lines []string
+}
+
+func (r *recordingLogger) record(level Level, msg string, keyvals []interface{}) {
+	line := level.String() + " " + msg
+	for _, kv := range keyvals {
+		line += " " + formatValue(kv)
+	}
+	r.lines = append(r.lines, line)
+}
+
+func (r *recordingLogger) Debug(msg string, keyvals ...interface{}) {
+	r.record(LevelDebug, msg, keyvals)
+}
+
+func (r *recordingLogger) Info(msg string, keyvals ...interface{}) {
+	r.record(LevelInfo, msg, keyvals)
+}
+
+func (r *recordingLogger) Warn(msg string, keyvals ...interface{}) {
+	r.record(LevelWarn, msg, keyvals)
+}
+
+func (r *recordingLogger) Error(msg string, keyvals ...interface{}) {
+	r.record(LevelError, msg, keyvals)
+}
+
+func TestStdLogger(t *testing.T) {
+	t.Log("Testing NewStdLogger")
+
+	t.Log("debug disabled")
+	{
+		var buf bytes.Buffer
+		l := NewStdLogger(log.New(&buf, "", 0), false)
+		l.Debug("hidden")
+		l.Info("shown", "metric", "foo")
+		if buf.String() != "[INFO] shown metric=foo\n" {
+			t.Fatalf("unexpected output (%q)", buf.String())
+		}
+	}
+
+	t.Log("debug enabled")
+	{
+		var buf bytes.Buffer
+		l := NewStdLogger(log.New(&buf, "", 0), true)
+		l.Debug("shown")
+		if buf.String() != "[DEBUG] shown\n" {
+			t.Fatalf("unexpected output (%q)", buf.String())
+		}
+	}
+
+	t.Log("debug toggled")
+	{
+		var buf bytes.Buffer
+		l := NewStdLogger(log.New(&buf, "", 0), false)
+		l.(DebugSetter).SetDebug(true)
+		l.Debug("shown")
+		l.(DebugSetter).SetDebug(false)
+		l.Debug("hidden")
+		if buf.String() != "[DEBUG] shown\n" {
+			t.Fatalf("unexpected output (%q)", buf.String())
+		}
+	}
+
+	t.Log("levels and values")
+	{
+		var buf bytes.Buffer
+		l := NewStdLogger(log.New(&buf, "", 0), false)
+		l.Warn("w", "err", errors.New("bad thing"), "n", 1)
+		l.Error("e", "empty", "", "odd")
+		expect := "[WARN] w err=\"bad thing\" n=1\n[ERROR] e empty=\"\" odd=\"(MISSING)\"\n"
+		if buf.String() != expect {
+			t.Fatalf("Expected %q, got %q", expect, buf.String())
+		}
+	}
+
+	t.Log("nil logger")
+	{
+		if _, ok := NewStdLogger(nil, true).(nopLogger); !ok {
+			t.Fatal("Expected nop logger")
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	t.Log("Testing Level.String")
+
+	tests := map[Level]string{
+		LevelDebug: "DEBUG",
+		LevelInfo:  "INFO",
+		LevelWarn:  "WARN",
+		LevelError: "ERROR",
+		Level(9):   "LEVEL(9)",
+	}
+	for level, expect := range tests {
+		if level.String() != expect {
+			t.Fatalf("Expected %s, got %s", expect, level.String())
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	t.Log("Testing With")
+
+	r := &recordingLogger{}
+
+	if With(r) != Logger(r) {
+		t.Fatal("Expected same logger without fields")
+	}
+
+	l := With(With(r, "a", 1), "b", 2)
+	l.Info("msg", "c", 3)
+	l.Debug("dbg")
+
+	expect := []string{"INFO msg a 1 b 2 c 3", "DEBUG dbg a 1 b 2"}
+	if strings.Join(r.lines, "|") != strings.Join(expect, "|") {
+		t.Fatalf("Expected %v, got %v", expect, r.lines)
+	}
+}
+
+func TestStdLog(t *testing.T) {
+	t.Log("Testing NewStdLog")
+
+	r := &recordingLogger{}
+	l := NewStdLog(r)
+
+	l.Printf("[DEBUG] one")
+	l.Printf("[INFO] two")
+	l.Printf("[WARN] three")
+	l.Printf("[ERR] four\n[ERROR] five")
+	l.Printf("[OTHER] six")
+	l.Printf("seven")
+
+	expect := []string{
+		"DEBUG one",
+		"INFO two",
+		"WARN three",
+		"ERROR four",
+		"ERROR five",
+		"DEBUG [OTHER] six",
+		"DEBUG seven",
+	}
+	if strings.Join(r.lines, "|") != strings.Join(expect, "|") {
+		t.Fatalf("Expected %v, got %v", expect, r.lines)
+	}
+}
+
+func TestNopLogger(t *testing.T) {
+	t.Log("Testing NewNopLogger")
+
+	l := NewNopLogger()
+	l.Debug("x")
+	l.Info("x")
+	l.Warn("x")
+	l.Error("x")
+}
@@ -0,0 +1,38 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	t.Log("Testing NewSlogLogger")
+
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	l := NewSlogLogger(slog.New(h))
+
+	l.Debug("hidden")
+	l.Warn("shown", "metric", "foo")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("Expected debug to be filtered (%s)", out)
+	}
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "msg=shown") || !strings.Contains(out, "metric=foo") {
+		t.Fatalf("unexpected output (%s)", out)
+	}
+
+	if NewSlogLogger(nil) == nil {
+		t.Fatal("Expected logger")
+	}
+}
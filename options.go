@@ -0,0 +1,412 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/circonus-labs/circonus-gometrics/logging"
+	"github.com/pkg/errors"
+)
+
+// Option is a typed configuration setting, see NewWithOptions. Options set
+// the equivalent string fields of Config, so both can be mixed (e.g. to set
+// a field which does not have an Option).
+type Option func(*Config)
+
+// NewConfig returns a Config with opts applied
+func NewConfig(opts ...Option) *Config {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// NewWithOptions returns a CirconusMetrics instance configured with opts,
+// the configuration is validated first and every problem found is reported
+// in a *ConfigError
+func NewWithOptions(opts ...Option) (*CirconusMetrics, error) {
+	cfg := NewConfig(opts...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// WithLog sets the logger (see Config.Log)
+func WithLog(l *log.Logger) Option {
+	return func(cfg *Config) { cfg.Log = l }
+}
+
+// WithLogger sets the leveled logger (see Config.Logger)
+func WithLogger(l logging.Logger) Option {
+	return func(cfg *Config) { cfg.Logger = l }
+}
+
+// WithDebug enables debug messages
+func WithDebug(debug bool) Option {
+	return func(cfg *Config) { cfg.Debug = debug }
+}
+
+// WithInterval sets how frequently metrics are submitted, 0 disables
+// automatic flushes
+func WithInterval(interval time.Duration) Option {
+	return func(cfg *Config) { cfg.Interval = interval.String() }
+}
+
+// WithResetCounters sets whether counters are reset on flush
+func WithResetCounters(reset bool) Option {
+	return func(cfg *Config) { cfg.ResetCounters = strconv.FormatBool(reset) }
+}
+
+// WithResetUpDownCounters sets whether up-down counters are reset on flush
+func WithResetUpDownCounters(reset bool) Option {
+	return func(cfg *Config) { cfg.ResetUpDownCounters = strconv.FormatBool(reset) }
+}
+
+// WithResetGauges sets whether gauges are reset on flush
+func WithResetGauges(reset bool) Option {
+	return func(cfg *Config) { cfg.ResetGauges = strconv.FormatBool(reset) }
+}
+
+// WithResetHistograms sets whether histograms are reset on flush
+func WithResetHistograms(reset bool) Option {
+	return func(cfg *Config) { cfg.ResetHistograms = strconv.FormatBool(reset) }
+}
+
+// WithResetText sets whether text metrics are reset on flush
+func WithResetText(reset bool) Option {
+	return func(cfg *Config) { cfg.ResetText = strconv.FormatBool(reset) }
+}
+
+// WithTimerUnits sets the units timers record durations in,
+// time.Second, time.Millisecond or time.Microsecond
+func WithTimerUnits(units time.Duration) Option {
+	return func(cfg *Config) {
+		switch units {
+		case time.Second:
+			cfg.TimerUnits = "s"
+		case time.Millisecond:
+			cfg.TimerUnits = "ms"
+		case time.Microsecond:
+			cfg.TimerUnits = "us"
+		default:
+			cfg.TimerUnits = units.String() // reported by Validate
+		}
+	}
+}
+
+// WithTimestampResolution sets the resolution timestamped metrics are
+// grouped by
+func WithTimestampResolution(res time.Duration) Option {
+	return func(cfg *Config) { cfg.TimestampResolution = res.String() }
+}
+
+// WithSubmitMaxMetrics sets the maximum number of metrics in a single submission
+func WithSubmitMaxMetrics(n int) Option {
+	return func(cfg *Config) { cfg.SubmitMaxMetrics = strconv.Itoa(n) }
+}
+
+// WithSubmitMaxBytes sets the maximum size of a single submission
+func WithSubmitMaxBytes(n int) Option {
+	return func(cfg *Config) { cfg.SubmitMaxBytes = strconv.Itoa(n) }
+}
+
+// WithSubmitWorkers sets the maximum number of concurrent submissions
+func WithSubmitWorkers(n int) Option {
+	return func(cfg *Config) { cfg.SubmitWorkers = strconv.Itoa(n) }
+}
+
+// WithSelfMetrics enables metrics about cgm itself
+func WithSelfMetrics(enabled bool) Option {
+	return func(cfg *Config) { cfg.SelfMetrics = strconv.FormatBool(enabled) }
+}
+
+// WithExpvar publishes expvar variables as metrics, include and exclude
+// are regular expressions matched against variable names (blank for all)
+func WithExpvar(include, exclude string) Option {
+	return func(cfg *Config) {
+		cfg.Expvar = "true"
+		cfg.ExpvarInclude = include
+		cfg.ExpvarExclude = exclude
+	}
+}
+
+// WithGraphite pushes metrics to graphite at each flush,
+// tcp://host:port or udp://host:port
+func WithGraphite(url string) Option {
+	return func(cfg *Config) { cfg.GraphiteURL = url }
+}
+
+// WithInflux pushes metrics to influxdb at each flush,
+// http(s)://host:port/write?db=name
+func WithInflux(url string) Option {
+	return func(cfg *Config) { cfg.InfluxURL = url }
+}
+
+// WithAPIToken sets the api token key and app
+func WithAPIToken(key, app string) Option {
+	return func(cfg *Config) {
+		cfg.CheckManager.API.TokenKey = key
+		cfg.CheckManager.API.TokenApp = app
+	}
+}
+
+// WithAPIURL sets the api url
+func WithAPIURL(url string) Option {
+	return func(cfg *Config) { cfg.CheckManager.API.URL = url }
+}
+
+// WithSubmissionURL sets a static submission url
+func WithSubmissionURL(url string) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.SubmissionURL = url }
+}
+
+// WithCheckID sets the id of the check to use
+func WithCheckID(id int) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.ID = strconv.Itoa(id) }
+}
+
+// WithCheckInstanceID sets the instance id used to find or create the check
+func WithCheckInstanceID(id string) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.InstanceID = id }
+}
+
+// WithCheckSearchTag sets the tag(s) used to find the check
+func WithCheckSearchTag(tags ...string) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.SearchTag = strings.Join(tags, ",") }
+}
+
+// WithCheckTags sets the tags added to the check
+func WithCheckTags(tags ...string) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.Tags = strings.Join(tags, ",") }
+}
+
+// WithCheckMaxURLAge sets how long submission failures are tolerated
+// before the submission url is refreshed
+func WithCheckMaxURLAge(age time.Duration) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.MaxURLAge = age.String() }
+}
+
+// WithForceMetricActivation sets whether inactive metrics are re-activated
+func WithForceMetricActivation(force bool) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.ForceMetricActivation = strconv.FormatBool(force) }
+}
+
+// WithCheckShards sets the number of check bundles metrics are spread across
+func WithCheckShards(n int) Option {
+	return func(cfg *Config) { cfg.CheckManager.Check.Shards = strconv.Itoa(n) }
+}
+
+// WithBrokerID sets the id of the broker to use for a new check
+func WithBrokerID(id int) Option {
+	return func(cfg *Config) { cfg.CheckManager.Broker.ID = strconv.Itoa(id) }
+}
+
+// WithBrokerSelectTag sets the tag(s) used to select a broker for a new check
+func WithBrokerSelectTag(tags ...string) Option {
+	return func(cfg *Config) { cfg.CheckManager.Broker.SelectTag = strings.Join(tags, ",") }
+}
+
+// WithBrokerMaxResponseTime sets the maximum time a broker can take to
+// respond to be selected for a new check
+func WithBrokerMaxResponseTime(d time.Duration) Option {
+	return func(cfg *Config) { cfg.CheckManager.Broker.MaxResponseTime = d.String() }
+}
+
+// WithDestination adds an additional destination metrics are submitted to
+func WithDestination(d Destination) Option {
+	return func(cfg *Config) { cfg.Destinations = append(cfg.Destinations, d) }
+}
+
+// setting is a string option with its name and how it is validated
+// (see checkSetting)
+type setting struct {
+	name  string
+	value string
+	check string
+}
+
+// Validate checks every setting (including check manager and destination
+// settings) and settings which depend on each other (e.g. the submit policy
+// retry waits, an API token or submission url is required), and returns a
+// *ConfigError listing all problems found, or nil. New stops at the first
+// invalid setting.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return &ConfigError{Errors: []error{errors.New("invalid configuration (nil)")}}
+	}
+
+	settings := []setting{
+		{"Interval", cfg.Interval, "duration"},
+		{"ResetCounters", cfg.ResetCounters, "bool"},
+		{"ResetUpDownCounters", cfg.ResetUpDownCounters, "bool"},
+		{"ResetGauges", cfg.ResetGauges, "bool"},
+		{"ResetHistograms", cfg.ResetHistograms, "bool"},
+		{"ResetText", cfg.ResetText, "bool"},
+		{"TimerUnits", cfg.TimerUnits, "timer_units"},
+		{"TopKOutput", cfg.TopKOutput, topKOutputCounters + "|" + topKOutputText},
+		{"HistogramEncoding", cfg.HistogramEncoding, histogramEncodingDec + "|" + histogramEncodingB64},
+		{"TimestampResolution", cfg.TimestampResolution, "resolution"},
+		{"SubmitCompression", cfg.SubmitCompression, compressionNone + "|" + compressionGzip + "|" + compressionDeflate},
+		{"SubmitCompressionThreshold", cfg.SubmitCompressionThreshold, "int"},
+		{"SubmitMaxMetrics", cfg.SubmitMaxMetrics, "int"},
+		{"SubmitMaxBytes", cfg.SubmitMaxBytes, "int"},
+		{"SubmitWorkers", cfg.SubmitWorkers, "count"},
+		{"SelfMetrics", cfg.SelfMetrics, "bool"},
+		{"Expvar", cfg.Expvar, "bool"},
+		{"ExpvarInclude", cfg.ExpvarInclude, "regexp"},
+		{"ExpvarExclude", cfg.ExpvarExclude, "regexp"},
+		{"GraphiteURL", cfg.GraphiteURL, "graphite_url"},
+		{"InfluxURL", cfg.InfluxURL, "influx_url"},
+		{"SubmitPolicy.MaxAttempts", cfg.SubmitPolicy.MaxAttempts, "count"},
+		{"SubmitPolicy.RetryWaitMin", cfg.SubmitPolicy.RetryWaitMin, "duration"},
+		{"SubmitPolicy.RetryWaitMax", cfg.SubmitPolicy.RetryWaitMax, "duration"},
+		{"SubmitPolicy.Backoff", cfg.SubmitPolicy.Backoff, backoffExponential + "|" + backoffLinear},
+		{"SubmitPolicy.Jitter", cfg.SubmitPolicy.Jitter, "bool"},
+		{"SubmitPolicy.AttemptTimeout", cfg.SubmitPolicy.AttemptTimeout, "duration"},
+		{"SubmitPolicy.Timeout", cfg.SubmitPolicy.Timeout, "duration"},
+		{"SubmitPolicy.DialTimeout", cfg.SubmitPolicy.DialTimeout, "duration"},
+		{"SubmitPolicy.RetryStatusCodes", cfg.SubmitPolicy.RetryStatusCodes, "status_codes"},
+	}
+	settings = append(settings, checkManagerSettings("CheckManager.", &cfg.CheckManager)...)
+
+	for i, d := range cfg.Destinations {
+		prefix := fmt.Sprintf("Destinations[%d].", i)
+		settings = append(settings, checkManagerSettings(prefix+"CheckManager.", &d.CheckManager)...)
+		settings = append(settings, setting{prefix + "MetricFilter", d.MetricFilter, "regexp"})
+	}
+
+	var errs []error
+	policyValid := true
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+		if err := checkSetting(s.check, s.value); err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid %s %q", s.name, s.value))
+			if strings.HasPrefix(s.name, "SubmitPolicy.") {
+				policyValid = false
+			}
+		}
+	}
+
+	// settings which depend on each other, the submit policy is only
+	// checked as a whole when each of its settings is valid
+	if policyValid {
+		if _, err := newSubmitPolicy(cfg.SubmitPolicy, 0); err != nil {
+			errs = append(errs, errors.Wrap(err, "invalid SubmitPolicy"))
+		}
+	}
+	if cfg.CheckManager.API.TokenKey == "" && cfg.CheckManager.Check.SubmissionURL == "" {
+		errs = append(errs, errors.New("invalid CheckManager (no API token and no submission url)"))
+	}
+	for i, d := range cfg.Destinations {
+		if d.CheckManager.API.TokenKey == "" && d.CheckManager.Check.SubmissionURL == "" && d.SubmissionURL == "" {
+			errs = append(errs, errors.Errorf("invalid Destinations[%d] (no API token and no submission url)", i))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+
+	return nil
+}
+
+// checkManagerSettings returns the check manager string options
+func checkManagerSettings(prefix string, cfg *checkmgr.Config) []setting {
+	return []setting{
+		{prefix + "Check.ID", cfg.Check.ID, "uint"},
+		{prefix + "Check.MaxURLAge", cfg.Check.MaxURLAge, "duration"},
+		{prefix + "Check.ForceMetricActivation", cfg.Check.ForceMetricActivation, "bool"},
+		{prefix + "Check.Shards", cfg.Check.Shards, "count"},
+		{prefix + "Broker.ID", cfg.Broker.ID, "uint"},
+		{prefix + "Broker.MaxResponseTime", cfg.Broker.MaxResponseTime, "duration"},
+	}
+}
+
+// checkSetting validates a setting value, check is one of:
+//
+//	bool         true|false (see strconv.ParseBool)
+//	int          an integer
+//	uint         an integer >= 0
+//	count        an integer >= 1
+//	duration     a duration >= 0 (see time.ParseDuration)
+//	resolution   a duration >= 1ms
+//	status_codes http status codes and ranges (e.g. 429,500-599)
+//	regexp       a regular expression
+//	a|b|c        one of the listed values
+func checkSetting(check, val string) error {
+	switch check {
+	case "":
+		return nil
+	case "bool":
+		_, err := strconv.ParseBool(val)
+		return err
+	case "int":
+		_, err := strconv.Atoi(val)
+		return err
+	case "uint", "count":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return err
+		}
+		if check == "uint" && n < 0 {
+			return errors.New("must be >= 0")
+		}
+		if check == "count" && n < 1 {
+			return errors.New("must be >= 1")
+		}
+		return nil
+	case "duration":
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return errors.New("must be >= 0")
+		}
+		return nil
+	case "resolution":
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		if d < time.Millisecond {
+			return errors.New("must be >= 1ms")
+		}
+		return nil
+	case "timer_units":
+		_, err := parseTimerUnits(val)
+		return err
+	case "status_codes":
+		_, err := parseStatusCodes(val)
+		return err
+	case "regexp":
+		_, err := regexp.Compile(val)
+		return err
+	case "graphite_url":
+		_, err := parseGraphiteURL(val)
+		return err
+	case "influx_url":
+		_, err := parseInfluxURL(val)
+		return err
+	}
+
+	for _, allowed := range strings.Split(check, "|") {
+		if val == allowed {
+			return nil
+		}
+	}
+	return errors.Errorf("expected one of %s", check)
+}
@@ -0,0 +1,184 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Log("Testing NewConfig")
+
+	cfg := NewConfig(
+		WithInterval(90*time.Second),
+		WithResetCounters(false),
+		WithResetGauges(true),
+		WithTimerUnits(time.Millisecond),
+		WithSubmitWorkers(2),
+		WithCheckID(1234),
+		WithCheckSearchTag("service:test", "env:dev"),
+		WithCheckMaxURLAge(time.Minute),
+		WithForceMetricActivation(true),
+		WithBrokerID(5),
+		WithBrokerMaxResponseTime(500*time.Millisecond),
+		WithSubmissionURL("http://127.0.0.1:2609/write/test"),
+		WithDestination(Destination{SubmissionURL: "http://127.0.0.1:2609/write/test"}),
+	)
+
+	tests := []struct{ have, want string }{
+		{cfg.Interval, "1m30s"},
+		{cfg.ResetCounters, "false"},
+		{cfg.ResetGauges, "true"},
+		{cfg.TimerUnits, "ms"},
+		{cfg.SubmitWorkers, "2"},
+		{cfg.CheckManager.Check.ID, "1234"},
+		{cfg.CheckManager.Check.SearchTag, "service:test,env:dev"},
+		{cfg.CheckManager.Check.MaxURLAge, "1m0s"},
+		{cfg.CheckManager.Check.ForceMetricActivation, "true"},
+		{cfg.CheckManager.Broker.ID, "5"},
+		{cfg.CheckManager.Broker.MaxResponseTime, "500ms"},
+	}
+	for _, test := range tests {
+		if test.have != test.want {
+			t.Fatalf("Expected '%s', got '%s'", test.want, test.have)
+		}
+	}
+	if len(cfg.Destinations) != 1 {
+		t.Fatalf("Expected 1 destination, got %d", len(cfg.Destinations))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("Testing Validate")
+
+	t.Log("nil config")
+	{
+		var cfg *Config
+		if err := cfg.Validate(); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("submission url only")
+	{
+		cfg := &Config{}
+		cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	t.Log("no api token and no submission url")
+	{
+		if err := (&Config{}).Validate(); err == nil || !strings.Contains(err.Error(), "invalid CheckManager ") {
+			t.Fatalf("Expected CheckManager error, got '%v'", err)
+		}
+	}
+
+	t.Log("retry wait max < retry wait min")
+	{
+		cfg := &Config{}
+		cfg.CheckManager.API.TokenKey = "abc"
+		cfg.SubmitPolicy.RetryWaitMin = "2s"
+		cfg.SubmitPolicy.RetryWaitMax = "1s"
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid SubmitPolicy: ") {
+			t.Fatalf("Expected SubmitPolicy error, got '%v'", err)
+		}
+	}
+
+	t.Log("all problems reported")
+	{
+		cfg := &Config{
+			Interval:      "10",
+			ResetCounters: "yes",
+			TimerUnits:    "ns",
+			SubmitWorkers: "0",
+		}
+		cfg.SubmitPolicy.RetryStatusCodes = "5xx"
+		cfg.CheckManager.Check.ID = "abc"
+		cfg.CheckManager.Check.MaxURLAge = "-1m"
+		cfg.CheckManager.Broker.MaxResponseTime = "500"
+		cfg.Destinations = []Destination{{MetricFilter: "^(foo"}}
+		cfg.Destinations[0].CheckManager.Broker.ID = "-1"
+
+		err := cfg.Validate()
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		cerr, ok := err.(*ConfigError)
+		if !ok {
+			t.Fatalf("Expected *ConfigError, got %T", err)
+		}
+
+		names := []string{
+			"Interval",
+			"ResetCounters",
+			"TimerUnits",
+			"SubmitWorkers",
+			"SubmitPolicy.RetryStatusCodes",
+			"CheckManager.Check.ID",
+			"CheckManager.Check.MaxURLAge",
+			"CheckManager.Broker.MaxResponseTime",
+			"Destinations[0].CheckManager.Broker.ID",
+			"Destinations[0].MetricFilter",
+			"CheckManager",
+			"Destinations[0]",
+		}
+		if len(cerr.Errors) != len(names) {
+			t.Fatalf("Expected %d errors, got %d: %v", len(names), len(cerr.Errors), err)
+		}
+		for i, name := range names {
+			if !strings.Contains(cerr.Errors[i].Error(), "invalid "+name+" ") {
+				t.Fatalf("Expected error for %s, got '%v'", name, cerr.Errors[i])
+			}
+		}
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	t.Log("Testing NewWithOptions")
+
+	t.Log("invalid options")
+	{
+		_, err := NewWithOptions(WithSubmissionURL("http://127.0.0.1:2609/write/test"), WithTimerUnits(time.Nanosecond), WithSubmitWorkers(0), WithCheckShards(-1))
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if cerr, ok := err.(*ConfigError); !ok || len(cerr.Errors) != 3 {
+			t.Fatalf("Expected 3 errors, got '%v'", err)
+		}
+	}
+
+	t.Log("valid options")
+	{
+		cm, err := NewWithOptions(
+			WithSubmissionURL("http://127.0.0.1:2609/write/test"),
+			WithInterval(0),
+			WithResetGauges(false),
+			WithTimerUnits(time.Microsecond),
+		)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if cm.flushInterval != 0 {
+			t.Fatalf("Expected flush interval 0, got %v", cm.flushInterval)
+		}
+		if cm.resetGauges {
+			t.Fatal("Expected reset gauges false")
+		}
+		if !cm.resetCounters {
+			t.Fatal("Expected reset counters default true")
+		}
+		if cm.timerUnits != time.Microsecond {
+			t.Fatalf("Expected timer units us, got %v", cm.timerUnits)
+		}
+	}
+}
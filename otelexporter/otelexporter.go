@@ -0,0 +1,303 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package otelexporter provides an OpenTelemetry metrics SDK exporter which
+// records metrics in a CirconusMetrics instance. Metrics are submitted to
+// the same check, through the check manager and trap, with the metrics
+// recorded directly through circonus-gometrics.
+//
+// Usage:
+//
+//	cmc := &cgm.Config{}
+//	cmc.CheckManager.API.TokenKey = os.Getenv("CIRCONUS_API_TOKEN")
+//	metrics, err := cgm.New(cmc)
+//	...
+//	exporter, err := otelexporter.New(metrics)
+//	...
+//	provider := metric.NewMeterProvider(metric.WithReader(metric.NewPeriodicReader(exporter)))
+//	otel.SetMeterProvider(provider)
+//
+// Conversions:
+//
+//	monotonic sums are counters (float counters for float64 values)
+//	non-monotonic sums are up/down counters (gauges for float64 values)
+//	gauges are gauges
+//	histograms and exponential histograms are histograms
+//
+// Data point attributes become stream tags on the metric name. Histogram
+// buckets are recorded in circonusllhist bins at the bucket midpoint (the
+// min/max for the unbounded explicit buckets). Histograms must be delta
+// temporality (the temporality selected by the exporter), summaries are not
+// supported.
+package otelexporter
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+
+	cgm "github.com/circonus-labs/circonus-gometrics"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Exporter records OpenTelemetry metrics in a CirconusMetrics instance
+type Exporter struct {
+	metrics    *cgm.CirconusMetrics
+	shutdown   bool
+	shutdownmu sync.RWMutex
+}
+
+// ensure Exporter satisfies the sdk interface
+var _ metric.Exporter = (*Exporter)(nil)
+
+// New returns an exporter recording metrics in m
+func New(m *cgm.CirconusMetrics) (*Exporter, error) {
+	if m == nil {
+		return nil, errors.New("invalid circonus metrics (nil)")
+	}
+
+	return &Exporter{metrics: m}, nil
+}
+
+// Temporality returns delta temporality for counters and histograms,
+// cumulative for up/down counters and gauges
+func (e *Exporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindCounter, metric.InstrumentKindObservableCounter, metric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
+// Aggregation returns the default aggregation for kind
+func (e *Exporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(kind)
+}
+
+// Export records the metrics, they are submitted with the next flush.
+// Unsupported metrics are skipped and reported in the returned error.
+func (e *Exporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.shutdownmu.RLock()
+	defer e.shutdownmu.RUnlock()
+	if e.shutdown {
+		return errors.New("exporter is shut down")
+	}
+
+	if rm == nil {
+		return nil
+	}
+
+	var skipped []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if !e.record(m) {
+				skipped = append(skipped, m.Name)
+			}
+		}
+	}
+
+	if len(skipped) > 0 {
+		return errors.Errorf("unsupported metrics skipped (%s)", strings.Join(skipped, ","))
+	}
+
+	return nil
+}
+
+// ForceFlush flushes the recorded metrics
+func (e *Exporter) ForceFlush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.metrics.Flush()
+
+	return nil
+}
+
+// Shutdown flushes the recorded metrics, subsequent exports fail
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.shutdownmu.Lock()
+	if e.shutdown {
+		e.shutdownmu.Unlock()
+		return nil
+	}
+	e.shutdown = true
+	e.shutdownmu.Unlock()
+
+	return e.ForceFlush(ctx)
+}
+
+// record records a metric, returns false if the aggregation is unsupported
+func (e *Exporter) record(m metricdata.Metrics) bool {
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		recordSum(e.metrics, m.Name, data)
+	case metricdata.Sum[float64]:
+		recordSum(e.metrics, m.Name, data)
+	case metricdata.Gauge[int64]:
+		recordGauge(e.metrics, m.Name, data)
+	case metricdata.Gauge[float64]:
+		recordGauge(e.metrics, m.Name, data)
+	case metricdata.Histogram[int64]:
+		return recordHistogram(e.metrics, m.Name, data)
+	case metricdata.Histogram[float64]:
+		return recordHistogram(e.metrics, m.Name, data)
+	case metricdata.ExponentialHistogram[int64]:
+		return recordExponentialHistogram(e.metrics, m.Name, data)
+	case metricdata.ExponentialHistogram[float64]:
+		return recordExponentialHistogram(e.metrics, m.Name, data)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// recordSum records sum data points as counters
+func recordSum[N int64 | float64](m *cgm.CirconusMetrics, name string, data metricdata.Sum[N]) {
+	delta := data.Temporality == metricdata.DeltaTemporality
+
+	for _, dp := range data.DataPoints {
+		metric := metricName(name, dp.Attributes)
+		switch v := any(dp.Value).(type) {
+		case int64:
+			switch {
+			case data.IsMonotonic && delta:
+				if v > 0 {
+					m.Add(metric, uint64(v))
+				}
+			case data.IsMonotonic:
+				if v >= 0 {
+					m.Set(metric, uint64(v))
+				}
+			case delta:
+				m.AddUpDown(metric, v)
+			default:
+				m.SetUpDown(metric, v)
+			}
+		case float64:
+			switch {
+			case data.IsMonotonic && delta:
+				m.AddFloat(metric, v)
+			case data.IsMonotonic:
+				m.SetFloat(metric, v)
+			case delta:
+				m.AddGauge(metric, v)
+			default:
+				m.SetGauge(metric, v)
+			}
+		}
+	}
+}
+
+// recordGauge records gauge data points as gauges
+func recordGauge[N int64 | float64](m *cgm.CirconusMetrics, name string, data metricdata.Gauge[N]) {
+	for _, dp := range data.DataPoints {
+		m.SetGauge(metricName(name, dp.Attributes), dp.Value)
+	}
+}
+
+// recordHistogram records the explicit bucket counts of delta histogram data
+// points, returns false for cumulative histograms
+func recordHistogram[N int64 | float64](m *cgm.CirconusMetrics, name string, data metricdata.Histogram[N]) bool {
+	if data.Temporality != metricdata.DeltaTemporality {
+		return false
+	}
+
+	for _, dp := range data.DataPoints {
+		metric := metricName(name, dp.Attributes)
+		min, hasMin := dp.Min.Value()
+		max, hasMax := dp.Max.Value()
+
+		for i, count := range dp.BucketCounts {
+			if count == 0 {
+				continue
+			}
+
+			var val float64
+			switch {
+			case len(dp.Bounds) == 0:
+				val = float64(dp.Sum) / float64(dp.Count)
+			case i == 0:
+				val = dp.Bounds[0]
+				if hasMin {
+					val = float64(min)
+				}
+			case i >= len(dp.Bounds):
+				val = dp.Bounds[len(dp.Bounds)-1]
+				if hasMax {
+					val = float64(max)
+				}
+			default:
+				val = (dp.Bounds[i-1] + dp.Bounds[i]) / 2
+			}
+
+			m.RecordCountForValue(metric, val, int64(count))
+		}
+	}
+
+	return true
+}
+
+// recordExponentialHistogram records the bucket counts of delta exponential
+// histogram data points, returns false for cumulative histograms
+func recordExponentialHistogram[N int64 | float64](m *cgm.CirconusMetrics, name string, data metricdata.ExponentialHistogram[N]) bool {
+	if data.Temporality != metricdata.DeltaTemporality {
+		return false
+	}
+
+	for _, dp := range data.DataPoints {
+		metric := metricName(name, dp.Attributes)
+
+		if dp.ZeroCount > 0 {
+			m.RecordCountForValue(metric, 0, int64(dp.ZeroCount))
+		}
+		recordExponentialBuckets(m, metric, dp.Scale, dp.PositiveBucket, 1)
+		recordExponentialBuckets(m, metric, dp.Scale, dp.NegativeBucket, -1)
+	}
+
+	return true
+}
+
+// recordExponentialBuckets records the bucket counts at the bucket midpoints,
+// bucket index i covers (base^i, base^(i+1)] where base is 2^(2^-scale)
+func recordExponentialBuckets(m *cgm.CirconusMetrics, metric string, scale int32, bucket metricdata.ExponentialBucket, sign float64) {
+	width := math.Exp2(-float64(scale))
+
+	for i, count := range bucket.Counts {
+		if count == 0 {
+			continue
+		}
+
+		index := float64(bucket.Offset) + float64(i)
+		lower := math.Exp2(index * width)
+		upper := math.Exp2((index + 1) * width)
+
+		m.RecordCountForValue(metric, sign*(lower+upper)/2, int64(count))
+	}
+}
+
+// metricName returns name with the attributes as stream tags
+func metricName(name string, attrs attribute.Set) string {
+	if attrs.Len() == 0 {
+		return name
+	}
+
+	tags := make(cgm.Tags, 0, attrs.Len())
+	for iter := attrs.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		tags = append(tags, cgm.Tag{Category: string(kv.Key), Value: kv.Value.Emit()})
+	}
+
+	return cgm.MetricNameWithStreamTags(name, tags)
+}
@@ -0,0 +1,241 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otelexporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// testBroker accepts submissions, the first pending submission is sent to submissions
+func testBroker(submissions chan map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var v map[string]interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		select {
+		case submissions <- v:
+		default:
+		}
+		fmt.Fprintf(w, `{"stats":%d}`, len(v))
+	}))
+}
+
+func testMetrics(t *testing.T, submissionURL string) *cgm.CirconusMetrics {
+	cfg := &cgm.Config{Interval: "0"}
+	cfg.CheckManager.Check.SubmissionURL = submissionURL
+	m, err := cgm.New(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	return m
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	t.Log("invalid metrics (nil)")
+	{
+		if _, err := New(nil); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("valid")
+	{
+		e, err := New(testMetrics(t, "http://127.0.0.1:2609/write/test"))
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if tm := e.Temporality(metric.InstrumentKindCounter); tm != metricdata.DeltaTemporality {
+			t.Fatalf("Expected delta counters, got %s", tm)
+		}
+		if tm := e.Temporality(metric.InstrumentKindUpDownCounter); tm != metricdata.CumulativeTemporality {
+			t.Fatalf("Expected cumulative up/down counters, got %s", tm)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Log("Testing Export")
+
+	server := testBroker(nil)
+	defer server.Close()
+
+	m := testMetrics(t, server.URL)
+	e, err := New(m)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	attrs := attribute.NewSet(attribute.String("host", "web1"), attribute.Int("shard", 2))
+	now := time.Now()
+
+	rm := &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{
+				{Name: "requests", Data: metricdata.Sum[int64]{
+					Temporality: metricdata.DeltaTemporality,
+					IsMonotonic: true,
+					DataPoints:  []metricdata.DataPoint[int64]{{Attributes: attrs, Time: now, Value: 5}},
+				}},
+				{Name: "cpu_seconds", Data: metricdata.Sum[float64]{
+					Temporality: metricdata.CumulativeTemporality,
+					IsMonotonic: true,
+					DataPoints:  []metricdata.DataPoint[float64]{{Time: now, Value: 1.5}},
+				}},
+				{Name: "in_flight", Data: metricdata.Sum[int64]{
+					Temporality: metricdata.CumulativeTemporality,
+					DataPoints:  []metricdata.DataPoint[int64]{{Time: now, Value: -3}},
+				}},
+				{Name: "temperature", Data: metricdata.Gauge[float64]{
+					DataPoints: []metricdata.DataPoint[float64]{{Time: now, Value: 21.5}},
+				}},
+				{Name: "latency", Data: metricdata.Histogram[float64]{
+					Temporality: metricdata.DeltaTemporality,
+					DataPoints: []metricdata.HistogramDataPoint[float64]{{
+						Time:         now,
+						Count:        6,
+						Bounds:       []float64{1, 3},
+						BucketCounts: []uint64{1, 2, 3},
+						Min:          metricdata.NewExtrema(0.5),
+						Max:          metricdata.NewExtrema(10.0),
+					}},
+				}},
+				{Name: "size", Data: metricdata.ExponentialHistogram[int64]{
+					Temporality: metricdata.DeltaTemporality,
+					DataPoints: []metricdata.ExponentialHistogramDataPoint[int64]{{
+						Time:           now,
+						Count:          5,
+						Scale:          0,
+						ZeroCount:      1,
+						PositiveBucket: metricdata.ExponentialBucket{Offset: 1, Counts: []uint64{2, 0, 1}},
+						NegativeBucket: metricdata.ExponentialBucket{Offset: 0, Counts: []uint64{1}},
+					}},
+				}},
+			},
+		}},
+	}
+
+	if err := e.Export(context.Background(), rm); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	histograms := map[string]string{
+		"latency": "[H[5.0e-01]=1 H[2.0e+00]=2 H[1.0e+01]=3]",
+		"size":    "[H[0.0e+00]=1 H[-1.5e+00]=1 H[3.0e+00]=2 H[1.2e+01]=1]",
+	}
+	for name, want := range histograms {
+		have, err := m.GetHistogramTest(name)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if fmt.Sprint(have) != want {
+			t.Fatalf("Expected %s to be %s, got %v", name, want, have)
+		}
+	}
+
+	metrics := *m.FlushMetrics()
+	expect := map[string]cgm.Metric{
+		"requests|ST[host:web1,shard:2]": {Type: "L", Value: uint64(5)},
+		"cpu_seconds":                    {Type: "n", Value: 1.5},
+		"in_flight":                      {Type: "l", Value: int64(-3)},
+		"temperature":                    {Type: "n", Value: 21.5},
+	}
+	for name, want := range expect {
+		have, ok := metrics[name]
+		if !ok {
+			t.Fatalf("Expected metric %s, got %v", name, metrics)
+		}
+		if have.Type != want.Type || have.Value != want.Value {
+			t.Fatalf("Expected %s to be %+v, got %+v", name, want, have)
+		}
+	}
+
+	t.Log("unsupported metrics")
+	{
+		rm := &metricdata.ResourceMetrics{
+			ScopeMetrics: []metricdata.ScopeMetrics{{
+				Metrics: []metricdata.Metrics{
+					{Name: "cumulative_latency", Data: metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality}},
+					{Name: "summary", Data: metricdata.Summary{}},
+				},
+			}},
+		}
+		err := e.Export(context.Background(), rm)
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if !strings.Contains(err.Error(), "cumulative_latency,summary") {
+			t.Fatalf("Expected skipped metrics in error, got '%v'", err)
+		}
+	}
+
+	t.Log("shut down")
+	{
+		if err := e.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if err := e.Export(context.Background(), rm); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
+
+func TestMeterProvider(t *testing.T) {
+	t.Log("Testing exporter with a meter provider")
+
+	submissions := make(chan map[string]interface{}, 1)
+	server := testBroker(submissions)
+	defer server.Close()
+
+	m := testMetrics(t, server.URL)
+	for !m.Ready() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	e, err := New(m)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	provider := metric.NewMeterProvider(metric.WithReader(metric.NewPeriodicReader(e, metric.WithInterval(time.Hour))))
+	defer provider.Shutdown(context.Background())
+
+	counter, err := provider.Meter("test").Int64Counter("jobs")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	counter.Add(context.Background(), 3, otelmetric.WithAttributes(attribute.String("queue", "default")))
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	select {
+	case v := <-submissions:
+		if _, ok := v["jobs|ST[queue:default]"]; !ok {
+			t.Fatalf("Expected jobs|ST[queue:default], got %v", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a submission")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlpreceiver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// export converts the request and records the metrics, returns the number
+// of rejected data points
+func (r *Receiver) export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (int64, error) {
+	rm := &metricdata.ResourceMetrics{}
+	var rejected int64
+
+	r.evictState(time.Now())
+
+	for _, rms := range req.GetResourceMetrics() {
+		var resAttrs []attribute.KeyValue
+		for _, kv := range rms.GetResource().GetAttributes() {
+			if r.resourceAttrs[kv.GetKey()] {
+				resAttrs = append(resAttrs, keyValue(kv))
+			}
+		}
+
+		for _, sms := range rms.GetScopeMetrics() {
+			sm := metricdata.ScopeMetrics{}
+			for _, m := range sms.GetMetrics() {
+				metrics, n := r.convertMetric(m, resAttrs)
+				sm.Metrics = append(sm.Metrics, metrics...)
+				rejected += n
+			}
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+		}
+	}
+
+	return rejected, r.exporter.Export(ctx, rm)
+}
+
+// convertMetric returns the metric as sdk metric data (int and double data
+// points are separate metrics) and the number of rejected data points
+func (r *Receiver) convertMetric(m *metricpb.Metric, resAttrs []attribute.KeyValue) ([]metricdata.Metrics, int64) {
+	var metrics []metricdata.Metrics
+	add := func(data metricdata.Aggregation) {
+		metrics = append(metrics, metricdata.Metrics{Name: m.GetName(), Description: m.GetDescription(), Unit: m.GetUnit(), Data: data})
+	}
+
+	switch data := m.GetData().(type) {
+	case *metricpb.Metric_Gauge:
+		ints, floats := numberDataPoints(data.Gauge.GetDataPoints(), resAttrs)
+		if len(ints) > 0 {
+			add(metricdata.Gauge[int64]{DataPoints: ints})
+		}
+		if len(floats) > 0 {
+			add(metricdata.Gauge[float64]{DataPoints: floats})
+		}
+
+	case *metricpb.Metric_Sum:
+		temporality, ok := convertTemporality(data.Sum.GetAggregationTemporality())
+		if !ok {
+			return nil, int64(len(data.Sum.GetDataPoints()))
+		}
+		ints, floats := numberDataPoints(data.Sum.GetDataPoints(), resAttrs)
+		if temporality == metricdata.CumulativeTemporality && data.Sum.GetIsMonotonic() {
+			ints = sumDeltas(r, m.GetName(), ints)
+			floats = sumDeltas(r, m.GetName(), floats)
+			temporality = metricdata.DeltaTemporality
+		}
+		if len(ints) > 0 {
+			add(metricdata.Sum[int64]{DataPoints: ints, Temporality: temporality, IsMonotonic: data.Sum.GetIsMonotonic()})
+		}
+		if len(floats) > 0 {
+			add(metricdata.Sum[float64]{DataPoints: floats, Temporality: temporality, IsMonotonic: data.Sum.GetIsMonotonic()})
+		}
+
+	case *metricpb.Metric_Histogram:
+		temporality, ok := convertTemporality(data.Histogram.GetAggregationTemporality())
+		if !ok {
+			return nil, int64(len(data.Histogram.GetDataPoints()))
+		}
+		hist := metricdata.Histogram[float64]{Temporality: metricdata.DeltaTemporality}
+		for _, dp := range data.Histogram.GetDataPoints() {
+			hdp := histogramDataPoint(dp, resAttrs)
+			if temporality == metricdata.CumulativeTemporality {
+				var ok bool
+				if hdp, ok = r.histogramDelta(m.GetName(), hdp); !ok {
+					continue
+				}
+			}
+			hist.DataPoints = append(hist.DataPoints, hdp)
+		}
+		add(hist)
+
+	case *metricpb.Metric_ExponentialHistogram:
+		temporality, ok := convertTemporality(data.ExponentialHistogram.GetAggregationTemporality())
+		if !ok {
+			return nil, int64(len(data.ExponentialHistogram.GetDataPoints()))
+		}
+		hist := metricdata.ExponentialHistogram[float64]{Temporality: metricdata.DeltaTemporality}
+		for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+			edp := exponentialHistogramDataPoint(dp, resAttrs)
+			if temporality == metricdata.CumulativeTemporality {
+				var ok bool
+				if edp, ok = r.exponentialHistogramDelta(m.GetName(), edp); !ok {
+					continue
+				}
+			}
+			hist.DataPoints = append(hist.DataPoints, edp)
+		}
+		add(hist)
+
+	case *metricpb.Metric_Summary:
+		return nil, int64(len(data.Summary.GetDataPoints()))
+	}
+
+	return metrics, 0
+}
+
+// convertTemporality returns the sdk temporality, false if unspecified
+func convertTemporality(t metricpb.AggregationTemporality) (metricdata.Temporality, bool) {
+	switch t {
+	case metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+		return metricdata.DeltaTemporality, true
+	case metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+		return metricdata.CumulativeTemporality, true
+	default:
+		return metricdata.Temporality(0), false
+	}
+}
+
+// numberDataPoints returns the int and double data points
+func numberDataPoints(dps []*metricpb.NumberDataPoint, resAttrs []attribute.KeyValue) ([]metricdata.DataPoint[int64], []metricdata.DataPoint[float64]) {
+	var ints []metricdata.DataPoint[int64]
+	var floats []metricdata.DataPoint[float64]
+
+	for _, dp := range dps {
+		attrs := attributeSet(resAttrs, dp.GetAttributes())
+		start, ts := unixNano(dp.GetStartTimeUnixNano()), unixNano(dp.GetTimeUnixNano())
+		switch v := dp.GetValue().(type) {
+		case *metricpb.NumberDataPoint_AsInt:
+			ints = append(ints, metricdata.DataPoint[int64]{Attributes: attrs, StartTime: start, Time: ts, Value: v.AsInt})
+		case *metricpb.NumberDataPoint_AsDouble:
+			floats = append(floats, metricdata.DataPoint[float64]{Attributes: attrs, StartTime: start, Time: ts, Value: v.AsDouble})
+		}
+	}
+
+	return ints, floats
+}
+
+// histogramDataPoint returns the sdk histogram data point
+func histogramDataPoint(dp *metricpb.HistogramDataPoint, resAttrs []attribute.KeyValue) metricdata.HistogramDataPoint[float64] {
+	hdp := metricdata.HistogramDataPoint[float64]{
+		Attributes:   attributeSet(resAttrs, dp.GetAttributes()),
+		StartTime:    unixNano(dp.GetStartTimeUnixNano()),
+		Time:         unixNano(dp.GetTimeUnixNano()),
+		Count:        dp.GetCount(),
+		Sum:          dp.GetSum(),
+		Bounds:       dp.GetExplicitBounds(),
+		BucketCounts: dp.GetBucketCounts(),
+	}
+	if dp.Min != nil {
+		hdp.Min = metricdata.NewExtrema(dp.GetMin())
+	}
+	if dp.Max != nil {
+		hdp.Max = metricdata.NewExtrema(dp.GetMax())
+	}
+	return hdp
+}
+
+// exponentialHistogramDataPoint returns the sdk exponential histogram data point
+func exponentialHistogramDataPoint(dp *metricpb.ExponentialHistogramDataPoint, resAttrs []attribute.KeyValue) metricdata.ExponentialHistogramDataPoint[float64] {
+	edp := metricdata.ExponentialHistogramDataPoint[float64]{
+		Attributes:    attributeSet(resAttrs, dp.GetAttributes()),
+		StartTime:     unixNano(dp.GetStartTimeUnixNano()),
+		Time:          unixNano(dp.GetTimeUnixNano()),
+		Count:         dp.GetCount(),
+		Sum:           dp.GetSum(),
+		Scale:         dp.GetScale(),
+		ZeroCount:     dp.GetZeroCount(),
+		ZeroThreshold: dp.GetZeroThreshold(),
+		PositiveBucket: metricdata.ExponentialBucket{
+			Offset: dp.GetPositive().GetOffset(),
+			Counts: dp.GetPositive().GetBucketCounts(),
+		},
+		NegativeBucket: metricdata.ExponentialBucket{
+			Offset: dp.GetNegative().GetOffset(),
+			Counts: dp.GetNegative().GetBucketCounts(),
+		},
+	}
+	if dp.Min != nil {
+		edp.Min = metricdata.NewExtrema(dp.GetMin())
+	}
+	if dp.Max != nil {
+		edp.Max = metricdata.NewExtrema(dp.GetMax())
+	}
+	return edp
+}
+
+// attributeSet returns the resource and data point attributes, data point
+// attributes take precedence
+func attributeSet(resAttrs []attribute.KeyValue, kvs []*commonpb.KeyValue) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, len(resAttrs)+len(kvs))
+	attrs = append(attrs, resAttrs...)
+	for _, kv := range kvs {
+		attrs = append(attrs, keyValue(kv))
+	}
+	return attribute.NewSet(attrs...)
+}
+
+// keyValue returns the attribute, bytes are base64 encoded and arrays and
+// key/value lists are json encoded
+func keyValue(kv *commonpb.KeyValue) attribute.KeyValue {
+	key := attribute.Key(kv.GetKey())
+
+	switch v := kv.GetValue().GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return key.String(v.StringValue)
+	case *commonpb.AnyValue_BoolValue:
+		return key.Bool(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return key.Int64(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return key.Float64(v.DoubleValue)
+	case *commonpb.AnyValue_BytesValue:
+		return key.String(base64.StdEncoding.EncodeToString(v.BytesValue))
+	case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+		data, _ := json.Marshal(anyValue(kv.GetValue()))
+		return key.String(string(data))
+	default:
+		return key.String("")
+	}
+}
+
+// anyValue returns the value for json encoding
+func anyValue(av *commonpb.AnyValue) interface{} {
+	switch v := av.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, value := range v.ArrayValue.GetValues() {
+			values = append(values, anyValue(value))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		values := make(map[string]interface{}, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.GetKey()] = anyValue(kv.GetValue())
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// unixNano returns the time for a unix nanosecond timestamp
+func unixNano(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns))
+}
@@ -0,0 +1,279 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlpreceiver
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Cumulative monotonic sums and histograms are converted to delta using
+// the previous export of each series (metric name and attributes). A
+// series with a new start time, a decreasing value, different bounds or
+// decreasing counts has been reset, all of its value (counts) is new.
+// Series not exported for the state ttl are forgotten.
+
+// sumState is the previous export of a cumulative monotonic sum
+type sumState struct {
+	start    time.Time
+	seen     time.Time
+	value    float64
+	intValue int64
+}
+
+// histogramState is the previous export of a cumulative histogram
+type histogramState struct {
+	start     time.Time
+	seen      time.Time
+	count     uint64
+	sum       float64
+	bounds    []float64
+	counts    []uint64
+	scale     int32
+	zeroCount uint64
+	positive  metricdata.ExponentialBucket
+	negative  metricdata.ExponentialBucket
+}
+
+// sumDeltas returns the deltas since the previous export of each sum data
+// point, baselines are dropped
+func sumDeltas[N int64 | float64](r *Receiver, name string, dps []metricdata.DataPoint[N]) []metricdata.DataPoint[N] {
+	deltas := dps[:0]
+	for _, dp := range dps {
+		if delta, ok := sumDelta(r, name, dp); ok {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas
+}
+
+// sumDelta returns the delta since the previous export of the sum, false
+// if dp is the baseline (the first export of a sum started before the
+// receiver)
+func sumDelta[N int64 | float64](r *Receiver, name string, dp metricdata.DataPoint[N]) (metricdata.DataPoint[N], bool) {
+	state := &sumState{start: dp.StartTime}
+	switch v := any(dp.Value).(type) {
+	case int64:
+		state.intValue = v
+	case float64:
+		state.value = v
+	}
+
+	prev := r.swapSumState(name, dp.Attributes, state)
+
+	if prev == nil {
+		return dp, r.isNew(dp.StartTime)
+	}
+
+	if !prev.start.Equal(dp.StartTime) {
+		return dp, true
+	}
+
+	delta := dp
+	delta.StartTime = prev.start
+	switch v := any(dp.Value).(type) {
+	case int64:
+		if v < prev.intValue {
+			return dp, true
+		}
+		delta.Value = N(v - prev.intValue)
+	case float64:
+		if v < prev.value {
+			return dp, true
+		}
+		delta.Value = N(v - prev.value)
+	}
+
+	return delta, true
+}
+
+// histogramDelta returns the delta since the previous export of the
+// histogram, false if dp is the baseline (the first export of a histogram
+// started before the receiver)
+func (r *Receiver) histogramDelta(name string, dp metricdata.HistogramDataPoint[float64]) (metricdata.HistogramDataPoint[float64], bool) {
+	prev := r.swapHistogramState(name, dp.Attributes, &histogramState{
+		start:  dp.StartTime,
+		count:  dp.Count,
+		sum:    dp.Sum,
+		bounds: dp.Bounds,
+		counts: dp.BucketCounts,
+	})
+
+	if prev == nil {
+		return dp, r.isNew(dp.StartTime)
+	}
+
+	if !prev.start.Equal(dp.StartTime) || !equalBounds(prev.bounds, dp.Bounds) || len(prev.counts) != len(dp.BucketCounts) || dp.Count < prev.count {
+		return dp, true
+	}
+
+	counts := make([]uint64, len(dp.BucketCounts))
+	for i, count := range dp.BucketCounts {
+		if count < prev.counts[i] {
+			return dp, true
+		}
+		counts[i] = count - prev.counts[i]
+	}
+
+	delta := dp
+	delta.StartTime = prev.start
+	delta.Count = dp.Count - prev.count
+	delta.Sum = dp.Sum - prev.sum
+	delta.BucketCounts = counts
+	delta.Min = metricdata.Extrema[float64]{}
+	delta.Max = metricdata.Extrema[float64]{}
+
+	return delta, true
+}
+
+// exponentialHistogramDelta returns the delta since the previous export of
+// the histogram, false if dp is the baseline (the first export of a
+// histogram started before the receiver). The previous buckets are
+// downscaled if the scale has been reduced.
+func (r *Receiver) exponentialHistogramDelta(name string, dp metricdata.ExponentialHistogramDataPoint[float64]) (metricdata.ExponentialHistogramDataPoint[float64], bool) {
+	prev := r.swapHistogramState(name, dp.Attributes, &histogramState{
+		start:     dp.StartTime,
+		count:     dp.Count,
+		sum:       dp.Sum,
+		scale:     dp.Scale,
+		zeroCount: dp.ZeroCount,
+		positive:  dp.PositiveBucket,
+		negative:  dp.NegativeBucket,
+	})
+
+	if prev == nil {
+		return dp, r.isNew(dp.StartTime)
+	}
+
+	if !prev.start.Equal(dp.StartTime) || prev.scale < dp.Scale || dp.Count < prev.count || dp.ZeroCount < prev.zeroCount {
+		return dp, true
+	}
+
+	shift := uint(prev.scale - dp.Scale)
+	positive, ok := bucketDelta(dp.PositiveBucket, prev.positive, shift)
+	if !ok {
+		return dp, true
+	}
+	negative, ok := bucketDelta(dp.NegativeBucket, prev.negative, shift)
+	if !ok {
+		return dp, true
+	}
+
+	delta := dp
+	delta.StartTime = prev.start
+	delta.Count = dp.Count - prev.count
+	delta.Sum = dp.Sum - prev.sum
+	delta.ZeroCount = dp.ZeroCount - prev.zeroCount
+	delta.PositiveBucket = positive
+	delta.NegativeBucket = negative
+	delta.Min = metricdata.Extrema[float64]{}
+	delta.Max = metricdata.Extrema[float64]{}
+
+	return delta, true
+}
+
+// bucketDelta returns cur - prev, prev downscaled by shift, false if
+// any bucket count decreased
+func bucketDelta(cur, prev metricdata.ExponentialBucket, shift uint) (metricdata.ExponentialBucket, bool) {
+	prevCounts := make(map[int32]uint64, len(prev.Counts))
+	for i, count := range prev.Counts {
+		if count > 0 {
+			prevCounts[(prev.Offset+int32(i))>>shift] += count
+		}
+	}
+
+	delta := metricdata.ExponentialBucket{Offset: cur.Offset, Counts: make([]uint64, len(cur.Counts))}
+	for i, count := range cur.Counts {
+		index := cur.Offset + int32(i)
+		if count < prevCounts[index] {
+			return delta, false
+		}
+		delta.Counts[i] = count - prevCounts[index]
+		delete(prevCounts, index)
+	}
+
+	// previous counts outside of the current buckets
+	if len(prevCounts) > 0 {
+		return delta, false
+	}
+
+	return delta, true
+}
+
+// swapSumState stores the state of a sum, returning the previous state
+func (r *Receiver) swapSumState(name string, attrs attribute.Set, state *sumState) *sumState {
+	key := stateKey(name, attrs)
+	state.seen = time.Now()
+
+	r.statemu.Lock()
+	defer r.statemu.Unlock()
+
+	prev := r.sums[key]
+	r.sums[key] = state
+
+	return prev
+}
+
+// swapHistogramState stores the state of a histogram, returning the previous state
+func (r *Receiver) swapHistogramState(name string, attrs attribute.Set, state *histogramState) *histogramState {
+	key := stateKey(name, attrs)
+	state.seen = time.Now()
+
+	r.statemu.Lock()
+	defer r.statemu.Unlock()
+
+	prev := r.histograms[key]
+	r.histograms[key] = state
+
+	return prev
+}
+
+// evictState forgets the series not exported for the state ttl, at most
+// once per ttl
+func (r *Receiver) evictState(now time.Time) {
+	r.statemu.Lock()
+	defer r.statemu.Unlock()
+
+	if now.Sub(r.lastEvict) < r.stateTTL {
+		return
+	}
+	r.lastEvict = now
+
+	for key, state := range r.sums {
+		if now.Sub(state.seen) >= r.stateTTL {
+			delete(r.sums, key)
+		}
+	}
+	for key, state := range r.histograms {
+		if now.Sub(state.seen) >= r.stateTTL {
+			delete(r.histograms, key)
+		}
+	}
+}
+
+// stateKey returns the key of a series, its name and attributes
+func stateKey(name string, attrs attribute.Set) string {
+	return name + "\x00" + attrs.Encoded(attribute.DefaultEncoder())
+}
+
+// isNew returns true if a series started after the receiver
+func (r *Receiver) isNew(start time.Time) bool {
+	return !start.IsZero() && !start.Before(r.started)
+}
+
+// equalBounds returns true if the explicit bucket bounds are the same
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlpreceiver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestHistogramDelta(t *testing.T) {
+	t.Log("Testing histogramDelta")
+
+	r, err := New(&Config{Metrics: testMetrics(t)})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	attrs := attribute.NewSet(attribute.String("host", "web1"))
+	before := r.started.Add(-time.Minute)
+	after := r.started.Add(time.Second)
+
+	dp := func(start time.Time, count uint64, counts ...uint64) metricdata.HistogramDataPoint[float64] {
+		return metricdata.HistogramDataPoint[float64]{
+			Attributes:   attrs,
+			StartTime:    start,
+			Count:        count,
+			Bounds:       []float64{1, 5},
+			BucketCounts: counts,
+			Min:          metricdata.NewExtrema(0.5),
+		}
+	}
+
+	tests := []struct {
+		desc   string
+		dp     metricdata.HistogramDataPoint[float64]
+		ok     bool
+		counts []uint64
+	}{
+		{"baseline (started before receiver)", dp(before, 3, 1, 2, 0), false, nil},
+		{"delta", dp(before, 6, 2, 3, 1), true, []uint64{1, 1, 1}},
+		{"no change", dp(before, 6, 2, 3, 1), true, []uint64{0, 0, 0}},
+		{"reset (new start time)", dp(after, 2, 0, 2, 0), true, []uint64{0, 2, 0}},
+		{"reset (decreasing counts)", dp(after, 3, 1, 1, 1), true, []uint64{1, 1, 1}},
+	}
+
+	for _, test := range tests {
+		delta, ok := r.histogramDelta("latency", test.dp)
+		if ok != test.ok {
+			t.Fatalf("%s: Expected %v, got %v", test.desc, test.ok, ok)
+		}
+		if ok && fmt.Sprint(delta.BucketCounts) != fmt.Sprint(test.counts) {
+			t.Fatalf("%s: Expected %v, got %v", test.desc, test.counts, delta.BucketCounts)
+		}
+	}
+
+	t.Log("series started after receiver")
+	{
+		if _, ok := r.histogramDelta("other", dp(after, 1, 1, 0, 0)); !ok {
+			t.Fatal("Expected first export to be recorded")
+		}
+	}
+}
+
+func TestExponentialHistogramDelta(t *testing.T) {
+	t.Log("Testing exponentialHistogramDelta")
+
+	r, err := New(&Config{Metrics: testMetrics(t)})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	start := r.started.Add(-time.Minute)
+	dp := func(scale int32, count uint64, offset int32, counts ...uint64) metricdata.ExponentialHistogramDataPoint[float64] {
+		return metricdata.ExponentialHistogramDataPoint[float64]{
+			StartTime:      start,
+			Count:          count,
+			Scale:          scale,
+			PositiveBucket: metricdata.ExponentialBucket{Offset: offset, Counts: counts},
+		}
+	}
+
+	tests := []struct {
+		desc   string
+		dp     metricdata.ExponentialHistogramDataPoint[float64]
+		ok     bool
+		offset int32
+		counts []uint64
+	}{
+		{"baseline", dp(1, 3, 2, 1, 2), false, 0, nil},
+		{"delta", dp(1, 5, 1, 1, 2, 2), true, 1, []uint64{1, 1, 0}},
+		// scale 1 buckets 1,2,3 (1,2,2) are scale 0 buckets 0,1 (1,4)
+		{"downscaled", dp(0, 7, 0, 2, 5), true, 0, []uint64{1, 1}},
+		{"reset (bucket dropped)", dp(0, 7, 1, 7), true, 1, []uint64{7}},
+	}
+
+	for _, test := range tests {
+		delta, ok := r.exponentialHistogramDelta("size", test.dp)
+		if ok != test.ok {
+			t.Fatalf("%s: Expected %v, got %v", test.desc, test.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		if delta.PositiveBucket.Offset != test.offset || fmt.Sprint(delta.PositiveBucket.Counts) != fmt.Sprint(test.counts) {
+			t.Fatalf("%s: Expected %d %v, got %d %v", test.desc, test.offset, test.counts, delta.PositiveBucket.Offset, delta.PositiveBucket.Counts)
+		}
+	}
+}
+
+func TestSumDelta(t *testing.T) {
+	t.Log("Testing sumDelta")
+
+	r, err := New(&Config{Metrics: testMetrics(t)})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	attrs := attribute.NewSet(attribute.String("host", "web1"))
+	before := r.started.Add(-time.Minute)
+	after := r.started.Add(time.Second)
+
+	tests := []struct {
+		desc  string
+		start time.Time
+		value int64
+		ok    bool
+		delta int64
+	}{
+		{"baseline (started before receiver)", before, 10, false, 0},
+		{"delta", before, 15, true, 5},
+		{"no change", before, 15, true, 0},
+		{"reset (new start time)", after, 3, true, 3},
+		{"reset (decreasing value)", after, 2, true, 2},
+	}
+
+	for _, test := range tests {
+		delta, ok := sumDelta(r, "requests", metricdata.DataPoint[int64]{Attributes: attrs, StartTime: test.start, Value: test.value})
+		if ok != test.ok {
+			t.Fatalf("%s: Expected %v, got %v", test.desc, test.ok, ok)
+		}
+		if ok && delta.Value != test.delta {
+			t.Fatalf("%s: Expected %d, got %d", test.desc, test.delta, delta.Value)
+		}
+	}
+
+	t.Log("float, attributes are separate series")
+	{
+		other := attribute.NewSet(attribute.String("host", "web2"))
+		dps := []metricdata.DataPoint[float64]{
+			{Attributes: other, StartTime: after, Value: 1.5},
+			{Attributes: attrs, StartTime: before, Value: 2.5},
+		}
+		deltas := sumDeltas(r, "bytes", dps)
+		if len(deltas) != 1 || deltas[0].Value != 1.5 {
+			t.Fatalf("Expected the series started after the receiver, got %v", deltas)
+		}
+		deltas = sumDeltas(r, "bytes", []metricdata.DataPoint[float64]{{Attributes: attrs, StartTime: before, Value: 4}})
+		if len(deltas) != 1 || deltas[0].Value != 1.5 {
+			t.Fatalf("Expected delta 1.5, got %v", deltas)
+		}
+	}
+}
+
+func TestEvictState(t *testing.T) {
+	t.Log("Testing evictState")
+
+	r, err := New(&Config{Metrics: testMetrics(t), StateTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	start := r.started.Add(-time.Hour)
+	sumDelta(r, "requests", metricdata.DataPoint[int64]{StartTime: start, Value: 1})
+	r.histogramDelta("latency", metricdata.HistogramDataPoint[float64]{StartTime: start, Count: 1, Bounds: []float64{1}, BucketCounts: []uint64{1, 0}})
+
+	t.Log("within ttl")
+	{
+		r.evictState(time.Now().Add(30 * time.Second))
+		if len(r.sums) != 1 || len(r.histograms) != 1 {
+			t.Fatalf("Expected state to be kept, got %d sums %d histograms", len(r.sums), len(r.histograms))
+		}
+	}
+
+	t.Log("not exported for ttl")
+	{
+		r.evictState(time.Now().Add(2 * time.Minute))
+		if len(r.sums) != 0 || len(r.histograms) != 0 {
+			t.Fatalf("Expected state to be evicted, got %d sums %d histograms", len(r.sums), len(r.histograms))
+		}
+
+		// the next export is a new baseline
+		if _, ok := sumDelta(r, "requests", metricdata.DataPoint[int64]{StartTime: start, Value: 5}); ok {
+			t.Fatal("Expected baseline")
+		}
+	}
+}
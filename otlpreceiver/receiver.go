@@ -0,0 +1,248 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package otlpreceiver provides an OTLP/HTTP metrics receiver which records
+// exported metrics (protobuf or json encoded) in a CirconusMetrics instance,
+// so sidecars and non-Go services can push OTLP metrics to a cgm based
+// aggregator.
+//
+// Usage:
+//
+//	metrics, err := cgm.New(cmc)
+//	...
+//	receiver, err := otlpreceiver.New(&otlpreceiver.Config{
+//	    Metrics:            metrics,
+//	    ResourceAttributes: []string{"service.name"},
+//	})
+//	...
+//	// standalone, serving /v1/metrics
+//	go receiver.ListenAndServe(":4318")
+//	// or with an existing mux
+//	mux.Handle("/v1/metrics", receiver)
+//
+// Metrics are recorded with the otelexporter conversions. Cumulative
+// monotonic sums and histograms are converted to delta, the first export
+// of a series started before the receiver is used as the baseline.
+// Summaries are rejected (reported as a partial success).
+package otlpreceiver
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics"
+	"github.com/circonus-labs/circonus-gometrics/otelexporter"
+	"github.com/pkg/errors"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MetricsPath is the OTLP/HTTP metrics path served by ListenAndServe
+	MetricsPath = "/v1/metrics"
+
+	defaultMaxRequestBytes = 4 * 1024 * 1024
+	defaultStateTTL        = time.Hour
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// Config options for the receiver
+type Config struct {
+	// Metrics is the CirconusMetrics instance metrics are recorded in
+	Metrics *cgm.CirconusMetrics
+
+	// ResourceAttributes are the resource attribute keys added to every
+	// data point as stream tags (e.g. service.name, host.name), data point
+	// attributes with the same key take precedence
+	ResourceAttributes []string
+
+	// MaxRequestBytes is the maximum (decompressed) request body size,
+	// default 4MiB
+	MaxRequestBytes int64
+
+	// StateTTL is how long the previous export of a cumulative series is
+	// kept, series not exported for StateTTL are forgotten (their next
+	// export is a new baseline), default 1h
+	StateTTL time.Duration
+}
+
+// Receiver is an OTLP/HTTP metrics receiver
+type Receiver struct {
+	exporter        *otelexporter.Exporter
+	resourceAttrs   map[string]bool
+	maxRequestBytes int64
+	started         time.Time
+	stateTTL        time.Duration
+	histograms      map[string]*histogramState
+	sums            map[string]*sumState
+	lastEvict       time.Time
+	statemu         sync.Mutex
+	server          *http.Server
+	servermu        sync.Mutex
+}
+
+// New returns a receiver recording metrics in cfg.Metrics
+func New(cfg *Config) (*Receiver, error) {
+	if cfg == nil {
+		return nil, errors.New("invalid configuration (nil)")
+	}
+
+	exporter, err := otelexporter.New(cfg.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		exporter:        exporter,
+		resourceAttrs:   make(map[string]bool, len(cfg.ResourceAttributes)),
+		maxRequestBytes: cfg.MaxRequestBytes,
+		started:         time.Now(),
+		stateTTL:        cfg.StateTTL,
+		histograms:      make(map[string]*histogramState),
+		sums:            make(map[string]*sumState),
+	}
+	r.lastEvict = r.started
+
+	for _, key := range cfg.ResourceAttributes {
+		r.resourceAttrs[key] = true
+	}
+
+	if r.maxRequestBytes <= 0 {
+		r.maxRequestBytes = defaultMaxRequestBytes
+	}
+
+	if r.stateTTL <= 0 {
+		r.stateTTL = defaultStateTTL
+	}
+
+	return r, nil
+}
+
+// ServeHTTP handles an OTLP/HTTP metrics export request
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := r.readBody(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request colmetricpb.ExportMetricsServiceRequest
+	if contentType == contentTypeJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, &request)
+	} else {
+		err = proto.Unmarshal(body, &request)
+	}
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "parsing request").Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := &colmetricpb.ExportMetricsServiceResponse{}
+	if rejected, err := r.export(req.Context(), &request); err != nil || rejected > 0 {
+		response.PartialSuccess = &colmetricpb.ExportMetricsPartialSuccess{RejectedDataPoints: rejected}
+		if err != nil {
+			response.PartialSuccess.ErrorMessage = err.Error()
+		}
+	}
+
+	var ret []byte
+	if contentType == contentTypeJSON {
+		ret, err = protojson.Marshal(response)
+	} else {
+		ret, err = proto.Marshal(response)
+	}
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "encoding response").Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(ret)
+}
+
+// readBody returns the (decompressed) request body
+func (r *Receiver) readBody(req *http.Request) ([]byte, error) {
+	var body io.Reader = req.Body
+
+	switch req.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading gzip body")
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, errors.Errorf("unsupported content encoding (%s)", req.Header.Get("Content-Encoding"))
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, r.maxRequestBytes+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading body")
+	}
+	if int64(len(data)) > r.maxRequestBytes {
+		return nil, errors.Errorf("request body exceeds %d bytes", r.maxRequestBytes)
+	}
+
+	return data, nil
+}
+
+// ListenAndServe serves the receiver on addr at MetricsPath, it blocks
+// until Shutdown is called (returning nil) or the server fails
+func (r *Receiver) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, r)
+
+	r.servermu.Lock()
+	if r.server != nil {
+		r.servermu.Unlock()
+		return errors.New("receiver already listening")
+	}
+	server := &http.Server{Addr: addr, Handler: mux}
+	r.server = server
+	r.servermu.Unlock()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops the standalone listener
+func (r *Receiver) Shutdown(ctx context.Context) error {
+	r.servermu.Lock()
+	server := r.server
+	r.server = nil
+	r.servermu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	return server.Shutdown(ctx)
+}
@@ -0,0 +1,290 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlpreceiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cgm "github.com/circonus-labs/circonus-gometrics"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// jsonPayload is an OTLP/HTTP json export request
+const jsonPayload = `{
+  "resourceMetrics": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "billing"}},
+      {"key": "process.pid", "value": {"intValue": "1234"}}
+    ]},
+    "scopeMetrics": [{
+      "scope": {"name": "billing"},
+      "metrics": [
+        {"name": "invoices", "sum": {
+          "aggregationTemporality": 1,
+          "isMonotonic": true,
+          "dataPoints": [{"asInt": "7", "timeUnixNano": "1700000000000000000", "attributes": [{"key": "currency", "value": {"stringValue": "usd"}}]}]
+        }},
+        {"name": "queue_depth", "gauge": {
+          "dataPoints": [{"asDouble": 2.5, "timeUnixNano": "1700000000000000000"}]
+        }},
+        {"name": "latency", "histogram": {
+          "aggregationTemporality": 1,
+          "dataPoints": [{"count": "3", "sum": 6, "explicitBounds": [1, 5], "bucketCounts": ["0", "3", "0"], "timeUnixNano": "1700000000000000000"}]
+        }},
+        {"name": "quantiles", "summary": {
+          "dataPoints": [{"count": "1", "sum": 1, "timeUnixNano": "1700000000000000000"}]
+        }}
+      ]
+    }]
+  }]
+}`
+
+func testMetrics(t *testing.T) *cgm.CirconusMetrics {
+	cfg := &cgm.Config{Interval: "0"}
+	cfg.CheckManager.Check.SubmissionURL = "http://127.0.0.1:2609/write/test"
+	m, err := cgm.New(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	return m
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func TestNew(t *testing.T) {
+	t.Log("Testing New")
+
+	t.Log("invalid config (nil)")
+	{
+		if _, err := New(nil); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("invalid metrics (nil)")
+	{
+		if _, err := New(&Config{}); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("valid")
+	{
+		r, err := New(&Config{Metrics: testMetrics(t)})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if r.maxRequestBytes != defaultMaxRequestBytes {
+			t.Fatalf("Expected %d max request bytes, got %d", defaultMaxRequestBytes, r.maxRequestBytes)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Log("Testing ServeHTTP")
+
+	m := testMetrics(t)
+	r, err := New(&Config{Metrics: m, ResourceAttributes: []string{"service.name"}, MaxRequestBytes: 4096})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	post := func(contentType, encoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", MetricsPath, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if encoding != "" {
+			req.Header.Set("Content-Encoding", encoding)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Log("invalid requests")
+	{
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", MetricsPath, nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("Expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+
+		tests := []struct {
+			contentType string
+			encoding    string
+			body        []byte
+			code        int
+		}{
+			{"text/plain", "", []byte("foo"), http.StatusUnsupportedMediaType},
+			{contentTypeJSON, "", []byte("{"), http.StatusBadRequest},
+			{contentTypeProtobuf, "", []byte("\xff\xff"), http.StatusBadRequest},
+			{contentTypeJSON, "br", []byte("{}"), http.StatusBadRequest},
+			{contentTypeJSON, "gzip", []byte("{}"), http.StatusBadRequest},
+			{contentTypeJSON, "", bytes.Repeat([]byte(" "), 4097), http.StatusBadRequest},
+		}
+		for _, test := range tests {
+			if w := post(test.contentType, test.encoding, test.body); w.Code != test.code {
+				t.Fatalf("Expected %d for %s %s, got %d", test.code, test.contentType, test.encoding, w.Code)
+			}
+		}
+	}
+
+	t.Log("json")
+	{
+		w := post(contentTypeJSON+"; charset=utf-8", "", []byte(jsonPayload))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != contentTypeJSON {
+			t.Fatalf("Expected %s, got %s", contentTypeJSON, ct)
+		}
+
+		var resp colmetricpb.ExportMetricsServiceResponse
+		if err := protojson.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if resp.GetPartialSuccess().GetRejectedDataPoints() != 1 {
+			t.Fatalf("Expected 1 rejected data point, got %v", resp.GetPartialSuccess())
+		}
+
+		hist, err := m.GetHistogramTest("latency|ST[service.name:billing]")
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(hist) != 1 || hist[0] != "H[3.0e+00]=3" {
+			t.Fatalf("Expected [H[3.0e+00]=3], got %v", hist)
+		}
+
+		metrics := *m.FlushMetrics()
+		expect := map[string]cgm.Metric{
+			"invoices|ST[currency:usd,service.name:billing]": {Type: "L", Value: uint64(7)},
+			"queue_depth|ST[service.name:billing]":           {Type: "n", Value: 2.5},
+		}
+		for name, want := range expect {
+			have, ok := metrics[name]
+			if !ok {
+				t.Fatalf("Expected metric %s, got %v", name, metrics)
+			}
+			if have.Type != want.Type || have.Value != want.Value {
+				t.Fatalf("Expected %s to be %+v, got %+v", name, want, have)
+			}
+		}
+	}
+
+	t.Log("protobuf (gzip)")
+	{
+		req := &colmetricpb.ExportMetricsServiceRequest{
+			ResourceMetrics: []*metricpb.ResourceMetrics{{
+				Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "service.name", Value: stringValue("web")}}},
+				ScopeMetrics: []*metricpb.ScopeMetrics{{
+					Metrics: []*metricpb.Metric{{
+						Name: "connections",
+						Data: &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+							AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+							DataPoints: []*metricpb.NumberDataPoint{{
+								Attributes: []*commonpb.KeyValue{{Key: "service.name", Value: stringValue("api")}},
+								Value:      &metricpb.NumberDataPoint_AsInt{AsInt: -2},
+							}},
+						}},
+					}},
+				}},
+			}},
+		}
+		data, err := proto.Marshal(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		var body bytes.Buffer
+		zw := gzip.NewWriter(&body)
+		zw.Write(data)
+		zw.Close()
+
+		w := post(contentTypeProtobuf, "gzip", body.Bytes())
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d (%s)", w.Code, w.Body.String())
+		}
+
+		var resp colmetricpb.ExportMetricsServiceResponse
+		if err := proto.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if resp.GetPartialSuccess() != nil {
+			t.Fatalf("Expected no partial success, got %v", resp.GetPartialSuccess())
+		}
+
+		metrics := *m.FlushMetrics()
+		if have := metrics["connections|ST[service.name:api]"]; have.Type != "l" || have.Value != int64(-2) {
+			t.Fatalf("Expected connections|ST[service.name:api] -2, got %v", metrics)
+		}
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	t.Log("Testing ListenAndServe")
+
+	m := testMetrics(t)
+	r, err := New(&Config{Metrics: m})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.ListenAndServe(addr)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		if resp, err = http.Post("http://"+addr+MetricsPath, contentTypeJSON, strings.NewReader(jsonPayload)); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	if err := r.ListenAndServe(addr); err == nil {
+		t.Fatal("Expected error, already listening")
+	}
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	if _, err := m.GetCounterTest("invoices|ST[currency:usd]"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Graphite and InfluxDB output. Metric names are sanitized (backticks
+// become dots, other unsupported characters underscores) and stream tags
+// become graphite 1.1 tags (name;tag=value) or influx tags. Histograms
+// and, for graphite, text metrics are skipped.
+
+// GraphiteOutput returns lines of metrics in graphite plaintext format
+func (m *CirconusMetrics) GraphiteOutput() (*bytes.Buffer, error) {
+	return m.lastOutput(writeGraphite)
+}
+
+// InfluxOutput returns lines of metrics in influxdb line protocol format
+// (millisecond precision)
+func (m *CirconusMetrics) InfluxOutput() (*bytes.Buffer, error) {
+	return m.lastOutput(writeInflux)
+}
+
+// lastOutput renders the last packaged metrics with write
+func (m *CirconusMetrics) lastOutput(write func(io.Writer, Metrics, time.Time) error) (*bytes.Buffer, error) {
+	m.lastMetrics.metricsmu.Lock()
+	defer m.lastMetrics.metricsmu.Unlock()
+
+	if m.lastMetrics.metrics == nil {
+		return nil, errors.New("no metrics available")
+	}
+
+	var b bytes.Buffer
+	if err := write(&b, *m.lastMetrics.metrics, m.lastMetrics.ts); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// writeGraphite writes metrics in graphite plaintext format, ts is used
+// for metrics without a timestamp
+func writeGraphite(w io.Writer, metrics Metrics, ts time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	for _, name := range sortedNames(metrics) {
+		metric := metrics[name]
+		if metric.Type == "s" {
+			continue // text metrics unsupported
+		}
+		val, ok := numericValue(metric.Value)
+		if !ok {
+			continue
+		}
+
+		base, tags := parseStreamTags(name)
+		bw.WriteString(sanitizeName(base))
+		for _, tag := range sortedTags(tags) {
+			value := graphiteTagValueReplacer.Replace(tag.Value)
+			value = strings.TrimLeft(value, "~")
+			if value == "" {
+				continue
+			}
+			bw.WriteString(";" + graphiteTagNameReplacer.Replace(tag.Category) + "=" + value)
+		}
+
+		bw.WriteString(" " + val + " " + strconv.FormatInt(metricTime(metric, ts).Unix(), 10) + "\n")
+	}
+
+	return errors.Wrap(bw.Flush(), "flushing metric buffer")
+}
+
+// writeInflux writes metrics in influxdb line protocol format, ts is used
+// for metrics without a timestamp
+func writeInflux(w io.Writer, metrics Metrics, ts time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	for _, name := range sortedNames(metrics) {
+		metric := metrics[name]
+
+		var field string
+		if s, ok := metric.Value.(string); ok && metric.Type == "s" {
+			field = `"` + influxStringReplacer.Replace(s) + `"`
+		} else {
+			val, ok := numericValue(metric.Value)
+			if !ok {
+				continue
+			}
+			field = val // always a float field, see numericValue
+		}
+
+		base, tags := parseStreamTags(name)
+		bw.WriteString(influxMeasurementReplacer.Replace(sanitizeName(base)))
+		for _, tag := range sortedTags(tags) {
+			if tag.Value == "" {
+				continue
+			}
+			bw.WriteString("," + influxTagReplacer.Replace(tag.Category) + "=" + influxTagReplacer.Replace(tag.Value))
+		}
+
+		ms := metricTime(metric, ts).UnixNano() / int64(time.Millisecond)
+		bw.WriteString(" value=" + field + " " + strconv.FormatInt(ms, 10) + "\n")
+	}
+
+	return errors.Wrap(bw.Flush(), "flushing metric buffer")
+}
+
+var (
+	graphiteTagNameReplacer   = strings.NewReplacer(";", "_", "!", "_", "^", "_", "=", "_", " ", "_")
+	graphiteTagValueReplacer  = strings.NewReplacer(";", "_", " ", "_")
+	influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagReplacer         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxStringReplacer      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// sanitizeName returns name with backticks replaced by dots and characters
+// other than letters, digits and _-.: replaced by underscores
+func sanitizeName(name string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c == '`':
+			return '.'
+		case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+			return c
+		case strings.ContainsRune("_-.:", c):
+			return c
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// numericValue returns the formatted value, false if the value is not a
+// (finite) number (e.g. a histogram). Integers are formatted without a
+// type suffix so influxdb stores every value as a float, the type of a
+// gauge (or expvar) value can change between flushes and influxdb rejects
+// writes changing the type of a field.
+func numericValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case int:
+		return strconv.FormatInt(int64(t), 10), true
+	case int8:
+		return strconv.FormatInt(int64(t), 10), true
+	case int16:
+		return strconv.FormatInt(int64(t), 10), true
+	case int32:
+		return strconv.FormatInt(int64(t), 10), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint64:
+		return strconv.FormatUint(t, 10), true
+	case float32:
+		return numericValue(float64(t))
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// metricTime returns the metric timestamp, ts if it has none
+func metricTime(metric Metric, ts time.Time) time.Time {
+	if metric.Timestamp > 0 {
+		return time.Unix(0, int64(metric.Timestamp)*int64(time.Millisecond))
+	}
+	return ts
+}
+
+// sortedNames returns the metric names in order
+func sortedNames(metrics Metrics) []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedTags returns the tags ordered by category
+func sortedTags(tags Tags) Tags {
+	sorted := append(Tags{}, tags...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Category < sorted[j].Category })
+	return sorted
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+// testOutputMetrics are metrics covering each supported type
+var testOutputMetrics = Metrics{
+	MetricNameWithStreamTags("http`requests", Tags{{"host", "web 1"}, {"code", "200"}}): {Type: "L", Value: uint64(30)},
+	MetricNameWithStreamTags("delta", Tags{{"dc", "us,east"}}):                          {Type: "l", Value: int64(-3)},
+	MetricNameWithStreamTags("version", Tags{{"tier", "~prod;a"}}):                      {Type: "s", Value: `1.2 "beta"`},
+	"load avg":    {Type: "n", Value: 0.25},
+	"latency":     {Type: "n", Value: []string{"H[1.0e+00]=1"}},
+	"latency_b64": {Type: "h", Value: "AAEKAAAB"},
+	"nan":         {Type: "n", Value: math.NaN()},
+	"old":         {Type: "i", Value: int32(7), Timestamp: 1500000000123},
+}
+
+func TestWriteGraphite(t *testing.T) {
+	t.Log("Testing writeGraphite")
+
+	var b bytes.Buffer
+	if err := writeGraphite(&b, testOutputMetrics, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expect := "delta;dc=us,east -3 1700000000\n" +
+		"http.requests;code=200;host=web_1 30 1700000000\n" +
+		"load_avg 0.25 1700000000\n" +
+		"old 7 1500000000\n"
+	if b.String() != expect {
+		t.Fatalf("Expected\n%s\ngot\n%s", expect, b.String())
+	}
+}
+
+func TestWriteInflux(t *testing.T) {
+	t.Log("Testing writeInflux")
+
+	var b bytes.Buffer
+	if err := writeInflux(&b, testOutputMetrics, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expect := `delta,dc=us\,east value=-3 1700000000000` + "\n" +
+		`http.requests,code=200,host=web\ 1 value=30 1700000000000` + "\n" +
+		`load_avg value=0.25 1700000000000` + "\n" +
+		`old value=7 1500000000123` + "\n" +
+		`version,tier=~prod;a value="1.2 \"beta\"" 1700000000000` + "\n"
+	if b.String() != expect {
+		t.Fatalf("Expected\n%s\ngot\n%s", expect, b.String())
+	}
+}
+
+func TestGraphiteInfluxOutput(t *testing.T) {
+	t.Log("Testing GraphiteOutput/InfluxOutput")
+
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	t.Log("No metrics")
+	{
+		if _, err := cm.GraphiteOutput(); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := cm.InfluxOutput(); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("counter")
+	{
+		cm.Set("foo`bar", 30)
+		cm.FlushMetrics()
+
+		b, err := cm.GraphiteOutput()
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if expect := "foo.bar 30 "; !bytes.HasPrefix(b.Bytes(), []byte(expect)) {
+			t.Fatalf("expected prefix (%s) got (%s)", expect, b.String())
+		}
+
+		b, err = cm.InfluxOutput()
+		if err != nil {
+			t.Fatalf("expected no error, got (%s)", err)
+		}
+		if expect := "foo.bar value=30 "; !bytes.HasPrefix(b.Bytes(), []byte(expect)) {
+			t.Fatalf("expected prefix (%s) got (%s)", expect, b.String())
+		}
+	}
+
+	t.Log("gauge changing type, influx field is always a float")
+	{
+		values := []struct {
+			value  interface{}
+			expect string
+		}{
+			{1, "baz value=1 "},
+			{1.5, "baz value=1.5 "},
+			{uint64(math.MaxUint64), "baz value=18446744073709551615 "},
+		}
+		for _, v := range values {
+			cm.Reset()
+			cm.Gauge("baz", v.value)
+			cm.FlushMetrics()
+
+			b, err := cm.InfluxOutput()
+			if err != nil {
+				t.Fatalf("expected no error, got (%s)", err)
+			}
+			if expect := v.expect; !bytes.HasPrefix(b.Bytes(), []byte(expect)) {
+				t.Fatalf("expected prefix (%s) got (%s)", expect, b.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/logging"
+	"github.com/pkg/errors"
+)
+
+// Reconfigure applies the runtime settings in cfg to a running instance
+// (e.g. on SIGHUP or from a feature flag system). cfg is validated first,
+// nothing is changed if any setting is invalid (see Config.Validate).
+//
+// Settings applied, blank settings revert to their defaults as in New:
+//
+//   - Interval, the flush loop is restarted with the new interval
+//   - ResetCounters, ResetUpDownCounters, ResetGauges, ResetHistograms, ResetText
+//   - Debug, including the check managers and their api clients, the logger
+//     must support it (see logging.DebugSetter) for Debug to be changed
+//   - SubmitPolicy
+//   - CheckManager.Broker.TLSConfig and Destinations[].CheckManager.Broker.TLSConfig
+//   - Destinations[].MetricFilter
+//
+// Other settings require a new instance and are ignored. Destinations are
+// matched by position, they cannot be added or removed.
+//
+// Metrics collected before the call are kept and sent with the next flush.
+// Submission clients are rebuilt for the next submission, submissions in
+// progress complete with the previous clients.
+func (m *CirconusMetrics) Reconfigure(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return errors.Wrap(err, "reconfigure")
+	}
+
+	if len(cfg.Destinations) != len(m.destinations) {
+		return errors.Errorf("reconfigure: destinations cannot be added or removed (have %d, got %d)", len(m.destinations), len(cfg.Destinations))
+	}
+
+	// parse everything before changing anything
+
+	interval := defaultFlushInterval
+	if cfg.Interval != "" {
+		interval = cfg.Interval
+	}
+	flushInterval, _ := time.ParseDuration(interval) // validated
+
+	policy, err := newSubmitPolicy(cfg.SubmitPolicy, flushInterval)
+	if err != nil {
+		return errors.Wrap(err, "reconfigure: parsing submit policy")
+	}
+
+	m.settingsmu.RLock()
+	debugChanged := cfg.Debug != m.Debug
+	m.settingsmu.RUnlock()
+	if _, ok := m.getLogger().(logging.DebugSetter); debugChanged && !ok {
+		return errors.New("reconfigure: Debug cannot be changed, the logger does not implement logging.DebugSetter")
+	}
+
+	filters := make([]*regexp.Regexp, len(cfg.Destinations))
+	for i, dcfg := range cfg.Destinations {
+		d := m.destinations[i]
+		if dcfg.Name != "" && dcfg.Name != d.name {
+			return errors.Errorf("reconfigure: destination %d is %s, got %s", i, d.name, dcfg.Name)
+		}
+		if dcfg.MetricFilter != "" {
+			filters[i] = regexp.MustCompile(dcfg.MetricFilter) // validated
+		}
+	}
+
+	// apply
+
+	m.packagingmu.Lock()
+	m.resetCounters = boolSetting(cfg.ResetCounters, true)
+	m.resetUpDownCounters = boolSetting(cfg.ResetUpDownCounters, false)
+	m.resetGauges = boolSetting(cfg.ResetGauges, true)
+	m.resetHistograms = boolSetting(cfg.ResetHistograms, true)
+	m.resetText = boolSetting(cfg.ResetText, true)
+	m.packagingmu.Unlock()
+
+	m.settingsmu.Lock()
+	m.flushInterval = flushInterval
+	m.submitPolicy = policy
+	m.Debug = cfg.Debug
+	m.settingsmu.Unlock()
+
+	if ds, ok := m.getLogger().(logging.DebugSetter); ok {
+		ds.SetDebug(cfg.Debug)
+	}
+	if !m.check.SetDebug(cfg.Debug) {
+		m.getLogger().Warn("check manager logger does not support changing Debug")
+	}
+
+	m.check.SetBrokerTLSConfig(cfg.CheckManager.Broker.TLSConfig)
+	m.primary.resetTrapClient()
+
+	for i, d := range m.destinations {
+		d.check.SetBrokerTLSConfig(cfg.Destinations[i].CheckManager.Broker.TLSConfig)
+		if !d.check.SetDebug(cfg.Debug) {
+			m.getLogger().Warn("check manager logger does not support changing Debug", "destination", d.name)
+		}
+		d.setFilter(filters[i])
+		d.resetTrapClient()
+	}
+
+	m.startFlushLoop(flushInterval)
+
+	m.getLogger().Info("reconfigured", "interval", flushInterval)
+
+	return nil
+}
+
+// boolSetting returns the parsed (validated) setting, def when blank
+func boolSetting(setting string, def bool) bool {
+	if setting == "" {
+		return def
+	}
+	v, _ := strconv.ParseBool(setting)
+	return v
+}
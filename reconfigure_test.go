@@ -0,0 +1,154 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/logging"
+)
+
+func TestReconfigure(t *testing.T) {
+	t.Log("Testing Reconfigure")
+
+	primary, primaryReceived := recordingBroker()
+	defer primary.Close()
+	agent, agentReceived := recordingBroker()
+	defer agent.Close()
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = primary.URL
+	cfg.Destinations = []Destination{{Name: "agent", SubmissionURL: agent.URL, MetricFilter: "^foo"}}
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	for !cm.Ready() || !cm.destinations[0].check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cm.Increment("foo")
+	cm.Flush()
+
+	t.Log("invalid settings")
+	{
+		bad := &Config{Interval: "soon", ResetGauges: "maybe", Destinations: cfg.Destinations}
+		if err := cm.Reconfigure(bad); err == nil {
+			t.Fatal("Expected error")
+		}
+		if cm.flushInterval != 0 || !cm.resetGauges {
+			t.Fatal("Expected settings to be unchanged")
+		}
+	}
+
+	t.Log("destinations changed")
+	{
+		if err := cm.Reconfigure(&Config{}); err == nil {
+			t.Fatal("Expected error")
+		}
+		renamed := &Config{Destinations: []Destination{{Name: "other"}}}
+		if err := cm.Reconfigure(renamed); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	t.Log("runtime settings")
+	{
+		// collected before reconfiguring, sent with the next automatic flush
+		cm.Increment("bar")
+		cm.Gauge("baz", 1)
+
+		newCfg := &Config{
+			Interval:     "200ms",
+			ResetGauges:  "false",
+			Debug:        true,
+			CheckManager: cfg.CheckManager,
+			Destinations: []Destination{{Name: "agent", SubmissionURL: agent.URL, MetricFilter: "^ba"}},
+			SubmitPolicy: SubmitPolicy{MaxAttempts: "2"},
+		}
+		if err := cm.Reconfigure(newCfg); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.primary.trapClientmu.Lock()
+		rebuilt := cm.primary.trapClient == nil
+		cm.primary.trapClientmu.Unlock()
+		if !rebuilt {
+			t.Fatal("Expected submission client to be discarded")
+		}
+
+		cm.packagingmu.Lock()
+		if cm.resetGauges || !cm.resetCounters {
+			t.Fatal("Expected reset settings to be updated")
+		}
+		cm.packagingmu.Unlock()
+
+		if p := cm.getSubmitPolicy(); p.maxAttempts != 2 || p.timeout != 200*time.Millisecond {
+			t.Fatalf("Expected updated submit policy, got %+v", p)
+		}
+		if !cm.Debug {
+			t.Fatal("Expected debug")
+		}
+		if !cm.check.Debug || !cm.destinations[0].check.Debug {
+			t.Fatal("Expected debug in check managers")
+		}
+
+		for i := 0; i < 100 && (!agentReceived()["bar"] || !primaryReceived()["bar"]); i++ {
+			time.Sleep(20 * time.Millisecond)
+		}
+		received := agentReceived()
+		if !received["bar"] || !received["baz"] {
+			t.Fatalf("Expected bar and baz at agent, got %v", received)
+		}
+		received = primaryReceived()
+		if !received["foo"] || !received["bar"] || !received["baz"] {
+			t.Fatalf("Expected foo, bar and baz at primary, got %v", received)
+		}
+	}
+
+	t.Log("disable automatic flush")
+	{
+		off := &Config{Interval: "0", CheckManager: cfg.CheckManager, Destinations: []Destination{{SubmissionURL: agent.URL}}}
+		if err := cm.Reconfigure(off); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		cm.flushLoopmu.Lock()
+		stopped := cm.flushStop == nil
+		cm.flushLoopmu.Unlock()
+		if !stopped {
+			t.Fatal("Expected flush loop to be stopped")
+		}
+	}
+
+	t.Log("debug, logger without DebugSetter")
+	{
+		ncfg := &Config{}
+		ncfg.Interval = "0"
+		ncfg.CheckManager.Check.SubmissionURL = primary.URL
+		ncfg.Logger = struct{ logging.Logger }{logging.NewStdLogger(log.New(ioutil.Discard, "", 0), false)}
+
+		ncm, err := NewCirconusMetrics(ncfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		dcfg := *ncfg
+		dcfg.Debug = true
+		if err := ncm.Reconfigure(&dcfg); err == nil {
+			t.Fatal("Expected error")
+		}
+		if ncm.Debug {
+			t.Fatal("Expected debug to be unchanged")
+		}
+		if err := ncm.Reconfigure(ncfg); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+}
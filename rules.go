@@ -0,0 +1,325 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonusllhist"
+	"github.com/pkg/errors"
+)
+
+// A Rule is a lightweight, local threshold evaluated against the metrics
+// packaged at each flush. Rules allow an application to react to its own
+// metrics (shed load, flip feature flags, log loudly) even when a broker
+// is unreachable. The criteria and severity model mirror api.RuleSetRule
+// so the same thresholds can later be pushed to Circonus as rule sets.
+
+// Rule criteria, these use the same strings as api.RuleSetRule.Criteria
+const (
+	RuleCriteriaMaxValue    = "max value"        // trigger if value > rule value
+	RuleCriteriaMinValue    = "min value"        // trigger if value < rule value
+	RuleCriteriaMatch       = "match"            // trigger if text value == rule value
+	RuleCriteriaNotMatch    = "does not match"   // trigger if text value != rule value
+	RuleCriteriaContains    = "contains"         // trigger if text value contains rule value
+	RuleCriteriaNotContains = "does not contain" // trigger if text value does not contain rule value
+	RuleCriteriaAbsence     = "on absence"       // trigger if metric not present in flush
+)
+
+const (
+	defaultRuleSeverity       = 1
+	defaultRuleIntervals      = 1
+	defaultHistogramStatistic = "mean"
+	histogramQuantilePrefix   = "p"
+	maxRuleSeverity           = 5
+)
+
+// Rule defines a local threshold rule for a single metric
+type Rule struct {
+	// unique name of the rule, used to remove the rule
+	Name string
+	// name of the metric the rule applies to
+	Metric string
+	// one of the RuleCriteria* constants (api.RuleSetRule.Criteria)
+	Criteria string
+	// 1-5, 1 being most severe (api.RuleSetRule.Severity), default 1
+	Severity uint
+	// threshold, numeric for value criteria, string for text criteria
+	Value interface{}
+	// number of consecutive flush intervals the criteria must be met
+	// before OnTrigger is called, default 1
+	Intervals uint
+	// for histograms, the statistic evaluated: mean, sum, min, max
+	// or a quantile expressed as pNN (e.g. p99, p99.9), default mean
+	Statistic string
+	// called once when the rule transitions to triggered
+	OnTrigger func(RuleEvent)
+	// called once when a triggered rule no longer meets the criteria
+	OnClear func(RuleEvent)
+}
+
+// RuleEvent is passed to rule callbacks
+type RuleEvent struct {
+	Rule      *Rule
+	Metric    string
+	Value     interface{} // value evaluated (nil if metric was absent)
+	Intervals uint        // consecutive intervals the criteria was met
+	Triggered bool
+	Timestamp time.Time
+}
+
+type ruleState struct {
+	rule      *Rule
+	matches   uint
+	triggered bool
+	quantile  float64
+}
+
+// AddRule registers a local threshold rule, replacing any rule with the same name
+func (m *CirconusMetrics) AddRule(r *Rule) error {
+	if r == nil {
+		return errors.New("invalid rule (nil)")
+	}
+	if r.Name == "" {
+		return errors.New("invalid rule, name required")
+	}
+	if r.Metric == "" {
+		return errors.New("invalid rule, metric required")
+	}
+
+	state := &ruleState{rule: r}
+
+	if r.Severity == 0 {
+		r.Severity = defaultRuleSeverity
+	}
+	if r.Severity > maxRuleSeverity {
+		return errors.Errorf("invalid rule severity (%d), must be 1-%d", r.Severity, maxRuleSeverity)
+	}
+	if r.Intervals == 0 {
+		r.Intervals = defaultRuleIntervals
+	}
+
+	switch r.Criteria {
+	case RuleCriteriaMaxValue, RuleCriteriaMinValue:
+		if _, ok := toFloat64(r.Value); !ok {
+			return errors.Errorf("invalid rule value (%v), numeric required for '%s'", r.Value, r.Criteria)
+		}
+	case RuleCriteriaMatch, RuleCriteriaNotMatch, RuleCriteriaContains, RuleCriteriaNotContains:
+		if _, ok := r.Value.(string); !ok {
+			return errors.Errorf("invalid rule value (%v), string required for '%s'", r.Value, r.Criteria)
+		}
+	case RuleCriteriaAbsence:
+	default:
+		return errors.Errorf("invalid rule criteria (%s)", r.Criteria)
+	}
+
+	if r.Statistic == "" {
+		r.Statistic = defaultHistogramStatistic
+	}
+	switch r.Statistic {
+	case "mean", "sum", "min", "max":
+	default:
+		if !strings.HasPrefix(r.Statistic, histogramQuantilePrefix) {
+			return errors.Errorf("invalid rule statistic (%s)", r.Statistic)
+		}
+		q, err := strconv.ParseFloat(strings.TrimPrefix(r.Statistic, histogramQuantilePrefix), 64)
+		if err != nil || q < 0 || q > 100 {
+			return errors.Errorf("invalid rule statistic quantile (%s)", r.Statistic)
+		}
+		state.quantile = q / 100
+	}
+
+	m.rlm.Lock()
+	defer m.rlm.Unlock()
+
+	if m.rules == nil {
+		m.rules = make(map[string]*ruleState)
+	}
+	m.rules[r.Name] = state
+
+	return nil
+}
+
+// RemoveRule removes the named rule
+func (m *CirconusMetrics) RemoveRule(name string) {
+	m.rlm.Lock()
+	defer m.rlm.Unlock()
+	delete(m.rules, name)
+}
+
+// RuleSetRule returns the api representation of the rule. The additional
+// intervals are converted to a wait time (in minutes) using the flush interval.
+func (r *Rule) RuleSetRule(interval time.Duration) api.RuleSetRule {
+	wait := uint(0)
+	if r.Intervals > 1 {
+		wait = uint((time.Duration(r.Intervals-1) * interval).Minutes())
+	}
+	return api.RuleSetRule{
+		Criteria: r.Criteria,
+		Severity: r.Severity,
+		Value:    fmt.Sprintf("%v", r.Value),
+		Wait:     wait,
+	}
+}
+
+// evaluateRules runs all registered rules against packaged metrics,
+// callbacks are invoked after all rules have been evaluated
+func (m *CirconusMetrics) evaluateRules(output Metrics, histograms map[string]*circonusllhist.Histogram) {
+	m.rlm.Lock()
+	if len(m.rules) == 0 {
+		m.rlm.Unlock()
+		return
+	}
+
+	ts := time.Now()
+	events := []RuleEvent{}
+
+	for _, state := range m.rules {
+		val, present := ruleValue(state, output, histograms)
+		met := state.met(val, present)
+		if met {
+			state.matches++
+		} else {
+			state.matches = 0
+		}
+
+		event := RuleEvent{
+			Rule:      state.rule,
+			Metric:    state.rule.Metric,
+			Value:     val,
+			Intervals: state.matches,
+			Timestamp: ts,
+		}
+
+		if met && !state.triggered && state.matches >= state.rule.Intervals {
+			state.triggered = true
+			event.Triggered = true
+			if state.rule.OnTrigger != nil {
+				events = append(events, event)
+			}
+		} else if !met && state.triggered {
+			state.triggered = false
+			if state.rule.OnClear != nil {
+				events = append(events, event)
+			}
+		}
+	}
+	m.rlm.Unlock()
+
+	for _, event := range events {
+		m.getLogger().Debug("rule evaluated", "rule", event.Rule.Name, "triggered", event.Triggered, "value", event.Value)
+		if event.Triggered {
+			event.Rule.OnTrigger(event)
+		} else {
+			event.Rule.OnClear(event)
+		}
+	}
+}
+
+// ruleValue extracts the value a rule is evaluated against
+func ruleValue(state *ruleState, output Metrics, histograms map[string]*circonusllhist.Histogram) (interface{}, bool) {
+	if hist, ok := histograms[state.rule.Metric]; ok {
+		if _, sent := output[state.rule.Metric]; !sent {
+			return nil, false
+		}
+		switch state.rule.Statistic {
+		case "mean":
+			return hist.ApproxMean(), true
+		case "sum":
+			return hist.ApproxSum(), true
+		case "min":
+			return hist.Min(), true
+		case "max":
+			return hist.Max(), true
+		default:
+			return hist.ValueAtQuantile(state.quantile), true
+		}
+	}
+
+	metric, ok := output[state.rule.Metric]
+	if !ok {
+		return nil, false
+	}
+	return metric.Value, true
+}
+
+// met determines if the rule criteria is met by the value
+func (s *ruleState) met(val interface{}, present bool) bool {
+	if s.rule.Criteria == RuleCriteriaAbsence {
+		return !present
+	}
+	if !present {
+		return false
+	}
+
+	switch s.rule.Criteria {
+	case RuleCriteriaMaxValue, RuleCriteriaMinValue:
+		v, ok := toFloat64(val)
+		if !ok {
+			return false
+		}
+		threshold, _ := toFloat64(s.rule.Value)
+		if s.rule.Criteria == RuleCriteriaMaxValue {
+			return v > threshold
+		}
+		return v < threshold
+	}
+
+	text := fmt.Sprintf("%v", val)
+	threshold := s.rule.Value.(string)
+	switch s.rule.Criteria {
+	case RuleCriteriaMatch:
+		return text == threshold
+	case RuleCriteriaNotMatch:
+		return text != threshold
+	case RuleCriteriaContains:
+		return strings.Contains(text, threshold)
+	case RuleCriteriaNotContains:
+		return !strings.Contains(text, threshold)
+	}
+
+	return false
+}
+
+// toFloat64 converts a numeric metric value to a float64
+func toFloat64(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), true
+	case int8:
+		return float64(t), true
+	case int16:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint8:
+		return float64(t), true
+	case uint16:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
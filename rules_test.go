@@ -0,0 +1,231 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddRule(t *testing.T) {
+	t.Log("Testing rules.AddRule")
+
+	cm := &CirconusMetrics{}
+
+	t.Log("invalid (nil)")
+	{
+		if err := cm.AddRule(nil); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid (no name)")
+	{
+		if err := cm.AddRule(&Rule{Metric: "foo", Criteria: RuleCriteriaMaxValue, Value: 1}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid (criteria)")
+	{
+		if err := cm.AddRule(&Rule{Name: "foo", Metric: "foo", Criteria: "bar", Value: 1}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid (non-numeric value)")
+	{
+		if err := cm.AddRule(&Rule{Name: "foo", Metric: "foo", Criteria: RuleCriteriaMaxValue, Value: "bar"}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("invalid (statistic)")
+	{
+		if err := cm.AddRule(&Rule{Name: "foo", Metric: "foo", Criteria: RuleCriteriaMaxValue, Value: 1, Statistic: "pfoo"}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	t.Log("valid")
+	{
+		r := &Rule{Name: "foo", Metric: "foo", Criteria: RuleCriteriaMaxValue, Value: 1, Statistic: "p99.9"}
+		if err := cm.AddRule(r); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if r.Severity != 1 {
+			t.Fatalf("expected default severity 1, got %d", r.Severity)
+		}
+		if r.Intervals != 1 {
+			t.Fatalf("expected default intervals 1, got %d", r.Intervals)
+		}
+		if len(cm.rules) != 1 {
+			t.Fatalf("expected 1 rule, got %d", len(cm.rules))
+		}
+	}
+
+	t.Log("remove")
+	{
+		cm.RemoveRule("foo")
+		if len(cm.rules) != 0 {
+			t.Fatalf("expected 0 rules, got %d", len(cm.rules))
+		}
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	t.Log("counter, max value for 2 intervals")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		triggered := 0
+		cleared := 0
+		err = cm.AddRule(&Rule{
+			Name:      "errors",
+			Metric:    "errors",
+			Criteria:  RuleCriteriaMaxValue,
+			Value:     100,
+			Intervals: 2,
+			OnTrigger: func(e RuleEvent) { triggered++ },
+			OnClear:   func(e RuleEvent) { cleared++ },
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.IncrementByValue("errors", 101)
+		cm.FlushMetrics()
+		if triggered != 0 {
+			t.Fatalf("expected not triggered after 1 interval")
+		}
+
+		cm.IncrementByValue("errors", 101)
+		cm.FlushMetrics()
+		if triggered != 1 {
+			t.Fatalf("expected triggered after 2 intervals, got %d", triggered)
+		}
+
+		cm.IncrementByValue("errors", 500)
+		cm.FlushMetrics()
+		if triggered != 1 {
+			t.Fatalf("expected trigger to be called once, got %d", triggered)
+		}
+
+		cm.IncrementByValue("errors", 5)
+		cm.FlushMetrics()
+		if cleared != 1 {
+			t.Fatalf("expected cleared, got %d", cleared)
+		}
+	}
+
+	t.Log("histogram, p99")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		var event RuleEvent
+		err = cm.AddRule(&Rule{
+			Name:      "latency",
+			Metric:    "latency",
+			Criteria:  RuleCriteriaMaxValue,
+			Value:     0.25,
+			Statistic: "p99",
+			OnTrigger: func(e RuleEvent) { event = e },
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		for i := 0; i < 100; i++ {
+			cm.RecordValue("latency", 0.5)
+		}
+		cm.FlushMetrics()
+		if !event.Triggered {
+			t.Fatal("expected triggered")
+		}
+		if v, ok := event.Value.(float64); !ok || v < 0.25 {
+			t.Fatalf("expected value > 0.25, got %v", event.Value)
+		}
+	}
+
+	t.Log("text, match")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		triggered := false
+		err = cm.AddRule(&Rule{
+			Name:      "state",
+			Metric:    "state",
+			Criteria:  RuleCriteriaMatch,
+			Value:     "degraded",
+			OnTrigger: func(e RuleEvent) { triggered = true },
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.SetText("state", "degraded")
+		cm.FlushMetrics()
+		if !triggered {
+			t.Fatal("expected triggered")
+		}
+	}
+
+	t.Log("absence")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		triggered := false
+		err = cm.AddRule(&Rule{
+			Name:      "heartbeat",
+			Metric:    "heartbeat",
+			Criteria:  RuleCriteriaAbsence,
+			OnTrigger: func(e RuleEvent) { triggered = true },
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.FlushMetrics()
+		if !triggered {
+			t.Fatal("expected triggered")
+		}
+	}
+}
+
+func TestRuleSetRule(t *testing.T) {
+	t.Log("Testing rules.RuleSetRule")
+
+	r := &Rule{Criteria: RuleCriteriaMaxValue, Severity: 2, Value: 250, Intervals: 7}
+	rsr := r.RuleSetRule(10 * time.Second)
+
+	if rsr.Criteria != RuleCriteriaMaxValue {
+		t.Fatalf("expected criteria '%s', got '%s'", RuleCriteriaMaxValue, rsr.Criteria)
+	}
+	if rsr.Severity != 2 {
+		t.Fatalf("expected severity 2, got %d", rsr.Severity)
+	}
+	if rsr.Value != "250" {
+		t.Fatalf("expected value '250', got '%v'", rsr.Value)
+	}
+	if rsr.Wait != 1 {
+		t.Fatalf("expected wait 1, got %d", rsr.Wait)
+	}
+}
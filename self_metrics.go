@@ -0,0 +1,157 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/circonus-labs/circonusllhist"
+)
+
+// Self metrics instrument the metrics pipeline itself (flush and packaging
+// durations, payload sizes, submission latency, retries and failures, etc.).
+// They are named <prefix>`<name> and kept apart from the application's
+// metrics (resets, gauge aggregation and packaged counts do not apply).
+// At each flush they are added to the metrics submitted to each
+// destination: packaging stats describe the metrics being submitted,
+// submission stats (and flush_duration) are those of the destination's
+// previous submissions, as they are only known once metrics have been sent.
+
+const (
+	defaultSelfMetrics       = "false"
+	defaultSelfMetricsPrefix = "cgm"
+
+	selfFlushDuration       = "flush_duration"        // histogram, duration of Flush
+	selfFlushesSkipped      = "flushes_skipped"       // counter, flushes skipped as one was already in progress
+	selfPackageDuration     = "package_duration"      // histogram, duration of packaging metrics
+	selfPackagedMetrics     = "metrics`"              // gauges, number of metrics packaged by type
+	selfPayloadBytes        = "payload_bytes"         // histogram, size of each payload (before compression)
+	selfSubmitLatency       = "submit_latency"        // histogram, duration of each submission, including retries
+	selfSubmitAttempts      = "submit_attempts"       // histogram, number of attempts for each submission
+	selfSubmitRetries       = "submit_retries"        // counter, submission retries
+	selfSubmitFailures      = "submit_failures"       // counter, submissions which failed after all attempts
+	selfMetricsActivated    = "metrics_activated"     // counter, new metrics passed to the check manager
+	selfUpdateCheckDuration = "update_check_duration" // histogram, duration of check manager UpdateCheck
+)
+
+// selfStats holds self metric values until they are submitted, one for
+// the flush and one per destination (shared by the shards of a check)
+type selfStats struct {
+	counters   map[string]uint64
+	gauges     map[string]int
+	histograms map[string]*circonusllhist.Histogram
+	mu         sync.Mutex
+}
+
+func newSelfStats() *selfStats {
+	return &selfStats{
+		counters:   make(map[string]uint64),
+		gauges:     make(map[string]int),
+		histograms: make(map[string]*circonusllhist.Histogram),
+	}
+}
+
+// selfMetricName returns the full name of a self metric
+func (m *CirconusMetrics) selfMetricName(name string) string {
+	return m.selfMetricsPrefix + "`" + name
+}
+
+// selfDuration records a duration, in timer units, in a self metric histogram
+func (m *CirconusMetrics) selfDuration(s *selfStats, name string, d time.Duration) {
+	m.selfValue(s, name, m.durationValue(d))
+}
+
+// selfValue records a value in a self metric histogram
+func (m *CirconusMetrics) selfValue(s *selfStats, name string, val float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hist, ok := s.histograms[name]
+	if !ok {
+		hist = circonusllhist.New()
+		s.histograms[name] = hist
+	}
+	hist.RecordValue(val)
+}
+
+// selfCount adds to a self metric counter
+func (m *CirconusMetrics) selfCount(s *selfStats, name string, n uint64) {
+	if s == nil || n == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += n
+}
+
+// selfGauge sets a self metric gauge
+func (m *CirconusMetrics) selfGauge(s *selfStats, name string, val int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gauges[name] = val
+}
+
+// selfOutput returns the recorded self metrics, ready to submit, and
+// resets them
+func (m *CirconusMetrics) selfOutput(s *selfStats) Metrics {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	counters, gauges, histograms := s.counters, s.gauges, s.histograms
+	s.counters = make(map[string]uint64)
+	s.gauges = make(map[string]int)
+	s.histograms = make(map[string]*circonusllhist.Histogram)
+	s.mu.Unlock()
+
+	output := make(Metrics, len(counters)+len(gauges)+len(histograms))
+	for name, value := range counters {
+		output[m.selfMetricName(name)] = Metric{Type: "L", Value: value}
+	}
+	for name, value := range gauges {
+		output[m.selfMetricName(name)] = Metric{Type: "i", Value: value}
+	}
+	for name, hist := range histograms {
+		metric, err := m.histogramMetric(hist)
+		if err != nil {
+			m.getLogger().Warn("encoding histogram", "metric", m.selfMetricName(name), "err", err)
+			continue
+		}
+		output[m.selfMetricName(name)] = metric
+	}
+
+	return output
+}
+
+// withSelfMetrics returns output with the flush self metrics (self) and
+// those of destination d added, output is not modified
+func (m *CirconusMetrics) withSelfMetrics(d *destination, output Metrics, self Metrics) Metrics {
+	if m.self == nil {
+		return output
+	}
+
+	dself := m.selfOutput(d.self)
+
+	combined := make(Metrics, len(output)+len(self)+len(dself))
+	for name, metric := range output {
+		combined[name] = metric
+	}
+	for name, metric := range self {
+		combined[name] = metric
+	}
+	for name, metric := range dself {
+		combined[name] = metric
+	}
+
+	return combined
+}
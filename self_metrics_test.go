@@ -0,0 +1,188 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelfMetrics(t *testing.T) {
+	t.Log("Testing self metrics")
+
+	t.Log("invalid setting")
+	{
+		cfg := &Config{}
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = "none"
+		cfg.SelfMetrics = "foo"
+		if _, err := NewCirconusMetrics(cfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	server, received := recordingBroker()
+	defer server.Close()
+
+	t.Log("disabled")
+	{
+		cfg := &Config{}
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = server.URL
+
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for !cm.Ready() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cm.Increment("foo")
+		cm.Flush()
+
+		for name := range *cm.FlushMetrics() {
+			if strings.HasPrefix(name, "cgm`") {
+				t.Fatalf("Expected no self metrics, found %s", name)
+			}
+		}
+	}
+
+	t.Log("enabled")
+	{
+		cfg := &Config{}
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = server.URL
+		cfg.SelfMetrics = "true"
+		cfg.SelfMetricsPrefix = "self"
+
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for !cm.Ready() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cm.Increment("foo")
+		cm.Gauge("bar", 1)
+		cm.Flush()
+
+		// packaging stats are sent with the flush they describe
+		r := received()
+		for _, name := range []string{
+			"self`package_duration",
+			"self`metrics`counter",
+			"self`metrics`gauge",
+		} {
+			if !r[name] {
+				t.Fatalf("Expected %s to be submitted, got %v", name, r)
+			}
+		}
+
+		// skipped flush
+		cm.flushing = true
+		cm.Flush()
+		cm.flushing = false
+
+		// flush and submission stats are sent with the next
+		cm.Flush()
+
+		r = received()
+		for _, name := range []string{
+			"self`flush_duration",
+			"self`flushes_skipped",
+			"self`payload_bytes",
+			"self`submit_latency",
+			"self`submit_attempts",
+			"self`metrics_activated",
+			"self`update_check_duration",
+		} {
+			if !r[name] {
+				t.Fatalf("Expected %s to be submitted, got %v", name, r)
+			}
+		}
+
+		t.Log("\tnot recorded as application metrics")
+		cm.Increment("foo")
+		for name := range *cm.FlushMetrics() {
+			if strings.HasPrefix(name, "self`") {
+				t.Fatalf("Expected no self metrics, found %s", name)
+			}
+		}
+		cm.Reset()
+		if len(cm.self.gauges) == 0 {
+			t.Fatal("Expected self metrics to be unaffected by Reset")
+		}
+	}
+
+	t.Log("per destination")
+	{
+		var mu sync.Mutex
+		submissions := make(map[string][]Metrics)
+		broker := func(name string) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				var metrics Metrics
+				if err := json.Unmarshal(body, &metrics); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				mu.Lock()
+				submissions[name] = append(submissions[name], metrics)
+				mu.Unlock()
+				w.WriteHeader(200)
+				fmt.Fprintf(w, `{"stats":%d}`, len(metrics))
+			}))
+		}
+		primary := broker("primary")
+		defer primary.Close()
+		agent := broker("agent")
+		defer agent.Close()
+
+		cfg := &Config{}
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = primary.URL
+		cfg.SelfMetrics = "true"
+		cfg.Destinations = []Destination{{Name: "agent", SubmissionURL: agent.URL}}
+
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for !cm.Ready() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cm.Increment("foo")
+		cm.Flush()
+		cm.Increment("foo")
+		cm.Flush()
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, name := range []string{"primary", "agent"} {
+			if len(submissions[name]) != 2 {
+				t.Fatalf("%s: expected 2 submissions, got %d", name, len(submissions[name]))
+			}
+			first, second := submissions[name][0], submissions[name][1]
+			if m := second["cgm`metrics`counter"]; m.Value != float64(1) {
+				t.Fatalf("%s: expected 1 packaged counter, got %v", name, m)
+			}
+			// a check manager without an api token activates every metric
+			// it is sent, counted for this destination only
+			if m := second["cgm`metrics_activated"]; m.Value != float64(len(first)) {
+				t.Fatalf("%s: expected %d activations, got %v", name, len(first), m)
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Push sinks send the packaged metrics to graphite (plaintext over tcp or
+// udp) and influxdb (line protocol over http) at each flush, in addition
+// to the check, see Config.GraphiteURL and Config.InfluxURL. Each sink has
+// a queue and a worker so a slow or dead sink does not delay the flush (or
+// the other sinks), each push is limited to the flush interval.
+
+const (
+	defaultSinkTimeout = 10 * time.Second
+	sinkQueueSize      = 10   // pending pushes per sink, further pushes are dropped
+	maxUDPPayload      = 1400 // graphite lines are batched into datagrams up to this size (fits an ethernet mtu)
+)
+
+// pushSink sends metrics to an external system, until ctx is done
+type pushSink interface {
+	push(ctx context.Context, metrics Metrics, ts time.Time) error
+	String() string
+}
+
+// sinkBatch is a queued push
+type sinkBatch struct {
+	metrics Metrics
+	ts      time.Time
+}
+
+// graphiteSink sends metrics in graphite plaintext format
+type graphiteSink struct {
+	network string
+	addr    string
+}
+
+// influxSink sends metrics in influxdb line protocol format
+type influxSink struct {
+	url    string
+	name   string // url without credentials or query, for logging
+	client *http.Client
+}
+
+// parseGraphiteURL returns a graphite sink for tcp://host:port or udp://host:port
+func parseGraphiteURL(s string) (*graphiteSink, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "tcp" && u.Scheme != "udp" {
+		return nil, errors.Errorf("unsupported scheme (%s), expected tcp or udp", u.Scheme)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return nil, err
+	}
+
+	return &graphiteSink{network: u.Scheme, addr: u.Host}, nil
+}
+
+// parseInfluxURL returns an influxdb sink for http(s)://host:port/write?db=name,
+// the path defaults to /write and precision is set to ms
+func parseInfluxURL(s string) (*influxSink, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme (%s), expected http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing host")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/write"
+	}
+	q := u.Query()
+	q.Set("precision", "ms")
+	u.RawQuery = q.Encode()
+
+	name := u.Scheme + "://" + u.Host + u.Path
+
+	return &influxSink{url: u.String(), name: name, client: &http.Client{}}, nil
+}
+
+// pushSinks queues metrics for each sink, they are dropped if the sink
+// queue is full. Once the instance has been closed the workers have
+// exited, metrics (e.g. a final Flush) are pushed directly.
+func (m *CirconusMetrics) pushSinks(metrics Metrics) {
+	if len(m.sinks) == 0 {
+		return
+	}
+
+	batch := sinkBatch{metrics: metrics, ts: time.Now()}
+	if m.queueSinks(batch) {
+		return
+	}
+	for _, s := range m.sinks {
+		m.pushSink(s, batch)
+	}
+}
+
+// queueSinks queues batch for each sink worker, started with the first
+// push, false if the instance has been closed
+func (m *CirconusMetrics) queueSinks(batch sinkBatch) bool {
+	m.closemu.RLock()
+	defer m.closemu.RUnlock()
+
+	if m.closed {
+		return false
+	}
+
+	m.sinksOnce.Do(func() {
+		m.sinkQueues = make([]chan sinkBatch, len(m.sinks))
+		for i, s := range m.sinks {
+			m.sinkQueues[i] = make(chan sinkBatch, sinkQueueSize)
+			go m.sinkWorker(s, m.sinkQueues[i])
+		}
+	})
+
+	for i, queue := range m.sinkQueues {
+		select {
+		case queue <- batch:
+		default:
+			m.getLogger().Warn("sink queue full, dropping metrics", "sink", m.sinks[i].String())
+		}
+	}
+
+	return true
+}
+
+// closeSinks closes the sink queues, the workers exit once the queued
+// metrics are pushed. Caller must hold closemu.
+func (m *CirconusMetrics) closeSinks() {
+	m.sinksOnce.Do(func() {}) // not started, never start them
+	for _, queue := range m.sinkQueues {
+		close(queue)
+	}
+}
+
+// sinkWorker pushes the queued metrics to a sink
+func (m *CirconusMetrics) sinkWorker(s pushSink, queue chan sinkBatch) {
+	for batch := range queue {
+		m.pushSink(s, batch)
+	}
+}
+
+// pushSink pushes batch to a sink, limited to sinkTimeout, failures are
+// logged
+func (m *CirconusMetrics) pushSink(s pushSink, batch sinkBatch) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.sinkTimeout())
+	defer cancel()
+
+	if err := s.push(ctx, batch.metrics, batch.ts); err != nil {
+		m.getLogger().Warn("pushing metrics", "sink", s.String(), "err", err)
+	}
+}
+
+// sinkTimeout returns how long a push can take, the flush interval
+// (defaultSinkTimeout when metrics are flushed manually)
+func (m *CirconusMetrics) sinkTimeout() time.Duration {
+	m.settingsmu.RLock()
+	defer m.settingsmu.RUnlock()
+
+	if m.flushInterval > 0 && m.flushInterval < defaultSinkTimeout {
+		return m.flushInterval
+	}
+	return defaultSinkTimeout
+}
+
+func (s *graphiteSink) String() string {
+	return s.network + "://" + s.addr
+}
+
+func (s *graphiteSink) push(ctx context.Context, metrics Metrics, ts time.Time) error {
+	var b bytes.Buffer
+	if err := writeGraphite(&b, metrics, ts); err != nil {
+		return err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return errors.Wrap(err, "connecting to graphite")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return errors.Wrap(err, "setting deadline")
+		}
+	}
+
+	if s.network == "tcp" {
+		_, err = conn.Write(b.Bytes())
+		return errors.Wrap(err, "writing to graphite")
+	}
+
+	// udp, whole lines per datagram
+	data := b.Bytes()
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxUDPPayload {
+			n = bytes.LastIndexByte(data[:maxUDPPayload], '\n') + 1
+			if n == 0 {
+				n = bytes.IndexByte(data, '\n') + 1 // line longer than a datagram
+			}
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return errors.Wrap(err, "writing to graphite")
+		}
+		data = data[n:]
+	}
+
+	return nil
+}
+
+func (s *influxSink) String() string {
+	return s.name
+}
+
+func (s *influxSink) push(ctx context.Context, metrics Metrics, ts time.Time) error {
+	var b bytes.Buffer
+	if err := writeInflux(&b, metrics, ts); err != nil {
+		return err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &b)
+	if err != nil {
+		return errors.Wrap(err, "writing to influxdb")
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "writing to influxdb")
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("writing to influxdb, %s (%s)", resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+)
+
+// Status of metric submission, see CirconusMetrics.Status
+type Status struct {
+	// destination name, blank for the primary destination
+	Name string `json:"name,omitempty"`
+	// metrics can be sent (all shards and destinations are ready)
+	Ready bool `json:"ready"`
+	// check manager state (check bundle, trap url, broker, metrics)
+	Check checkmgr.Status `json:"check"`
+	// time of the last successful and failed submissions (zero if none)
+	LastSubmitSuccess time.Time `json:"last_submit_success"`
+	LastSubmitFailure time.Time `json:"last_submit_failure"`
+	// error from the last failed submission
+	LastSubmitError string `json:"last_submit_error,omitempty"`
+	// number of timestamped metric groups waiting for the next flush
+	QueueDepth int `json:"queue_depth"`
+	// status of each check shard, when the check is sharded
+	Shards []Status `json:"shards,omitempty"`
+	// status of each additional destination
+	Destinations []Status `json:"destinations,omitempty"`
+}
+
+// Status returns the current state of metric submission, for the primary
+// check and any additional destinations
+func (m *CirconusMetrics) Status() Status {
+	s := m.primary.status()
+
+	m.tsm.Lock()
+	s.QueueDepth = len(m.timestamped)
+	m.tsm.Unlock()
+
+	for _, d := range m.destinations {
+		ds := d.status()
+		if !ds.Ready {
+			s.Ready = false
+		}
+		s.Destinations = append(s.Destinations, ds)
+	}
+
+	return s
+}
+
+// StatusHandler returns an http.Handler responding with Status as json,
+// the response code is 503 (service unavailable) until all checks are ready
+func (m *CirconusMetrics) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.Status()
+
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !s.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(data)
+	})
+}
+
+// status returns the state of a destination
+func (d *destination) status() Status {
+	s := Status{Name: d.name, Check: d.check.Status()}
+	s.Ready = s.Check.Ready
+
+	d.statusmu.Lock()
+	s.LastSubmitSuccess = d.lastSuccess
+	s.LastSubmitFailure = d.lastFailure
+	s.LastSubmitError = d.lastError
+	d.statusmu.Unlock()
+
+	if len(d.shards) > 0 {
+		// shard check state is reported with each shard
+		s.Check.Shards = nil
+		for _, shard := range d.shards {
+			s.Shards = append(s.Shards, shard.status())
+		}
+	}
+
+	return s
+}
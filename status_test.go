@@ -0,0 +1,115 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	t.Log("Testing Status")
+
+	server, _ := recordingBroker()
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = server.URL
+	cfg.SubmitPolicy.MaxAttempts = "1"
+	cfg.Destinations = []Destination{{Name: "failing", SubmissionURL: failing.URL}}
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	for !cm.Ready() || !cm.destinations[0].check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Log("before submission")
+	{
+		s := cm.Status()
+		if !s.Ready {
+			t.Fatal("Expected ready")
+		}
+		if !s.LastSubmitSuccess.IsZero() || !s.LastSubmitFailure.IsZero() {
+			t.Fatalf("Expected no submissions, got %+v", s)
+		}
+		if s.Check.TrapURL != server.URL {
+			t.Fatalf("unexpected trap url (%s)", s.Check.TrapURL)
+		}
+	}
+
+	cm.Increment("foo")
+	cm.RecordValueWithTimestamp("bar", 1, time.Now().Add(-time.Minute))
+	cm.AddWithTimestamp("baz", 1, time.Now().Add(-2*time.Minute))
+
+	t.Log("queued timestamped metrics")
+	{
+		if s := cm.Status(); s.QueueDepth != 2 {
+			t.Fatalf("Expected 2 queued groups, got %d", s.QueueDepth)
+		}
+	}
+
+	cm.Flush()
+
+	t.Log("after submission")
+	{
+		s := cm.Status()
+		if s.LastSubmitSuccess.IsZero() || s.LastSubmitError != "" {
+			t.Fatalf("Expected successful submission, got %+v", s)
+		}
+		if s.QueueDepth != 0 {
+			t.Fatalf("Expected empty queue, got %d", s.QueueDepth)
+		}
+		if len(s.Destinations) != 1 {
+			t.Fatalf("Expected 1 destination, got %d", len(s.Destinations))
+		}
+		ds := s.Destinations[0]
+		if ds.Name != "failing" || ds.LastSubmitFailure.IsZero() || ds.LastSubmitError == "" {
+			t.Fatalf("Expected failed submission, got %+v", ds)
+		}
+	}
+
+	t.Log("handler")
+	{
+		rec := httptest.NewRecorder()
+		cm.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		var s Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if !s.Ready || len(s.Destinations) != 1 {
+			t.Fatalf("unexpected status %+v", s)
+		}
+	}
+
+	t.Log("handler, not ready")
+	{
+		d, err := newDestination(Destination{SubmissionURL: server.URL}, false, nil, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		cm.destinations = append(cm.destinations, d)
+
+		rec := httptest.NewRecorder()
+		cm.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected 503, got %d", rec.Code)
+		}
+	}
+}
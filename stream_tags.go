@@ -0,0 +1,103 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+)
+
+// Stream tags are category:value pairs appended to a metric name in the
+// form name|ST[category:value,...]. Each tagged name is a separate metric
+// stream. Categories and values containing characters outside of the
+// allowed set are base64 encoded (e.g. b"aG9zdCBuYW1l").
+
+// Tag is a stream tag
+type Tag struct {
+	Category string
+	Value    string
+}
+
+// Tags is a list of stream tags
+type Tags []Tag
+
+const (
+	tagCategoryChars = "`+!@#$%^&\"'/?._-"
+	tagValueChars    = tagCategoryChars + ":="
+)
+
+// MetricNameWithStreamTags returns metric with the stream tags appended.
+// Tags are sorted, duplicates and tags without a category are dropped.
+func MetricNameWithStreamTags(metric string, tags Tags) string {
+	encoded := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag.Category == "" {
+			continue
+		}
+		t := encodeTag(tag.Category, tagCategoryChars) + ":" + encodeTag(tag.Value, tagValueChars)
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		encoded = append(encoded, t)
+	}
+
+	if len(encoded) == 0 {
+		return metric
+	}
+
+	sort.Strings(encoded)
+
+	return metric + "|ST[" + strings.Join(encoded, ",") + "]"
+}
+
+// encodeTag returns s, base64 encoded if it contains characters other than
+// letters, digits and allowed
+func encodeTag(s, allowed string) string {
+	for _, c := range s {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			continue
+		}
+		if strings.ContainsRune(allowed, c) {
+			continue
+		}
+		return `b"` + base64.StdEncoding.EncodeToString([]byte(s)) + `"`
+	}
+	return s
+}
+
+// parseStreamTags returns the metric name without stream tags and the
+// decoded tags
+func parseStreamTags(metric string) (string, Tags) {
+	i := strings.Index(metric, "|ST[")
+	if i < 0 || !strings.HasSuffix(metric, "]") {
+		return metric, nil
+	}
+
+	var tags Tags
+	for _, tag := range strings.Split(metric[i+4:len(metric)-1], ",") {
+		parts := strings.SplitN(tag, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		tags = append(tags, Tag{Category: decodeTag(parts[0]), Value: decodeTag(parts[1])})
+	}
+
+	return metric[:i], tags
+}
+
+// decodeTag returns s, base64 decoded if encoded (b"...")
+func decodeTag(s string) string {
+	if len(s) < 3 || !strings.HasPrefix(s, `b"`) || !strings.HasSuffix(s, `"`) {
+		return s
+	}
+	data, err := base64.StdEncoding.DecodeString(s[2 : len(s)-1])
+	if err != nil {
+		return s
+	}
+	return string(data)
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMetricNameWithStreamTags(t *testing.T) {
+	t.Log("Testing MetricNameWithStreamTags")
+
+	tests := []struct {
+		tags Tags
+		want string
+	}{
+		{nil, "foo"},
+		{Tags{{"", "bar"}}, "foo"},
+		{Tags{{"host", "web1"}}, "foo|ST[host:web1]"},
+		{Tags{{"region", "us-east-1"}, {"az", "a"}, {"region", "us-east-1"}}, "foo|ST[az:a,region:us-east-1]"},
+		{Tags{{"url", "http://example.com:80/a=b"}}, "foo|ST[url:http://example.com:80/a=b]"},
+		{Tags{{"host name", "web 1"}}, `foo|ST[b"aG9zdCBuYW1l":b"d2ViIDE="]`},
+		{Tags{{"a:b", "c,d"}}, `foo|ST[b"YTpi":b"Yyxk"]`},
+		{Tags{{"empty", ""}}, "foo|ST[empty:]"},
+	}
+
+	for _, test := range tests {
+		if have := MetricNameWithStreamTags("foo", test.tags); have != test.want {
+			t.Fatalf("Expected %q, got %q", test.want, have)
+		}
+	}
+}
+
+func TestParseStreamTags(t *testing.T) {
+	t.Log("Testing parseStreamTags")
+
+	tests := []struct {
+		metric string
+		name   string
+		tags   Tags
+	}{
+		{"foo", "foo", nil},
+		{"foo|ST[", "foo|ST[", nil},
+		{"foo|ST[host:web1]", "foo", Tags{{"host", "web1"}}},
+		{"foo`bar|ST[az:a,url:http://example.com:80/]", "foo`bar", Tags{{"az", "a"}, {"url", "http://example.com:80/"}}},
+		{`foo|ST[b"aG9zdCBuYW1l":b"d2ViIDE="]`, "foo", Tags{{"host name", "web 1"}}},
+	}
+
+	for _, test := range tests {
+		name, tags := parseStreamTags(test.metric)
+		if name != test.name || fmt.Sprint(tags) != fmt.Sprint(test.tags) {
+			t.Fatalf("Expected %s %v, got %s %v", test.name, test.tags, name, tags)
+		}
+	}
+}
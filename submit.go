@@ -6,57 +6,186 @@ package circonusgometrics
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
-	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+	"github.com/circonus-labs/circonus-gometrics/logging"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 )
 
-func (m *CirconusMetrics) submit(output Metrics, newMetrics map[string]*api.CheckBundleMetric) {
+const (
+	defaultSubmitCompression          = compressionNone
+	defaultSubmitCompressionThreshold = "1024"
+	compressionNone                   = "none"
+	compressionGzip                   = "gzip"
+	compressionDeflate                = "deflate"
+	trapMaxIdleConns                  = 2
+	trapIdleConnTimeout               = 90 * time.Second
+)
+
+// SubmitResult describes a successful metric submission, see Config.OnSubmitSuccess
+type SubmitResult struct {
+	Destination string        // destination name, blank for the primary destination
+	Metrics     int           // number of metrics submitted
+	Stats       int           // number of metrics accepted by the broker
+	Submissions int           // number of submissions the metrics were split into
+	Duration    time.Duration // time taken to submit, including retries
+}
+
+// submit sends metrics to each destination, with self metrics added when
+// withSelf is true (once per flush)
+func (m *CirconusMetrics) submit(output Metrics, withSelf bool) {
+	var self Metrics
+	if withSelf {
+		self = m.selfOutput(m.self)
+	}
+
+	prepare := func(d *destination) Metrics {
+		if withSelf {
+			return m.withSelfMetrics(d, output, self)
+		}
+		return output
+	}
+
+	if len(m.destinations) == 0 {
+		m.submitTo(m.primary, prepare(m.primary))
+		return
+	}
+
+	// destinations are independent, a slow or dead
+	// destination does not block submission to the others
+	var wg sync.WaitGroup
+	for _, d := range append([]*destination{m.primary}, m.destinations...) {
+		wg.Add(1)
+		go func(d *destination) {
+			defer wg.Done()
+			m.submitTo(d, d.filterMetrics(prepare(d)))
+		}(d)
+	}
+	wg.Wait()
+}
+
+// submitTo sends metrics to a single destination, only metrics active
+// (or activated) in the destination check are sent
+func (m *CirconusMetrics) submitTo(d *destination, output Metrics) {
 
-	// if there is nowhere to send metrics to, just return.
-	if !m.check.IsReady() {
-		m.Log.Printf("[WARN] check not ready, skipping metric submission")
+	logger := d.logger(m.getLogger())
+
+	if len(output) == 0 {
+		logger.Debug("no metrics to send, skipping")
+		return
+	}
+
+	// if there is nowhere to send metrics to, just return. not ready
+	// is only a failure once the check has been initialized
+	if ready, wasReady := d.checkReady(); !ready {
+		if !wasReady {
+			logger.Debug("check not initialized yet, skipping metric submission")
+			return
+		}
+		logger.Warn("check not ready, skipping metric submission")
+		m.submitDone(d, SubmitResult{}, errors.New("check not ready, skipping metric submission"))
 		return
 	}
 
+	// sharded check, each shard has its own check bundle and trap
+	if len(d.shards) > 0 {
+		outputs := d.splitMetrics(output)
+		var wg sync.WaitGroup
+		for i, shard := range d.shards {
+			wg.Add(1)
+			go func(i int, shard *destination) {
+				defer wg.Done()
+				m.submitTo(shard, outputs[i])
+			}(i, shard)
+		}
+		wg.Wait()
+		return
+	}
+
+	output, newMetrics := d.activeMetrics(output)
+
 	// update check if there are any new metrics or, if metric tags have been added since last submit
-	m.check.UpdateCheck(newMetrics)
+	start := time.Now()
+	d.check.UpdateCheck(newMetrics)
+	m.selfDuration(d.self, selfUpdateCheckDuration, time.Since(start))
+	m.selfCount(d.self, selfMetricsActivated, uint64(len(newMetrics)))
 
-	str, err := json.Marshal(output)
-	if err != nil {
-		m.Log.Printf("[ERROR] marshaling output %+v", err)
+	if len(output) == 0 {
+		logger.Debug("no active metrics to send, skipping")
 		return
 	}
 
-	numStats, err := m.trapCall(str)
+	payloads, err := m.chunkPayloads(output)
 	if err != nil {
-		m.Log.Printf("[ERROR] %+v\n", err)
+		logger.Error("marshaling output", "err", err)
+		m.submitDone(d, SubmitResult{}, errors.Wrap(err, "marshaling output"))
 		return
 	}
 
-	// OK response from circonus-agent does not
-	// indicate how many metrics were received
-	if numStats == -1 {
-		numStats = len(output)
+	if len(payloads) > 1 {
+		logger.Debug("splitting metrics into multiple submissions", "metrics", len(output), "submissions", len(payloads))
 	}
 
-	if m.Debug {
-		m.Log.Printf("[DEBUG] %d stats sent\n", numStats)
+	start = time.Now()
+	numStats, err := m.sendPayloads(d, payloads)
+	m.submitDone(d, SubmitResult{
+		Metrics:     len(output),
+		Stats:       numStats,
+		Submissions: len(payloads),
+		Duration:    time.Since(start),
+	}, err)
+	if err != nil {
+		logger.Error("submitting metrics", "err", err)
+		if numStats == 0 {
+			return
+		}
+	}
+
+	logger.Debug("stats sent", "stats", numStats)
+}
+
+// submitDone records the result of a submission to a destination
+// and calls the submit callbacks
+func (m *CirconusMetrics) submitDone(d *destination, result SubmitResult, err error) {
+	d.recordSubmit(err)
+
+	if err != nil {
+		if m.onSubmitError != nil {
+			if d.name != "" {
+				err = errors.Wrapf(err, "destination %s", d.name)
+			}
+			m.onSubmitError(err)
+		}
+		return
+	}
+
+	if m.onSubmitSuccess != nil {
+		result.Destination = d.name
+		m.onSubmitSuccess(result)
 	}
 }
 
-func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
-	trap, err := m.check.GetSubmissionURL()
+func (m *CirconusMetrics) trapCall(d *destination, payload []byte) (int, error) {
+	trap, err := d.check.GetSubmissionURL()
+	if err != nil {
+		return 0, errors.Wrap(err, "trap call")
+	}
+
+	payload, encoding, err := m.compressPayload(payload)
 	if err != nil {
 		return 0, errors.Wrap(err, "trap call")
 	}
@@ -69,6 +198,20 @@ func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
+	if encoding != "" {
+		req.Header.Add("Content-Encoding", encoding)
+	}
+
+	policy := m.getSubmitPolicy()
+
+	// overall deadline for the submission, including retries
+	var deadline time.Time
+	if policy.timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), policy.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+		deadline, _ = ctx.Deadline()
+	}
 
 	// keep last HTTP error in the event of retry failure
 	var lastHTTPError error
@@ -79,13 +222,13 @@ func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
 
 		if err != nil {
 			lastHTTPError = err
-			return true, errors.Wrap(err, "retry policy")
+			return policy.retriable(0), errors.Wrap(err, "retry policy")
 		}
-		// Check the response code. We retry on 500-range responses to allow
-		// the server time to recover, as 500's are typically not permanent
-		// errors and may relate to outages on the server side. This will catch
-		// invalid response codes as well, like 0 and 999.
-		if resp.StatusCode == 0 || resp.StatusCode >= 500 {
+		// Check the response code against the policy. By default 500-range
+		// responses are retried to allow the server time to recover, as 500's
+		// are typically not permanent errors and may relate to outages on the
+		// server side. This will catch invalid response codes as well, like 0 and 999.
+		if policy.retriable(resp.StatusCode) {
 			body, readErr := ioutil.ReadAll(resp.Body)
 			if readErr != nil {
 				lastHTTPError = fmt.Errorf("- last HTTP error: %d %+v", resp.StatusCode, readErr)
@@ -97,47 +240,14 @@ func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
 		return false, nil
 	}
 
-	client := retryablehttp.NewClient()
-	if trap.URL.Scheme == "https" {
-		client.HTTPClient.Transport = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			Dial: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: 10 * time.Second,
-			TLSClientConfig:     trap.TLS,
-			DisableKeepAlives:   true,
-			MaxIdleConnsPerHost: -1,
-			DisableCompression:  false,
-		}
-	} else if trap.URL.Scheme == "http" {
-		client.HTTPClient.Transport = &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			Dial: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).Dial,
-			DisableKeepAlives:   true,
-			MaxIdleConnsPerHost: -1,
-			DisableCompression:  false,
-		}
-	} else if trap.IsSocket {
-		m.Log.Println("using socket transport")
-		client.HTTPClient.Transport = trap.SockTransport
-	} else {
-		return 0, errors.Errorf("unknown scheme (%s), skipping submission", trap.URL.Scheme)
-	}
-	client.RetryWaitMin = 1 * time.Second
-	client.RetryWaitMax = 5 * time.Second
-	client.RetryMax = 3
-	// retryablehttp only groks log or no log
-	// but, outputs everything as [DEBUG] messages
-	if m.Debug {
-		client.Logger = m.Log
-	} else {
-		client.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
+	trapClient, err := m.trapHTTPClient(d, trap)
+	if err != nil {
+		return 0, err
 	}
+
+	// per submission settings are applied to a copy of the long-lived client
+	client := *trapClient
+	client.Backoff = policy.backoff(deadline)
 	client.CheckRetry = retryPolicy
 
 	attempts := -1
@@ -145,13 +255,19 @@ func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
 		attempts = retryNumber
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	if attempts >= 0 {
+		m.selfDuration(d.self, selfSubmitLatency, time.Since(start))
+		m.selfValue(d.self, selfSubmitAttempts, float64(attempts+1))
+		m.selfCount(d.self, selfSubmitRetries, uint64(attempts))
+	}
 	if err != nil {
 		if lastHTTPError != nil {
 			return 0, fmt.Errorf("[ERROR] submitting: %+v %+v", err, lastHTTPError)
 		}
 		if attempts == client.RetryMax {
-			m.check.RefreshTrap()
+			d.check.RefreshTrap()
 		}
 		return 0, errors.Wrap(err, "trap call")
 	}
@@ -166,12 +282,12 @@ func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		m.Log.Printf("[ERROR] reading body, proceeding. %s\n", err)
+		d.logger(m.getLogger()).Error("reading body, proceeding", "err", err)
 	}
 
 	var response map[string]interface{}
 	if err := json.Unmarshal(body, &response); err != nil {
-		m.Log.Printf("[ERROR] parsing body, proceeding. %v (%s)\n", err, body)
+		d.logger(m.getLogger()).Error("parsing body, proceeding", "err", err, "body", string(body))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -186,3 +302,120 @@ func (m *CirconusMetrics) trapCall(payload []byte) (int, error) {
 	}
 	return 0, errors.New("[ERROR] bad response type")
 }
+
+// compressPayload compresses the payload, if compression is enabled and the
+// payload meets the size threshold, returning the content encoding applied
+func (m *CirconusMetrics) compressPayload(payload []byte) ([]byte, string, error) {
+	if m.compression == "" || m.compression == compressionNone || len(payload) < m.compressionMinSize {
+		return payload, "", nil
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch m.compression {
+	case compressionGzip:
+		w = gzip.NewWriter(&buf)
+	case compressionDeflate:
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, "", errors.Errorf("unknown compression (%s)", m.compression)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return nil, "", errors.Wrap(err, "compressing payload")
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", errors.Wrap(err, "compressing payload")
+	}
+
+	m.getLogger().Debug("compressed payload", "bytes", len(payload), "compressed_bytes", buf.Len(), "compression", m.compression)
+
+	return buf.Bytes(), m.compression, nil
+}
+
+// trapHTTPClient returns the long-lived (retryable) http client used for
+// submissions. Connections are kept alive and reused across flushes, the
+// client is only rebuilt when the trap url, tls config or socket transport
+// changes (e.g. after the check manager resets the trap).
+func (m *CirconusMetrics) trapHTTPClient(d *destination, trap *checkmgr.Trap) (*retryablehttp.Client, error) {
+	d.trapClientmu.Lock()
+	defer d.trapClientmu.Unlock()
+
+	trapURL := trap.URL.String()
+	if d.trapClient != nil &&
+		d.trapClientURL == trapURL &&
+		d.trapClientTLS == trap.TLS &&
+		d.trapClientSock == trap.SockTransport {
+		return d.trapRetryClient, nil
+	}
+
+	policy := m.getSubmitPolicy()
+
+	// keep enough idle connections for concurrent chunk submissions
+	idleConns := trapMaxIdleConns
+	if m.submitWorkers > idleConns {
+		idleConns = m.submitWorkers
+	}
+
+	var transport http.RoundTripper
+	if trap.URL.Scheme == "https" || trap.URL.Scheme == "http" {
+		t := &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   policy.dialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:        idleConns,
+			MaxIdleConnsPerHost: idleConns,
+			IdleConnTimeout:     trapIdleConnTimeout,
+			DisableCompression:  false,
+		}
+		if trap.URL.Scheme == "https" {
+			t.TLSHandshakeTimeout = 10 * time.Second
+			t.TLSClientConfig = trap.TLS
+		}
+		transport = t
+	} else if trap.IsSocket {
+		d.logger(m.getLogger()).Info("using socket transport")
+		transport = trap.SockTransport
+	} else {
+		return nil, errors.Errorf("unknown scheme (%s), skipping submission", trap.URL.Scheme)
+	}
+
+	if d.trapClient != nil {
+		if t, ok := d.trapClient.Transport.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+		d.logger(m.getLogger()).Debug("rebuilding submission client", "url", trapURL)
+	}
+
+	d.trapClient = &http.Client{Transport: transport, Timeout: policy.attemptTimeout}
+	d.trapClientURL = trapURL
+	d.trapClientTLS = trap.TLS
+	d.trapClientSock = trap.SockTransport
+
+	client := retryablehttp.NewClient()
+	client.HTTPClient = d.trapClient
+	client.RetryWaitMin = policy.retryWaitMin
+	client.RetryWaitMax = policy.retryWaitMax
+	client.RetryMax = policy.maxAttempts - 1
+	// retryablehttp only groks a standard logger, forward
+	// its messages (by level) to the leveled logger
+	client.Logger = logging.NewStdLog(d.logger(m.getLogger()))
+	d.trapRetryClient = client
+
+	return d.trapRetryClient, nil
+}
+
+// getSubmitPolicy returns the submission policy, defaults are used if
+// one was not configured (e.g. CirconusMetrics not created with New)
+func (m *CirconusMetrics) getSubmitPolicy() *submitPolicy {
+	m.settingsmu.RLock()
+	defer m.settingsmu.RUnlock()
+
+	if m.submitPolicy != nil {
+		return m.submitPolicy
+	}
+	policy, _ := newSubmitPolicy(SubmitPolicy{}, m.flushInterval) // defaults always parse
+	return policy
+}
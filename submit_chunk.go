@@ -0,0 +1,174 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Large metric sets are split into multiple submissions (chunks) by metric
+// count and payload size, brokers and proxies reject or time out on very
+// large bodies. Chunks are sent concurrently by a bounded number of workers,
+// the stats from each chunk are aggregated and failed chunks are reported.
+
+const (
+	defaultSubmitMaxMetrics = "10000"
+	defaultSubmitMaxBytes   = "4194304" // 4MB
+	defaultSubmitWorkers    = "4"
+)
+
+// submitPayload is a marshaled chunk of metrics
+type submitPayload struct {
+	data  []byte
+	count int
+}
+
+// chunkPayloads marshals metrics into one or more payloads, each limited to
+// maxSubmitMetrics metrics and maxSubmitBytes bytes (0 is no limit). A single
+// metric larger than maxSubmitBytes is sent in a payload of its own.
+func (m *CirconusMetrics) chunkPayloads(output Metrics) ([]submitPayload, error) {
+	names := make([]string, 0, len(output))
+	for name := range output {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	payloads := []submitPayload{}
+	var buf bytes.Buffer
+	count := 0
+
+	closeChunk := func() {
+		buf.WriteByte('}')
+		data := make([]byte, buf.Len())
+		copy(data, buf.Bytes())
+		payloads = append(payloads, submitPayload{data: data, count: count})
+		buf.Reset()
+		count = 0
+	}
+
+	for _, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshaling metric name %s", name)
+		}
+		val, err := json.Marshal(output[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshaling metric %s", name)
+		}
+
+		if count > 0 {
+			full := m.maxSubmitMetrics > 0 && count >= m.maxSubmitMetrics
+			// current + separator + key:value + closing brace
+			if m.maxSubmitBytes > 0 && buf.Len()+1+len(key)+1+len(val)+1 > m.maxSubmitBytes {
+				full = true
+			}
+			if full {
+				closeChunk()
+			}
+		}
+
+		if count == 0 {
+			buf.WriteByte('{')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+		count++
+	}
+
+	if count > 0 {
+		closeChunk()
+	}
+
+	return payloads, nil
+}
+
+// sendPayloads submits payloads to a destination using up to submitWorkers
+// concurrent submissions. Returns the total number of stats accepted and, if
+// any payloads failed, an error describing the failures.
+func (m *CirconusMetrics) sendPayloads(d *destination, payloads []submitPayload) (int, error) {
+	type result struct {
+		numStats int
+		err      error
+	}
+
+	results := make([]result, len(payloads))
+
+	send := func(i int) {
+		m.selfValue(d.self, selfPayloadBytes, float64(len(payloads[i].data)))
+		numStats, err := m.trapCall(d, payloads[i].data)
+		// OK response from circonus-agent does not
+		// indicate how many metrics were received
+		if err == nil && numStats == -1 {
+			numStats = payloads[i].count
+		}
+		results[i] = result{numStats: numStats, err: err}
+	}
+
+	workers := m.submitWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(payloads) {
+		workers = len(payloads)
+	}
+
+	if workers <= 1 {
+		for i := range payloads {
+			send(i)
+		}
+	} else {
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					send(i)
+				}
+			}()
+		}
+		for i := range payloads {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	numStats := 0
+	failed := 0
+	unsent := 0
+	errs := []string{}
+	for i, r := range results {
+		if r.err != nil {
+			failed++
+			unsent += payloads[i].count
+			errs = append(errs, r.err.Error())
+			continue
+		}
+		numStats += r.numStats
+	}
+
+	m.selfCount(d.self, selfSubmitFailures, uint64(failed))
+
+	if failed == 0 {
+		return numStats, nil
+	}
+
+	if len(payloads) == 1 {
+		return numStats, results[0].err
+	}
+
+	return numStats, errors.Errorf("%d of %d submissions failed (%d metrics not sent): %s", failed, len(payloads), unsent, strings.Join(errs, "; "))
+}
@@ -0,0 +1,342 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testChunkMetrics(n int) Metrics {
+	output := make(Metrics, n)
+	for i := 0; i < n; i++ {
+		output[fmt.Sprintf("metric%05d", i)] = Metric{Type: "L", Value: uint64(i)}
+	}
+	return output
+}
+
+func TestChunkPayloads(t *testing.T) {
+	t.Log("Testing submit_chunk.chunkPayloads")
+
+	t.Log("no limits, identical to single marshal")
+	{
+		cm := &CirconusMetrics{}
+		output := testChunkMetrics(100)
+		output["<html>&"] = Metric{Type: "s", Value: "a<b>"}
+
+		payloads, err := cm.chunkPayloads(output)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(payloads) != 1 {
+			t.Fatalf("Expected 1 payload, got %d", len(payloads))
+		}
+		expect, err := json.Marshal(output)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if string(payloads[0].data) != string(expect) {
+			t.Fatalf("Expected %s, got %s", expect, payloads[0].data)
+		}
+		if payloads[0].count != 101 {
+			t.Fatalf("Expected 101, got %d", payloads[0].count)
+		}
+	}
+
+	t.Log("by metric count")
+	{
+		cm := &CirconusMetrics{maxSubmitMetrics: 30}
+		output := testChunkMetrics(100)
+
+		payloads, err := cm.chunkPayloads(output)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(payloads) != 4 {
+			t.Fatalf("Expected 4 payloads, got %d", len(payloads))
+		}
+
+		seen := Metrics{}
+		for i, p := range payloads {
+			var chunk Metrics
+			if err := json.Unmarshal(p.data, &chunk); err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			if len(chunk) != p.count {
+				t.Fatalf("Expected %d, got %d", p.count, len(chunk))
+			}
+			if i < 3 && p.count != 30 {
+				t.Fatalf("Expected 30, got %d", p.count)
+			}
+			for name, val := range chunk {
+				seen[name] = val
+			}
+		}
+		if len(seen) != 100 {
+			t.Fatalf("Expected 100 metrics, got %d", len(seen))
+		}
+	}
+
+	t.Log("by byte size")
+	{
+		cm := &CirconusMetrics{maxSubmitBytes: 512}
+		output := testChunkMetrics(100)
+
+		payloads, err := cm.chunkPayloads(output)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(payloads) < 2 {
+			t.Fatalf("Expected multiple payloads, got %d", len(payloads))
+		}
+		total := 0
+		for _, p := range payloads {
+			if len(p.data) > 512 {
+				t.Fatalf("Expected <= 512 bytes, got %d", len(p.data))
+			}
+			var chunk Metrics
+			if err := json.Unmarshal(p.data, &chunk); err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			total += len(chunk)
+		}
+		if total != 100 {
+			t.Fatalf("Expected 100 metrics, got %d", total)
+		}
+	}
+
+	t.Log("oversized metric")
+	{
+		cm := &CirconusMetrics{maxSubmitBytes: 10}
+		output := Metrics{"a": Metric{Type: "s", Value: strings.Repeat("x", 100)}, "b": Metric{Type: "n", Value: 1}}
+
+		payloads, err := cm.chunkPayloads(output)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(payloads) != 2 {
+			t.Fatalf("Expected 2 payloads, got %d", len(payloads))
+		}
+	}
+
+	t.Log("empty")
+	{
+		cm := &CirconusMetrics{}
+		payloads, err := cm.chunkPayloads(Metrics{})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if len(payloads) != 0 {
+			t.Fatalf("Expected 0 payloads, got %d", len(payloads))
+		}
+	}
+}
+
+func TestSendPayloads(t *testing.T) {
+	t.Log("Testing submit_chunk.sendPayloads")
+
+	var mu sync.Mutex
+	received := 0
+	var active, maxActive int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		var chunk Metrics
+		if err := json.Unmarshal(body, &chunk); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, ok := chunk["metric00000"]; ok {
+			// fail the first chunk, not retriable
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, `{"error":"rejected"}`)
+			return
+		}
+		mu.Lock()
+		received += len(chunk)
+		mu.Unlock()
+		w.WriteHeader(200)
+		fmt.Fprintf(w, `{"stats":%d}`, len(chunk))
+	}))
+	defer server.Close()
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = server.URL
+	cfg.SubmitMaxMetrics = "10"
+	cfg.SubmitWorkers = "3"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	for !cm.check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	payloads, err := cm.chunkPayloads(testChunkMetrics(100))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(payloads) != 10 {
+		t.Fatalf("Expected 10 payloads, got %d", len(payloads))
+	}
+
+	numStats, err := cm.sendPayloads(cm.primary, payloads)
+	if err == nil {
+		t.Fatal("Expected error for partial failure")
+	}
+	if !strings.Contains(err.Error(), "1 of 10 submissions failed (10 metrics not sent)") {
+		t.Fatalf("unexpected error (%s)", err)
+	}
+	if numStats != 90 {
+		t.Fatalf("Expected 90 stats, got %d", numStats)
+	}
+	if received != 90 {
+		t.Fatalf("Expected 90 received, got %d", received)
+	}
+	if n := atomic.LoadInt32(&maxActive); n < 2 || n > 3 {
+		t.Fatalf("Expected 2-3 concurrent submissions, got %d", n)
+	}
+}
+
+func TestSendPayloadsConcurrentTraps(t *testing.T) {
+	t.Log("Testing submit_chunk.sendPayloads concurrency (https and http+unix)")
+
+	var received int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var chunk Metrics
+		if err := json.Unmarshal(body, &chunk); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(&received, int32(len(chunk)))
+		w.WriteHeader(200)
+		fmt.Fprintf(w, `{"stats":%d}`, len(chunk))
+	})
+
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+
+	dir, err := ioutil.TempDir("", "cgm")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockFile := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sockFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer l.Close()
+	go http.Serve(l, handler)
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(tlsServer.Certificate())
+
+	traps := map[string]string{
+		"https":     tlsServer.URL,
+		"http+unix": "http+unix://" + sockFile + "/write/test",
+	}
+
+	for scheme, submissionURL := range traps {
+		t.Logf("\t%s", scheme)
+
+		atomic.StoreInt32(&received, 0)
+
+		var submitErr error
+		cfg := &Config{
+			Interval:         "0",
+			SubmitMaxMetrics: "1",
+			SubmitWorkers:    "8",
+			OnSubmitError:    func(err error) { submitErr = err },
+		}
+		cfg.CheckManager.Check.SubmissionURL = submissionURL
+		cfg.CheckManager.Broker.TLSConfig = &tls.Config{RootCAs: certPool}
+
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for !cm.check.IsReady() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		for i := 0; i < 50; i++ {
+			cm.Increment(fmt.Sprintf("counter%02d", i))
+		}
+		cm.Flush()
+
+		if submitErr != nil {
+			t.Fatalf("%s: expected no error, got '%v'", scheme, submitErr)
+		}
+		if n := atomic.LoadInt32(&received); n != 50 {
+			t.Fatalf("%s: expected 50 metrics received, got %d", scheme, n)
+		}
+	}
+}
+
+func TestNewSubmitSplitting(t *testing.T) {
+	t.Log("Testing submission splitting settings")
+
+	tests := []struct {
+		cfg         Config
+		shouldFail  bool
+		maxMetrics  int
+		maxBytes    int
+		workerCount int
+	}{
+		{Config{}, false, 10000, 4194304, 4},
+		{Config{SubmitMaxMetrics: "0", SubmitMaxBytes: "0", SubmitWorkers: "1"}, false, 0, 0, 1},
+		{Config{SubmitMaxMetrics: "foo"}, true, 0, 0, 0},
+		{Config{SubmitMaxBytes: "foo"}, true, 0, 0, 0},
+		{Config{SubmitWorkers: "foo"}, true, 0, 0, 0},
+		{Config{SubmitWorkers: "0"}, true, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		cfg := test.cfg
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = "none"
+
+		cm, err := NewCirconusMetrics(&cfg)
+		if test.shouldFail {
+			if err == nil {
+				t.Fatalf("Expected error for %+v", test.cfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if cm.maxSubmitMetrics != test.maxMetrics || cm.maxSubmitBytes != test.maxBytes || cm.submitWorkers != test.workerCount {
+			t.Fatalf("Expected %d/%d/%d, got %d/%d/%d", test.maxMetrics, test.maxBytes, test.workerCount, cm.maxSubmitMetrics, cm.maxSubmitBytes, cm.submitWorkers)
+		}
+	}
+}
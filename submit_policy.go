@@ -0,0 +1,223 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSubmitMaxAttempts      = "4"
+	defaultSubmitRetryWaitMin     = "1s"
+	defaultSubmitRetryWaitMax     = "5s"
+	defaultSubmitBackoff          = backoffExponential
+	defaultSubmitJitter           = "false"
+	defaultSubmitDialTimeout      = "30s"
+	defaultSubmitRetryStatusCodes = "0,500-999" // connection level (0) and server errors, including invalid codes
+	backoffExponential            = "exponential"
+	backoffLinear                 = "linear"
+)
+
+// SubmitPolicy options for retries and timeouts when submitting metrics.
+// This is separate from the API client retry policy (CheckManager.API).
+type SubmitPolicy struct {
+	// maximum number of attempts for a submission, including the first (default 4)
+	MaxAttempts string
+	// minimum amount of time to wait between attempts (default 1s)
+	RetryWaitMin string
+	// maximum amount of time to wait between attempts (default 5s)
+	RetryWaitMax string
+	// shape of the backoff between attempts, exponential|linear (default exponential)
+	Backoff string
+	// add random jitter to the backoff between attempts "(true|false)" (default false)
+	Jitter string
+	// maximum amount of time for a single attempt (default none)
+	AttemptTimeout string
+	// maximum amount of time for a submission, including all attempts and waits.
+	// bounded by the flush interval (default flush interval, none if flush interval is 0)
+	Timeout string
+	// maximum amount of time to establish a connection (default 30s)
+	DialTimeout string
+	// comma separated list of response status codes, or ranges of codes, which
+	// will be retried. 0 represents connection errors (default "0,500-999")
+	RetryStatusCodes string
+}
+
+type statusCodeRange struct {
+	min int
+	max int
+}
+
+// submitPolicy is the parsed form of SubmitPolicy
+type submitPolicy struct {
+	maxAttempts      int
+	retryWaitMin     time.Duration
+	retryWaitMax     time.Duration
+	linear           bool
+	jitter           bool
+	attemptTimeout   time.Duration
+	timeout          time.Duration
+	dialTimeout      time.Duration
+	retryStatusCodes []statusCodeRange
+}
+
+// newSubmitPolicy parses submit policy settings, the overall timeout is bounded by the flush interval
+func newSubmitPolicy(cfg SubmitPolicy, flushInterval time.Duration) (*submitPolicy, error) {
+	p := &submitPolicy{}
+
+	setting := func(val, def string) string {
+		if val != "" {
+			return val
+		}
+		return def
+	}
+
+	attempts, err := strconv.Atoi(setting(cfg.MaxAttempts, defaultSubmitMaxAttempts))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing max attempts")
+	}
+	if attempts < 1 {
+		return nil, errors.Errorf("invalid max attempts (%d), must be >= 1", attempts)
+	}
+	p.maxAttempts = attempts
+
+	p.retryWaitMin, err = time.ParseDuration(setting(cfg.RetryWaitMin, defaultSubmitRetryWaitMin))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing retry wait min")
+	}
+
+	p.retryWaitMax, err = time.ParseDuration(setting(cfg.RetryWaitMax, defaultSubmitRetryWaitMax))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing retry wait max")
+	}
+	if p.retryWaitMax < p.retryWaitMin {
+		return nil, errors.Errorf("invalid retry wait max (%s), must be >= retry wait min (%s)", p.retryWaitMax, p.retryWaitMin)
+	}
+
+	switch backoff := setting(cfg.Backoff, defaultSubmitBackoff); backoff {
+	case backoffExponential:
+		p.linear = false
+	case backoffLinear:
+		p.linear = true
+	default:
+		return nil, errors.Errorf("invalid backoff (%s)", backoff)
+	}
+
+	p.jitter, err = strconv.ParseBool(setting(cfg.Jitter, defaultSubmitJitter))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing jitter")
+	}
+
+	if cfg.AttemptTimeout != "" {
+		p.attemptTimeout, err = time.ParseDuration(cfg.AttemptTimeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing attempt timeout")
+		}
+	}
+
+	p.timeout = flushInterval
+	if cfg.Timeout != "" {
+		p.timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing timeout")
+		}
+		if flushInterval > 0 && (p.timeout == 0 || p.timeout > flushInterval) {
+			p.timeout = flushInterval
+		}
+	}
+
+	p.dialTimeout, err = time.ParseDuration(setting(cfg.DialTimeout, defaultSubmitDialTimeout))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing dial timeout")
+	}
+
+	p.retryStatusCodes, err = parseStatusCodes(setting(cfg.RetryStatusCodes, defaultSubmitRetryStatusCodes))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing retry status codes")
+	}
+
+	return p, nil
+}
+
+// parseStatusCodes parses a list of status codes and code ranges (e.g. 429,500-599)
+func parseStatusCodes(codes string) ([]statusCodeRange, error) {
+	ranges := []statusCodeRange{}
+	for _, code := range strings.Split(strings.Replace(codes, " ", "", -1), ",") {
+		if code == "" {
+			continue
+		}
+		bounds := strings.SplitN(code, "-", 2)
+		min, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		max := min
+		if len(bounds) == 2 {
+			max, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if max < min {
+			return nil, errors.Errorf("invalid status code range (%s)", code)
+		}
+		ranges = append(ranges, statusCodeRange{min: min, max: max})
+	}
+	return ranges, nil
+}
+
+// retriable determines if a response status code should be retried
+func (p *submitPolicy) retriable(code int) bool {
+	for _, r := range p.retryStatusCodes {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns a retryablehttp.Backoff implementing the policy, waits
+// are shortened so they do not extend past the deadline (if not zero)
+func (p *submitPolicy) backoff(deadline time.Time) func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		var wait time.Duration
+		if p.linear {
+			wait = min * time.Duration(attemptNum+1)
+		} else {
+			mult := math.Pow(2, float64(attemptNum)) * float64(min)
+			wait = time.Duration(mult)
+			if float64(wait) != mult {
+				wait = max
+			}
+		}
+		if wait > max || wait < 0 {
+			wait = max
+		}
+
+		if p.jitter && wait > 0 {
+			// equal jitter, wait somewhere between half and all of the backoff
+			half := wait / 2
+			wait = half + time.Duration(rand.Int63n(int64(wait-half)+1))
+		}
+
+		if !deadline.IsZero() {
+			if remaining := time.Until(deadline); wait > remaining {
+				wait = remaining
+			}
+			if wait < 0 {
+				wait = 0
+			}
+		}
+
+		return wait
+	}
+}
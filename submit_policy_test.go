@@ -0,0 +1,300 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewSubmitPolicy(t *testing.T) {
+	t.Log("Testing submit_policy.newSubmitPolicy")
+
+	t.Log("defaults")
+	{
+		p, err := newSubmitPolicy(SubmitPolicy{}, 10*time.Second)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if p.maxAttempts != 4 {
+			t.Fatalf("Expected 4, got %d", p.maxAttempts)
+		}
+		if p.retryWaitMin != time.Second || p.retryWaitMax != 5*time.Second {
+			t.Fatalf("Expected 1s/5s, got %s/%s", p.retryWaitMin, p.retryWaitMax)
+		}
+		if p.linear || p.jitter {
+			t.Fatal("Expected exponential backoff without jitter")
+		}
+		if p.attemptTimeout != 0 {
+			t.Fatalf("Expected 0, got %s", p.attemptTimeout)
+		}
+		if p.timeout != 10*time.Second {
+			t.Fatalf("Expected 10s, got %s", p.timeout)
+		}
+		if p.dialTimeout != 30*time.Second {
+			t.Fatalf("Expected 30s, got %s", p.dialTimeout)
+		}
+	}
+
+	t.Log("timeout bounded by flush interval")
+	{
+		p, err := newSubmitPolicy(SubmitPolicy{Timeout: "1m"}, 10*time.Second)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if p.timeout != 10*time.Second {
+			t.Fatalf("Expected 10s, got %s", p.timeout)
+		}
+
+		p, err = newSubmitPolicy(SubmitPolicy{Timeout: "2s"}, 10*time.Second)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if p.timeout != 2*time.Second {
+			t.Fatalf("Expected 2s, got %s", p.timeout)
+		}
+	}
+
+	t.Log("no timeout, manual flushes")
+	{
+		p, err := newSubmitPolicy(SubmitPolicy{}, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if p.timeout != 0 {
+			t.Fatalf("Expected 0, got %s", p.timeout)
+		}
+	}
+
+	t.Log("invalid settings")
+	{
+		tests := []SubmitPolicy{
+			{MaxAttempts: "foo"},
+			{MaxAttempts: "0"},
+			{RetryWaitMin: "foo"},
+			{RetryWaitMax: "foo"},
+			{RetryWaitMin: "10s", RetryWaitMax: "1s"},
+			{Backoff: "foo"},
+			{Jitter: "foo"},
+			{AttemptTimeout: "foo"},
+			{Timeout: "foo"},
+			{DialTimeout: "foo"},
+			{RetryStatusCodes: "foo"},
+			{RetryStatusCodes: "599-500"},
+		}
+		for _, cfg := range tests {
+			if _, err := newSubmitPolicy(cfg, 0); err == nil {
+				t.Fatalf("Expected error for %+v", cfg)
+			}
+		}
+	}
+}
+
+func TestSubmitPolicyRetriable(t *testing.T) {
+	t.Log("Testing submit_policy.retriable")
+
+	t.Log("defaults")
+	{
+		p, err := newSubmitPolicy(SubmitPolicy{}, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for code, expect := range map[int]bool{0: true, 200: false, 404: false, 429: false, 500: true, 503: true, 999: true} {
+			if p.retriable(code) != expect {
+				t.Fatalf("Expected %v for %d", expect, code)
+			}
+		}
+	}
+
+	t.Log("custom")
+	{
+		p, err := newSubmitPolicy(SubmitPolicy{RetryStatusCodes: "429, 502-504"}, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for code, expect := range map[int]bool{0: false, 429: true, 500: false, 502: true, 504: true, 505: false} {
+			if p.retriable(code) != expect {
+				t.Fatalf("Expected %v for %d", expect, code)
+			}
+		}
+	}
+}
+
+func TestSubmitPolicyBackoff(t *testing.T) {
+	t.Log("Testing submit_policy.backoff")
+
+	min := 100 * time.Millisecond
+	max := time.Second
+
+	t.Log("exponential")
+	{
+		p, _ := newSubmitPolicy(SubmitPolicy{}, 0)
+		backoff := p.backoff(time.Time{})
+		expect := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+		for i, e := range expect {
+			if w := backoff(min, max, i, nil); w != e {
+				t.Fatalf("attempt %d expected %s, got %s", i, e, w)
+			}
+		}
+		if w := backoff(min, max, 100, nil); w != max {
+			t.Fatalf("Expected %s, got %s", max, w)
+		}
+	}
+
+	t.Log("linear")
+	{
+		p, _ := newSubmitPolicy(SubmitPolicy{Backoff: "linear"}, 0)
+		backoff := p.backoff(time.Time{})
+		expect := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 400 * time.Millisecond}
+		for i, e := range expect {
+			if w := backoff(min, max, i, nil); w != e {
+				t.Fatalf("attempt %d expected %s, got %s", i, e, w)
+			}
+		}
+	}
+
+	t.Log("jitter")
+	{
+		p, _ := newSubmitPolicy(SubmitPolicy{Jitter: "true"}, 0)
+		backoff := p.backoff(time.Time{})
+		for i := 0; i < 100; i++ {
+			w := backoff(min, max, 2, nil)
+			if w < 200*time.Millisecond || w > 400*time.Millisecond {
+				t.Fatalf("Expected 200ms-400ms, got %s", w)
+			}
+		}
+	}
+
+	t.Log("bounded by deadline")
+	{
+		p, _ := newSubmitPolicy(SubmitPolicy{}, 0)
+		backoff := p.backoff(time.Now().Add(50 * time.Millisecond))
+		if w := backoff(min, max, 3, nil); w > 50*time.Millisecond {
+			t.Fatalf("Expected <= 50ms, got %s", w)
+		}
+		backoff = p.backoff(time.Now().Add(-time.Second))
+		if w := backoff(min, max, 3, nil); w != 0 {
+			t.Fatalf("Expected 0, got %s", w)
+		}
+	}
+}
+
+func TestTrapCallSubmitPolicy(t *testing.T) {
+	t.Log("Testing submit.trapCall with submit policy")
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(200)
+		fmt.Fprintln(w, `{"stats":1}`)
+	}))
+	defer server.Close()
+
+	newMetrics := func(policy SubmitPolicy) *CirconusMetrics {
+		cfg := &Config{}
+		cfg.Interval = "0"
+		cfg.CheckManager.Check.SubmissionURL = server.URL
+		cfg.SubmitPolicy = policy
+
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		for !cm.check.IsReady() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return cm
+	}
+
+	t.Log("not retriable (default)")
+	{
+		atomic.StoreInt32(&calls, 0)
+		cm := newMetrics(SubmitPolicy{})
+		if _, err := cm.trapCall(cm.primary, []byte(`{"foo":{"_type":"n","_value":1}}`)); err == nil {
+			t.Fatal("Expected error")
+		}
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Fatalf("Expected 1 attempt, got %d", n)
+		}
+	}
+
+	t.Log("retriable")
+	{
+		atomic.StoreInt32(&calls, 0)
+		cm := newMetrics(SubmitPolicy{RetryStatusCodes: "429", RetryWaitMin: "1ms", RetryWaitMax: "5ms"})
+		numStats, err := cm.trapCall(cm.primary, []byte(`{"foo":{"_type":"n","_value":1}}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if numStats != 1 {
+			t.Fatalf("Expected 1, got %d", numStats)
+		}
+		if n := atomic.LoadInt32(&calls); n != 3 {
+			t.Fatalf("Expected 3 attempts, got %d", n)
+		}
+	}
+
+	t.Log("max attempts")
+	{
+		atomic.StoreInt32(&calls, 0)
+		cm := newMetrics(SubmitPolicy{MaxAttempts: "2", RetryStatusCodes: "429", RetryWaitMin: "1ms", RetryWaitMax: "5ms"})
+		if _, err := cm.trapCall(cm.primary, []byte(`{"foo":{"_type":"n","_value":1}}`)); err == nil {
+			t.Fatal("Expected error")
+		}
+		if n := atomic.LoadInt32(&calls); n != 2 {
+			t.Fatalf("Expected 2 attempts, got %d", n)
+		}
+	}
+}
+
+func TestTrapCallSubmitPolicyTimeout(t *testing.T) {
+	t.Log("Testing submit.trapCall with submit policy timeouts")
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(200)
+		fmt.Fprintln(w, `{"stats":1}`)
+	}))
+	defer server.Close()
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = server.URL
+	cfg.SubmitPolicy = SubmitPolicy{
+		AttemptTimeout: "50ms",
+		Timeout:        "300ms",
+		RetryWaitMin:   "10ms",
+		RetryWaitMax:   "10ms",
+		MaxAttempts:    "100",
+	}
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	for !cm.check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if _, err := cm.trapCall(cm.primary, []byte(`{"foo":{"_type":"n","_value":1}}`)); err == nil {
+		t.Fatal("Expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected submission to stop at the overall timeout, took %s", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n < 2 || n > 10 {
+		t.Fatalf("Expected a few attempts within the timeout, got %d", n)
+	}
+}
@@ -5,14 +5,24 @@
 package circonusgometrics
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/circonus-labs/circonus-gometrics/api"
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
 )
 
 func fakeBroker() *httptest.Server {
@@ -39,13 +49,12 @@ func TestSubmit(t *testing.T) {
 		t.Errorf("Expected no error, got '%v'", err)
 	}
 
-	newMetrics := make(map[string]*api.CheckBundleMetric)
 	output := Metrics{"foo": Metric{Type: "n", Value: 1}}
 	// output["foo"] = map[string]interface{}{
 	// 	"_type":  "n",
 	// 	"_value": 1,
 	// }
-	cm.submit(output, newMetrics)
+	cm.submit(output, false)
 }
 
 func TestTrapCall(t *testing.T) {
@@ -78,7 +87,7 @@ func TestTrapCall(t *testing.T) {
 		t.Errorf("Expected no error, got '%v'", err)
 	}
 
-	numStats, err := cm.trapCall(str)
+	numStats, err := cm.trapCall(cm.primary, str)
 	if err != nil {
 		t.Errorf("Expected no error, got '%v'", err)
 	}
@@ -87,3 +96,407 @@ func TestTrapCall(t *testing.T) {
 		t.Errorf("Expected 1, got %d", numStats)
 	}
 }
+
+// decodingBroker returns a handler which decodes (per Content-Encoding) and
+// parses submitted payloads, sending the encoding used on the encodings channel
+func decodingBroker(t *testing.T, encodings chan<- string, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		var body io.Reader = r.Body
+		encoding := r.Header.Get("Content-Encoding")
+		switch encoding {
+		case "gzip":
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip reader %v", err)
+				w.WriteHeader(400)
+				return
+			}
+			body = zr
+		case "deflate":
+			zr, err := zlib.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("zlib reader %v", err)
+				w.WriteHeader(400)
+				return
+			}
+			body = zr
+		}
+
+		var v map[string]interface{}
+		if err := json.NewDecoder(body).Decode(&v); err != nil {
+			t.Errorf("decoding payload (%s) %v", encoding, err)
+			w.WriteHeader(400)
+			return
+		}
+
+		encodings <- encoding
+
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			fmt.Fprintf(w, `{"stats":%d}`, len(v))
+		}
+	}
+}
+
+func TestTrapCallCompression(t *testing.T) {
+	t.Log("Testing submit.trapCall compression")
+
+	payload := []byte(`{"foo":{"_type":"n","_value":1},"bar":{"_type":"n","_value":2}}`)
+
+	tests := []struct {
+		compression string
+		threshold   string
+		expected    string
+	}{
+		{"", "", ""},
+		{"none", "0", ""},
+		{"gzip", "0", "gzip"},
+		{"deflate", "0", "deflate"},
+		{"gzip", "4096", ""},
+	}
+
+	encodings := make(chan string, 1)
+	server := httptest.NewServer(decodingBroker(t, encodings, http.StatusOK))
+	defer server.Close()
+
+	for _, test := range tests {
+		cfg := &Config{
+			SubmitCompression:          test.compression,
+			SubmitCompressionThreshold: test.threshold,
+		}
+		cfg.CheckManager.Check.SubmissionURL = server.URL
+
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		for !cm.check.IsReady() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		numStats, err := cm.trapCall(cm.primary, payload)
+		if err != nil {
+			t.Fatalf("%s: Expected no error, got '%v'", test.compression, err)
+		}
+		if numStats != 2 {
+			t.Fatalf("%s: Expected 2 stats, got %d", test.compression, numStats)
+		}
+		if encoding := <-encodings; encoding != test.expected {
+			t.Fatalf("Expected encoding '%s', got '%s'", test.expected, encoding)
+		}
+	}
+
+	t.Log("invalid settings")
+	{
+		cfg := &Config{SubmitCompression: "br"}
+		cfg.CheckManager.Check.SubmissionURL = server.URL
+		if _, err := NewCirconusMetrics(cfg); err == nil {
+			t.Fatal("Expected error")
+		}
+
+		cfg = &Config{SubmitCompressionThreshold: "1k"}
+		cfg.CheckManager.Check.SubmissionURL = server.URL
+		if _, err := NewCirconusMetrics(cfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
+
+func TestTrapCallCompressionSocket(t *testing.T) {
+	t.Log("Testing submit.trapCall compression (http+unix)")
+
+	dir, err := ioutil.TempDir("", "cgm")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockFile := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sockFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer l.Close()
+
+	encodings := make(chan string, 1)
+	go http.Serve(l, decodingBroker(t, encodings, http.StatusNoContent))
+
+	cfg := &Config{
+		SubmitCompression:          "gzip",
+		SubmitCompressionThreshold: "0",
+	}
+	cfg.CheckManager.Check.SubmissionURL = "http+unix://" + sockFile + "/write/test"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	for !cm.check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	numStats, err := cm.trapCall(cm.primary, []byte(`{"foo":{"_type":"n","_value":1}}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if numStats != -1 {
+		t.Fatalf("Expected -1 stats, got %d", numStats)
+	}
+	if encoding := <-encodings; encoding != "gzip" {
+		t.Fatalf("Expected encoding 'gzip', got '%s'", encoding)
+	}
+}
+
+func TestTrapHTTPClient(t *testing.T) {
+	t.Log("Testing submit.trapHTTPClient")
+
+	var newConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		w.WriteHeader(200)
+		fmt.Fprintln(w, `{"stats":1}`)
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = server.URL
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	for !cm.check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	trap, err := cm.check.GetSubmissionURL()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	t.Log("same trap, same client")
+	{
+		c1, err := cm.trapHTTPClient(cm.primary, trap)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		c2, err := cm.trapHTTPClient(cm.primary, trap)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if c1 != c2 {
+			t.Fatal("Expected client to be reused")
+		}
+	}
+
+	t.Log("connection reused across submissions")
+	{
+		for i := 0; i < 5; i++ {
+			if _, err := cm.trapCall(cm.primary, []byte(`{"foo":{"_type":"n","_value":1}}`)); err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+		}
+		if n := atomic.LoadInt32(&newConns); n != 1 {
+			t.Fatalf("Expected 1 connection, got %d", n)
+		}
+	}
+
+	t.Log("different trap url, new client")
+	{
+		c1, err := cm.trapHTTPClient(cm.primary, trap)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		u := *trap.URL
+		u.Path = "/other"
+		c2, err := cm.trapHTTPClient(cm.primary, &checkmgr.Trap{URL: &u})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if c1 == c2 {
+			t.Fatal("Expected client to be rebuilt")
+		}
+	}
+
+	t.Log("unknown scheme")
+	{
+		u := *trap.URL
+		u.Scheme = "ftp"
+		if _, err := cm.trapHTTPClient(cm.primary, &checkmgr.Trap{URL: &u}); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
+
+// benchTLSMetrics returns a cgm instance submitting to a local tls broker
+func benchTLSMetrics(b *testing.B) (*CirconusMetrics, *httptest.Server) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		w.WriteHeader(200)
+		fmt.Fprintln(w, `{"stats":1}`)
+	}))
+
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = server.URL
+	cfg.CheckManager.Broker.TLSConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	cfg.Interval = "0"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		b.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	for !cm.check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return cm, server
+}
+
+func BenchmarkTrapCallPooledClient(b *testing.B) {
+	cm, server := benchTLSMetrics(b)
+	defer server.Close()
+
+	payload := []byte(`{"foo":{"_type":"n","_value":1}}`)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cm.trapCall(cm.primary, payload); err != nil {
+			b.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+}
+
+func BenchmarkTrapCallNewClient(b *testing.B) {
+	cm, server := benchTLSMetrics(b)
+	defer server.Close()
+
+	payload := []byte(`{"foo":{"_type":"n","_value":1}}`)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// force a new client (new tcp+tls handshake) for every submission
+		cm.primary.trapClientmu.Lock()
+		if cm.primary.trapClient != nil {
+			cm.primary.trapClient.Transport.(*http.Transport).CloseIdleConnections()
+		}
+		cm.primary.trapClient = nil
+		cm.primary.trapClientmu.Unlock()
+
+		if _, err := cm.trapCall(cm.primary, payload); err != nil {
+			b.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+}
+
+func TestSubmitCallbacks(t *testing.T) {
+	t.Log("Testing OnSubmitSuccess and OnSubmitError")
+
+	server, _ := recordingBroker()
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	var mu sync.Mutex
+	var results []SubmitResult
+	var errs []error
+
+	cfg := &Config{}
+	cfg.Interval = "0"
+	cfg.CheckManager.Check.SubmissionURL = server.URL
+	cfg.SubmitPolicy.MaxAttempts = "1"
+	cfg.Destinations = []Destination{{Name: "failing", SubmissionURL: failing.URL}}
+	cfg.OnSubmitSuccess = func(result SubmitResult) {
+		mu.Lock()
+		results = append(results, result)
+		mu.Unlock()
+	}
+	cfg.OnSubmitError = func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	for !cm.Ready() || !cm.destinations[0].check.IsReady() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cm.Increment("foo")
+	cm.Increment("bar")
+	cm.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	r := results[0]
+	if r.Destination != "" || r.Metrics != 2 || r.Stats != 2 || r.Submissions != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %d", len(errs))
+	}
+	if !strings.HasPrefix(errs[0].Error(), "destination failing: ") {
+		t.Fatalf("Expected destination in error, got '%v'", errs[0])
+	}
+}
+
+func TestSubmitNotReady(t *testing.T) {
+	t.Log("Testing submission to a check which is not ready")
+
+	var errs []error
+
+	cm := &CirconusMetrics{onSubmitError: func(err error) { errs = append(errs, err) }}
+
+	ccfg := &checkmgr.Config{}
+	ccfg.Check.SubmissionURL = "http://127.0.0.1:1/write/test"
+	check, err := checkmgr.New(ccfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	d := newCheckDestination("", check)
+
+	t.Log("not initialized yet")
+	{
+		cm.submitTo(d, Metrics{"foo": {Type: "L", Value: uint64(1)}})
+		if len(errs) != 0 {
+			t.Fatalf("Expected no error, got %v", errs)
+		}
+	}
+
+	t.Log("not ready after initialization")
+	{
+		d.wasReady = true
+		cm.submitTo(d, Metrics{})
+		if len(errs) != 0 {
+			t.Fatalf("Expected no error for empty output, got %v", errs)
+		}
+		cm.submitTo(d, Metrics{"foo": {Type: "L", Value: uint64(1)}})
+		if len(errs) != 1 {
+			t.Fatalf("Expected 1 error, got %d", len(errs))
+		}
+	}
+}
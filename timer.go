@@ -0,0 +1,127 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A Timer records elapsed durations into a histogram.
+//
+// Durations are recorded in the units configured with Config.TimerUnits
+// (s, ms or us, default s). Durations of operations which returned an
+// error are recorded in a separate histogram named <name>`error.
+
+const (
+	defaultTimerUnits = "s"
+	timerErrorSuffix  = "`error"
+)
+
+// Span tracks the duration of an operation, started with StartSpan
+type Span struct {
+	m     *CirconusMetrics
+	name  string
+	start time.Time
+}
+
+type spanContextKey struct{}
+
+// Time starts a timer for the named histogram, call the returned
+// function to record the elapsed time (e.g. defer m.Time("foo")())
+func (m *CirconusMetrics) Time(metric string) func() {
+	start := time.Now()
+	return func() {
+		m.RecordDuration(metric, time.Since(start))
+	}
+}
+
+// TimeFunc calls fn and records its duration in the named histogram, if fn
+// returns an error the duration is recorded in the error histogram
+func (m *CirconusMetrics) TimeFunc(metric string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	if err != nil {
+		m.RecordDuration(metric+timerErrorSuffix, time.Since(start))
+	} else {
+		m.RecordDuration(metric, time.Since(start))
+	}
+	return err
+}
+
+// RecordDuration adds a duration, in the configured timer units, to a histogram
+func (m *CirconusMetrics) RecordDuration(metric string, d time.Duration) {
+	m.NewHistogram(metric).RecordValue(m.durationValue(d))
+}
+
+// StartSpan starts timing the named operation, the span is carried in
+// the returned context and can be retrieved with SpanFromContext
+func (m *CirconusMetrics) StartSpan(ctx context.Context, metric string) (context.Context, *Span) {
+	span := &Span{
+		m:     m,
+		name:  metric,
+		start: time.Now(),
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SpanFromContext returns the span carried in the context, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// Name returns the histogram name of a span
+func (s *Span) Name() string {
+	return s.name
+}
+
+// End records the elapsed time of a successful operation
+func (s *Span) End() {
+	s.EndWithError(nil)
+}
+
+// EndWithError records the elapsed time of an operation, if err is not nil
+// the elapsed time is recorded in the error histogram
+func (s *Span) EndWithError(err error) {
+	if s == nil {
+		return
+	}
+	metric := s.name
+	if err != nil {
+		metric += timerErrorSuffix
+	}
+	s.m.RecordDuration(metric, time.Since(s.start))
+}
+
+// durationValue converts a duration to a float64 in the configured timer units
+func (m *CirconusMetrics) durationValue(d time.Duration) float64 {
+	units := m.timerUnits
+	if units == 0 {
+		units = time.Second
+	}
+	return float64(d) / float64(units)
+}
+
+// parseTimerUnits converts a timer units setting to a duration
+func parseTimerUnits(units string) (time.Duration, error) {
+	switch units {
+	case "s":
+		return time.Second, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "us", "µs":
+		return time.Microsecond, nil
+	}
+	return 0, errors.Errorf("invalid timer units (%s), must be s, ms or us", units)
+}
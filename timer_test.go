@@ -0,0 +1,119 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	t.Log("Testing timer.Time")
+
+	cm := &CirconusMetrics{histograms: make(map[string]*Histogram)}
+
+	cm.Time("foo")()
+
+	hist, ok := cm.histograms["foo"]
+	if !ok {
+		t.Fatal("Expected to find foo")
+	}
+
+	if hist.hist == nil {
+		t.Fatal("Expected hist")
+	}
+}
+
+func TestTimeFunc(t *testing.T) {
+	t.Log("Testing timer.TimeFunc")
+
+	cm := &CirconusMetrics{histograms: make(map[string]*Histogram)}
+
+	if err := cm.TimeFunc("foo", func() error { return nil }); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := cm.histograms["foo"]; !ok {
+		t.Fatal("Expected to find foo")
+	}
+
+	expectedErr := errors.New("bar")
+	if err := cm.TimeFunc("foo", func() error { return expectedErr }); err != expectedErr {
+		t.Fatalf("Expected %v, got %v", expectedErr, err)
+	}
+	if _, ok := cm.histograms["foo`error"]; !ok {
+		t.Fatal("Expected to find foo`error")
+	}
+}
+
+func TestRecordDuration(t *testing.T) {
+	t.Log("Testing timer.RecordDuration")
+
+	tests := []struct {
+		units    string
+		expected string
+	}{
+		{"s", "H[1.5e+00]=1"},
+		{"ms", "H[1.5e+03]=1"},
+		{"us", "H[1.5e+06]=1"},
+	}
+
+	for _, test := range tests {
+		units, err := parseTimerUnits(test.units)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		cm := &CirconusMetrics{histograms: make(map[string]*Histogram), timerUnits: units}
+
+		cm.RecordDuration("foo", 1500*time.Millisecond)
+
+		val, err := cm.GetHistogramTest("foo")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if val[0] != test.expected {
+			t.Fatalf("Expected %s, got %s", test.expected, val[0])
+		}
+	}
+
+	if _, err := parseTimerUnits("ns"); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestSpan(t *testing.T) {
+	t.Log("Testing timer.StartSpan")
+
+	cm := &CirconusMetrics{histograms: make(map[string]*Histogram)}
+
+	ctx, span := cm.StartSpan(context.Background(), "foo")
+	if span.Name() != "foo" {
+		t.Fatalf("Expected foo, got %s", span.Name())
+	}
+
+	if SpanFromContext(ctx) != span {
+		t.Fatal("Expected span from context")
+	}
+
+	if SpanFromContext(context.Background()) != nil {
+		t.Fatal("Expected nil span")
+	}
+
+	span.End()
+	if _, ok := cm.histograms["foo"]; !ok {
+		t.Fatal("Expected to find foo")
+	}
+
+	_, span = cm.StartSpan(ctx, "bar")
+	span.EndWithError(errors.New("baz"))
+	if _, ok := cm.histograms["bar"]; ok {
+		t.Fatal("Expected not to find bar")
+	}
+	if _, ok := cm.histograms["bar`error"]; !ok {
+		t.Fatal("Expected to find bar`error")
+	}
+}
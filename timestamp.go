@@ -0,0 +1,192 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"time"
+
+	"github.com/circonus-labs/circonusllhist"
+)
+
+// Timestamped metrics are recorded at an explicit point in time rather
+// than "now" (e.g., batch jobs processing log files or replaying queues).
+//
+// Values are grouped by timestamp, truncated to Config.TimestampResolution
+// (default one second), and each group is sent as a separate submission
+// with the Circonus `_ts` field set on every metric, so late or historical
+// data lands in the correct time slot. Values recorded within the same
+// group are combined (counters are summed, the last gauge and text values
+// are kept and histograms are merged). Timestamped values are always reset
+// after they have been flushed.
+
+const defaultTimestampResolution = "1s"
+
+type timestampedMetrics struct {
+	counters   map[string]uint64
+	gauges     map[string]interface{}
+	histograms map[string]*circonusllhist.Histogram
+	text       map[string]string
+}
+
+// AddWithTimestamp updates counter, at timestamp ts, by supplied value
+func (m *CirconusMetrics) AddWithTimestamp(metric string, val uint64, ts time.Time) {
+	m.tsm.Lock()
+	defer m.tsm.Unlock()
+	m.timestampBucket(ts).counters[metric] += val
+}
+
+// SetGaugeWithTimestamp sets a gauge, at timestamp ts, to a value
+func (m *CirconusMetrics) SetGaugeWithTimestamp(metric string, val interface{}, ts time.Time) {
+	m.tsm.Lock()
+	defer m.tsm.Unlock()
+	m.timestampBucket(ts).gauges[metric] = val
+}
+
+// RecordValueWithTimestamp adds a value, at timestamp ts, to a histogram
+func (m *CirconusMetrics) RecordValueWithTimestamp(metric string, val float64, ts time.Time) {
+	m.RecordCountForValueWithTimestamp(metric, val, 1, ts)
+}
+
+// RecordCountForValueWithTimestamp adds count n for value, at timestamp ts, to a histogram
+func (m *CirconusMetrics) RecordCountForValueWithTimestamp(metric string, val float64, n int64, ts time.Time) {
+	m.tsm.Lock()
+	defer m.tsm.Unlock()
+
+	bucket := m.timestampBucket(ts)
+	hist, ok := bucket.histograms[metric]
+	if !ok {
+		hist = circonusllhist.New()
+		bucket.histograms[metric] = hist
+	}
+	hist.RecordValues(val, n)
+}
+
+// SetTextWithTimestamp sets a text metric, at timestamp ts, to a value
+func (m *CirconusMetrics) SetTextWithTimestamp(metric string, val string, ts time.Time) {
+	m.tsm.Lock()
+	defer m.tsm.Unlock()
+	m.timestampBucket(ts).text[metric] = val
+}
+
+// timestampBucket returns the metrics for a timestamp, truncated to the
+// timestamp resolution, caller must hold the lock
+func (m *CirconusMetrics) timestampBucket(ts time.Time) *timestampedMetrics {
+	key := timestampMillis(ts.Truncate(m.timestampResolution))
+
+	if m.timestamped == nil {
+		m.timestamped = make(map[uint64]*timestampedMetrics)
+	}
+
+	bucket, ok := m.timestamped[key]
+	if !ok {
+		bucket = &timestampedMetrics{
+			counters:   make(map[string]uint64),
+			gauges:     make(map[string]interface{}),
+			histograms: make(map[string]*circonusllhist.Histogram),
+			text:       make(map[string]string),
+		}
+		m.timestamped[key] = bucket
+	}
+
+	return bucket
+}
+
+// snapTimestamped returns the recorded timestamped metrics and resets them
+func (m *CirconusMetrics) snapTimestamped() map[uint64]*timestampedMetrics {
+	m.tsm.Lock()
+	defer m.tsm.Unlock()
+
+	t := m.timestamped
+	m.timestamped = make(map[uint64]*timestampedMetrics)
+
+	return t
+}
+
+// timestampedReady reports whether every destination check has been
+// initialized, a check which was ready and is not ready now (e.g. a trap
+// reset) reports the failed submission instead (see Config.OnSubmitError)
+func (m *CirconusMetrics) timestampedReady() bool {
+	for _, d := range append([]*destination{m.primary}, m.destinations...) {
+		if ready, wasReady := d.checkReady(); !ready && !wasReady {
+			return false
+		}
+	}
+
+	return true
+}
+
+// packageTimestampedMetrics returns the timestamped metrics grouped by
+// timestamp (milliseconds since epoch) with `_ts` set on each metric
+func (m *CirconusMetrics) packageTimestampedMetrics() map[uint64]Metrics {
+	m.packagingmu.Lock()
+	defer m.packagingmu.Unlock()
+
+	buckets := m.snapTimestamped()
+	groups := make(map[uint64]Metrics, len(buckets))
+
+	if len(buckets) == 0 {
+		return groups
+	}
+
+	m.getLogger().Debug("packaging timestamped metric groups", "groups", len(buckets))
+
+	for ts, bucket := range buckets {
+		output := make(Metrics, len(bucket.counters)+len(bucket.gauges)+len(bucket.histograms)+len(bucket.text))
+
+		for name, value := range bucket.counters {
+			output[name] = Metric{Type: "L", Value: value, Timestamp: ts}
+		}
+
+		for name, value := range bucket.gauges {
+			output[name] = Metric{Type: m.getGaugeType(value), Value: value, Timestamp: ts}
+		}
+
+		for name, value := range bucket.histograms {
+			metric, err := m.histogramMetric(value)
+			if err != nil {
+				m.getLogger().Warn("encoding histogram", "metric", name, "err", err)
+				continue
+			}
+			metric.Timestamp = ts
+			output[name] = metric
+		}
+
+		for name, value := range bucket.text {
+			output[name] = Metric{Type: "s", Value: value, Timestamp: ts}
+		}
+
+		if len(output) > 0 {
+			groups[ts] = output
+		}
+	}
+
+	return groups
+}
+
+// FlushTimestampedMetrics flushes current timestamped metrics to a structure
+// grouped by timestamp (milliseconds since epoch) and returns it (does NOT send to Circonus)
+func (m *CirconusMetrics) FlushTimestampedMetrics() map[uint64]Metrics {
+	m.flushmu.Lock()
+	if m.flushing {
+		m.flushmu.Unlock()
+		return map[uint64]Metrics{}
+	}
+
+	m.flushing = true
+	m.flushmu.Unlock()
+
+	groups := m.packageTimestampedMetrics()
+
+	m.flushmu.Lock()
+	m.flushing = false
+	m.flushmu.Unlock()
+
+	return groups
+}
+
+// timestampMillis converts a time to milliseconds since epoch
+func timestampMillis(ts time.Time) uint64 {
+	return uint64(ts.UnixNano() / int64(time.Millisecond))
+}
@@ -0,0 +1,264 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/circonus-labs/circonus-gometrics/checkmgr"
+)
+
+func TestRecordWithTimestamp(t *testing.T) {
+	t.Log("Testing timestamp recording")
+
+	cm := &CirconusMetrics{}
+
+	ts1 := time.Unix(1500000000, 0)
+	ts2 := ts1.Add(time.Minute)
+
+	cm.AddWithTimestamp("foo", 1, ts1)
+	cm.AddWithTimestamp("foo", 2, ts1)
+	cm.AddWithTimestamp("foo", 5, ts2)
+	cm.SetGaugeWithTimestamp("bar", int64(3), ts1)
+	cm.RecordValueWithTimestamp("baz", 1.5, ts2)
+	cm.SetTextWithTimestamp("qux", "quux", ts2)
+
+	if len(cm.timestamped) != 2 {
+		t.Fatalf("Expected 2 timestamps, got %d", len(cm.timestamped))
+	}
+
+	bucket := cm.timestamped[timestampMillis(ts1)]
+	if bucket == nil {
+		t.Fatal("Expected bucket for ts1")
+	}
+	if bucket.counters["foo"] != 3 {
+		t.Fatalf("Expected 3, got %d", bucket.counters["foo"])
+	}
+	if bucket.gauges["bar"].(int64) != 3 {
+		t.Fatalf("Expected 3, got %v", bucket.gauges["bar"])
+	}
+
+	bucket = cm.timestamped[timestampMillis(ts2)]
+	if bucket == nil {
+		t.Fatal("Expected bucket for ts2")
+	}
+	if bucket.counters["foo"] != 5 {
+		t.Fatalf("Expected 5, got %d", bucket.counters["foo"])
+	}
+	if _, ok := bucket.histograms["baz"]; !ok {
+		t.Fatal("Expected to find baz")
+	}
+	if bucket.text["qux"] != "quux" {
+		t.Fatalf("Expected quux, got %s", bucket.text["qux"])
+	}
+}
+
+func TestFlushTimestampedMetrics(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+	cfg.TimestampResolution = "1ms"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	ts := time.Unix(1500000000, 123456789)
+	cm.AddWithTimestamp("foo", 10, ts)
+	cm.RecordValueWithTimestamp("bar", 30.28, ts)
+
+	groups := cm.FlushTimestampedMetrics()
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %v", groups)
+	}
+
+	metrics, ok := groups[1500000000123]
+	if !ok {
+		t.Fatalf("Expected group for 1500000000123, got %v", groups)
+	}
+	if m, mok := metrics["foo"]; !mok {
+		t.Fatalf("'foo' not found in %v", metrics)
+	} else if m.Type != "L" || m.Value.(uint64) != 10 || m.Timestamp != 1500000000123 {
+		t.Fatalf("not correct %v", m)
+	}
+	if m, mok := metrics["bar"]; !mok {
+		t.Fatalf("'bar' not found in %v", metrics)
+	} else if m.Value.([]string)[0] != "H[3.0e+01]=1" || m.Timestamp != 1500000000123 {
+		t.Fatalf("not correct %v", m)
+	}
+
+	groups = cm.FlushTimestampedMetrics()
+	if len(groups) != 0 {
+		t.Fatalf("Expected reset, got %v", groups)
+	}
+
+	t.Log("flush in progress")
+	{
+		cm.AddWithTimestamp("foo", 10, ts)
+
+		cm.flushing = true
+		if groups := cm.FlushTimestampedMetrics(); len(groups) != 0 {
+			t.Fatalf("Expected no groups while flushing, got %v", groups)
+		}
+		cm.flushing = false
+
+		if groups := cm.FlushTimestampedMetrics(); len(groups) != 1 {
+			t.Fatalf("Expected 1 group, got %v", groups)
+		}
+	}
+}
+
+func TestTimestampResolution(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	t.Log("default, one second")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		for i := 0; i < 5; i++ {
+			ts := time.Unix(1500000000, int64(i)*int64(100*time.Millisecond))
+			cm.AddWithTimestamp("foo", 1, ts)
+			cm.RecordValueWithTimestamp("bar", float64(i), ts)
+		}
+		cm.AddWithTimestamp("foo", 1, time.Unix(1500000001, 0))
+
+		groups := cm.FlushTimestampedMetrics()
+		if len(groups) != 2 {
+			t.Fatalf("Expected 2 groups, got %v", groups)
+		}
+		metrics, ok := groups[1500000000000]
+		if !ok {
+			t.Fatalf("Expected group for 1500000000000, got %v", groups)
+		}
+		if m := metrics["foo"]; m.Value.(uint64) != 5 || m.Timestamp != 1500000000000 {
+			t.Fatalf("not correct %v", m)
+		}
+		if m := metrics["bar"]; len(m.Value.([]string)) != 5 {
+			t.Fatalf("not correct %v", m)
+		}
+	}
+
+	t.Log("one minute")
+	{
+		tcfg := *cfg
+		tcfg.TimestampResolution = "1m"
+		cm, err := NewCirconusMetrics(&tcfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.AddWithTimestamp("foo", 1, time.Unix(1500000000, 0))
+		cm.AddWithTimestamp("foo", 1, time.Unix(1500000019, 0))
+
+		groups := cm.FlushTimestampedMetrics()
+		if m := groups[1500000000000]["foo"]; len(groups) != 1 || m.Value.(uint64) != 2 {
+			t.Fatalf("Expected 1 group, got %v", groups)
+		}
+	}
+
+	t.Log("invalid")
+	{
+		for _, res := range []string{"1us", "-1s", "foo"} {
+			tcfg := *cfg
+			tcfg.TimestampResolution = res
+			if _, err := NewCirconusMetrics(&tcfg); err == nil {
+				t.Fatalf("Expected error for %s", res)
+			}
+			if err := tcfg.Validate(); err == nil {
+				t.Fatalf("Expected validation error for %s", res)
+			}
+		}
+	}
+}
+
+func TestFlushTimestampedSubmission(t *testing.T) {
+	var mu sync.Mutex
+	submissions := []map[string]map[string]interface{}{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		var v map[string]map[string]interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			panic(err)
+		}
+		mu.Lock()
+		submissions = append(submissions, v)
+		mu.Unlock()
+		w.WriteHeader(200)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"stats":1}`))
+	}))
+	defer ts.Close()
+
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = ts.URL + "/metrics_endpoint"
+	cfg.Interval = "0"
+
+	cm, err := NewCirconusMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	for !cm.Ready() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Log("held until the check is initialized")
+	{
+		check, err := checkmgr.New(&checkmgr.Config{Check: checkmgr.CheckConfig{SubmissionURL: ts.URL + "/metrics_endpoint"}})
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		primary := cm.primary
+		cm.primary = newCheckDestination("", check)
+
+		cm.AddWithTimestamp("foo", 1, time.Unix(1500000000, 0))
+		cm.Flush()
+
+		mu.Lock()
+		if len(submissions) != 0 {
+			t.Fatalf("Expected no submissions, got %d", len(submissions))
+		}
+		mu.Unlock()
+		if len(cm.timestamped) != 1 {
+			t.Fatalf("Expected 1 queued group, got %d", len(cm.timestamped))
+		}
+
+		cm.primary = primary
+	}
+
+	cm.AddWithTimestamp("foo", 1, time.Unix(1500000060, 0))
+	cm.AddWithTimestamp("foo", 1, time.Unix(1500000000, 0))
+
+	cm.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(submissions) != 2 {
+		t.Fatalf("Expected 2 submissions, got %d", len(submissions))
+	}
+	if submissions[0]["foo"]["_ts"].(float64) != 1500000000000 {
+		t.Fatalf("Expected oldest first, got %v", submissions)
+	}
+	if submissions[1]["foo"]["_ts"].(float64) != 1500000060000 {
+		t.Fatalf("Expected newest last, got %v", submissions)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A TopK metric tracks the most frequent items seen during a flush interval
+// (e.g., top 10 error messages).
+//
+// Items are counted with a space-saving sketch so memory use is bounded
+// regardless of the number of distinct items. The top K items are submitted
+// either by rank, as counters named <name>`<rank> with the item in a text
+// metric named <name>`<rank>`item, or, when Config.TopKOutput is "text", as
+// a single JSON text metric. Item values never become part of a metric name,
+// so the number of metrics is bounded by K. The sketch is reset after each
+// flush.
+
+const (
+	defaultTopK        = 10
+	topKCapacityFactor = 4 // counters tracked per reported item
+	defaultTopKOutput  = topKOutputCounters
+	topKOutputCounters = "counters"
+	topKOutputText     = "text"
+	topKItemSeparator  = "`"
+	topKItemSuffix     = "item" // text metric holding the item for a rank
+)
+
+// TopK tracks the most frequent items in a stream
+type TopK struct {
+	name     string
+	k        int
+	capacity int
+	counts   map[string]uint64
+	rw       sync.Mutex
+}
+
+// TopKItem is an item and its (approximate) count
+type TopKItem struct {
+	Item  string `json:"item"`
+	Count uint64 `json:"count"`
+}
+
+// AddTopK records an occurrence of item in a top-k metric
+func (m *CirconusMetrics) AddTopK(metric string, item string) {
+	m.NewTopK(metric, defaultTopK).AddCount(item, 1)
+}
+
+// AddTopKCount records n occurrences of item in a top-k metric
+func (m *CirconusMetrics) AddTopKCount(metric string, item string, n uint64) {
+	m.NewTopK(metric, defaultTopK).AddCount(item, n)
+}
+
+// RemoveTopK removes a top-k metric
+func (m *CirconusMetrics) RemoveTopK(metric string) {
+	m.tkm.Lock()
+	defer m.tkm.Unlock()
+	delete(m.topKs, metric)
+}
+
+// GetTopKTest returns the current top items for a top-k metric. (note: it is a function specifically for "testing", disable automatic submission during testing.)
+func (m *CirconusMetrics) GetTopKTest(metric string) ([]TopKItem, error) {
+	m.tkm.Lock()
+	defer m.tkm.Unlock()
+
+	if tk, ok := m.topKs[metric]; ok {
+		return tk.Top(), nil
+	}
+
+	return nil, fmt.Errorf("TopK metric '%s' not found", metric)
+}
+
+// NewTopK returns a top-k metric instance reporting the k most frequent
+// items. If the metric already exists, the existing instance is returned.
+func (m *CirconusMetrics) NewTopK(metric string, k int) *TopK {
+	m.tkm.Lock()
+	defer m.tkm.Unlock()
+
+	if tk, ok := m.topKs[metric]; ok {
+		return tk
+	}
+
+	if k <= 0 {
+		k = defaultTopK
+	}
+
+	tk := &TopK{
+		name:     metric,
+		k:        k,
+		capacity: k * topKCapacityFactor,
+		counts:   make(map[string]uint64),
+	}
+
+	m.topKs[metric] = tk
+
+	return tk
+}
+
+// Name returns the name from a top-k metric instance
+func (tk *TopK) Name() string {
+	return tk.name
+}
+
+// Add records an occurrence of item
+func (tk *TopK) Add(item string) {
+	tk.AddCount(item, 1)
+}
+
+// AddCount records n occurrences of item
+func (tk *TopK) AddCount(item string, n uint64) {
+	tk.rw.Lock()
+	defer tk.rw.Unlock()
+
+	if _, ok := tk.counts[item]; ok || len(tk.counts) < tk.capacity {
+		tk.counts[item] += n
+		return
+	}
+
+	// space-saving: replace the item with the smallest count, the new
+	// item inherits the count of the evicted item (over-estimation bound)
+	minItem := ""
+	minCount := uint64(0)
+	first := true
+	for i, c := range tk.counts {
+		if first || c < minCount {
+			minItem = i
+			minCount = c
+			first = false
+		}
+	}
+	delete(tk.counts, minItem)
+	tk.counts[item] = minCount + n
+}
+
+// Top returns the k most frequent items, ordered by count
+func (tk *TopK) Top() []TopKItem {
+	tk.rw.Lock()
+	defer tk.rw.Unlock()
+	return tk.top()
+}
+
+// top returns the ranked items, caller must hold the lock
+func (tk *TopK) top() []TopKItem {
+	items := make([]TopKItem, 0, len(tk.counts))
+	for i, c := range tk.counts {
+		items = append(items, TopKItem{Item: i, Count: c})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count == items[j].Count {
+			return items[i].Item < items[j].Item
+		}
+		return items[i].Count > items[j].Count
+	})
+
+	if len(items) > tk.k {
+		items = items[:tk.k]
+	}
+
+	return items
+}
+
+// snapshot returns the ranked items and resets the sketch
+func (tk *TopK) snapshot() []TopKItem {
+	tk.rw.Lock()
+	defer tk.rw.Unlock()
+
+	if len(tk.counts) == 0 {
+		return nil
+	}
+
+	items := tk.top()
+	tk.counts = make(map[string]uint64)
+
+	return items
+}
+
+// topKText renders ranked items as a JSON text metric value
+func topKText(items []TopKItem) (string, error) {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestAddTopK(t *testing.T) {
+	t.Log("Testing topk.AddTopK")
+
+	cm := &CirconusMetrics{topKs: make(map[string]*TopK)}
+
+	cm.AddTopK("foo", "a")
+	cm.AddTopKCount("foo", "b", 3)
+	cm.AddTopK("foo", "c")
+	cm.AddTopK("foo", "c")
+
+	items, err := cm.GetTopKTest("foo")
+	if err != nil {
+		t.Fatalf("Expected no error %v", err)
+	}
+
+	expected := []TopKItem{{"b", 3}, {"c", 2}, {"a", 1}}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, items)
+	}
+	for i, item := range expected {
+		if items[i] != item {
+			t.Fatalf("Expected %v, got %v", expected, items)
+		}
+	}
+
+	_, err = cm.GetTopKTest("bar")
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestTopKSpaceSaving(t *testing.T) {
+	t.Log("Testing topk space saving")
+
+	cm := &CirconusMetrics{topKs: make(map[string]*TopK)}
+	tk := cm.NewTopK("foo", 2)
+
+	// heavy hitters interleaved with a long tail of single occurrences
+	for i := 0; i < 1000; i++ {
+		tk.Add("heavy1")
+		if i%2 == 0 {
+			tk.Add("heavy2")
+		}
+		tk.Add(fmt.Sprintf("tail-%d", i))
+	}
+
+	items := tk.Top()
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %v", items)
+	}
+	if items[0].Item != "heavy1" || items[1].Item != "heavy2" {
+		t.Fatalf("Expected heavy1, heavy2 got %v", items)
+	}
+	if len(tk.counts) > 2*topKCapacityFactor {
+		t.Fatalf("Expected at most %d counters, got %d", 2*topKCapacityFactor, len(tk.counts))
+	}
+}
+
+func TestFlushTopK(t *testing.T) {
+	cfg := &Config{}
+	cfg.CheckManager.Check.SubmissionURL = "none"
+	cfg.Interval = "0"
+
+	t.Log("counters")
+	{
+		cm, err := NewCirconusMetrics(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.AddTopKCount("errors", "timeout", 5)
+		cm.AddTopKCount("errors", "refused", 2)
+		cm.AddUnique("users", "bob")
+
+		metrics := cm.FlushMetrics()
+		for rank, item := range []TopKItem{{"timeout", 5}, {"refused", 2}} {
+			name := "errors`" + strconv.Itoa(rank+1)
+			if m, mok := (*metrics)[name]; !mok {
+				t.Fatalf("'%s' not found in %v", name, metrics)
+			} else if m.Type != "L" || m.Value.(uint64) != item.Count {
+				t.Fatalf("not correct %v", m)
+			}
+			if m, mok := (*metrics)[name+"`item"]; !mok {
+				t.Fatalf("'%s`item' not found in %v", name, metrics)
+			} else if m.Type != "s" || m.Value.(string) != item.Item {
+				t.Fatalf("not correct %v", m)
+			}
+		}
+		if _, mok := (*metrics)["errors`timeout"]; mok {
+			t.Fatalf("item used as metric name %v", metrics)
+		}
+		if m, mok := (*metrics)["users"]; !mok {
+			t.Fatalf("'users' not found in %v", metrics)
+		} else if m.Type != "L" || m.Value.(uint64) != 1 {
+			t.Fatalf("not correct %v", m)
+		}
+
+		metrics = cm.FlushMetrics()
+		if len(*metrics) != 0 {
+			t.Fatalf("Expected reset, got %v", metrics)
+		}
+	}
+
+	t.Log("text")
+	{
+		tcfg := *cfg
+		tcfg.TopKOutput = "text"
+		cm, err := NewCirconusMetrics(&tcfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+
+		cm.AddTopKCount("errors", "timeout", 5)
+
+		metrics := cm.FlushMetrics()
+		expected := `[{"item":"timeout","count":5}]`
+		if m, mok := (*metrics)["errors"]; !mok {
+			t.Fatalf("'errors' not found in %v", metrics)
+		} else if m.Type != "s" || m.Value.(string) != expected {
+			t.Fatalf("not correct %v", m)
+		}
+	}
+
+	t.Log("invalid output")
+	{
+		tcfg := *cfg
+		tcfg.TopKOutput = "json"
+		if _, err := NewCirconusMetrics(&tcfg); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/circonus-labs/circonus-gometrics/internal/strhash"
+)
+
+// A Unique metric estimates the number of distinct values seen during a
+// flush interval (e.g., distinct users per interval).
+//
+// Values are tracked with a HyperLogLog sketch so memory use is constant
+// regardless of the number of distinct values. The estimate is submitted
+// as an unsigned 64-bit integer and the sketch is reset after each flush.
+
+const (
+	hllPrecision = 14 // 2^14 registers, ~0.8% standard error
+	hllRegisters = 1 << hllPrecision
+)
+
+// Unique estimates the cardinality of a set of values
+type Unique struct {
+	name      string
+	registers []uint8
+	rw        sync.Mutex
+}
+
+// AddUnique adds a value to a unique metric
+func (m *CirconusMetrics) AddUnique(metric string, val string) {
+	m.NewUnique(metric).Add(val)
+}
+
+// RemoveUnique removes a unique metric
+func (m *CirconusMetrics) RemoveUnique(metric string) {
+	m.um.Lock()
+	defer m.um.Unlock()
+	delete(m.uniques, metric)
+}
+
+// GetUniqueTest returns the current estimate for a unique metric. (note: it is a function specifically for "testing", disable automatic submission during testing.)
+func (m *CirconusMetrics) GetUniqueTest(metric string) (uint64, error) {
+	m.um.Lock()
+	defer m.um.Unlock()
+
+	if u, ok := m.uniques[metric]; ok {
+		return u.Estimate(), nil
+	}
+
+	return 0, fmt.Errorf("Unique metric '%s' not found", metric)
+}
+
+// NewUnique returns a unique metric instance.
+func (m *CirconusMetrics) NewUnique(metric string) *Unique {
+	m.um.Lock()
+	defer m.um.Unlock()
+
+	if u, ok := m.uniques[metric]; ok {
+		return u
+	}
+
+	u := &Unique{
+		name:      metric,
+		registers: make([]uint8, hllRegisters),
+	}
+
+	m.uniques[metric] = u
+
+	return u
+}
+
+// Name returns the name from a unique metric instance
+func (u *Unique) Name() string {
+	return u.name
+}
+
+// Add adds a value to a unique metric instance
+func (u *Unique) Add(val string) {
+	h := strhash.Sum64(val)
+	idx := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	u.rw.Lock()
+	if rank > u.registers[idx] {
+		u.registers[idx] = rank
+	}
+	u.rw.Unlock()
+}
+
+// Estimate returns the estimated number of distinct values
+func (u *Unique) Estimate() uint64 {
+	u.rw.Lock()
+	defer u.rw.Unlock()
+	return hllEstimate(u.registers)
+}
+
+// snapshot returns the current estimate and resets the sketch
+func (u *Unique) snapshot() (uint64, bool) {
+	u.rw.Lock()
+	defer u.rw.Unlock()
+
+	empty := true
+	for _, r := range u.registers {
+		if r != 0 {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return 0, false
+	}
+
+	est := hllEstimate(u.registers)
+	u.registers = make([]uint8, hllRegisters)
+
+	return est, true
+}
+
+// hllEstimate calculates the cardinality estimate from registers
+func hllEstimate(registers []uint8) uint64 {
+	m := float64(len(registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+
+	// small range correction, linear counting
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(est + 0.5)
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Circonus, Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package circonusgometrics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestAddUnique(t *testing.T) {
+	t.Log("Testing unique.AddUnique")
+
+	cm := &CirconusMetrics{uniques: make(map[string]*Unique)}
+
+	cm.AddUnique("foo", "bar")
+	cm.AddUnique("foo", "bar")
+	cm.AddUnique("foo", "baz")
+
+	val, err := cm.GetUniqueTest("foo")
+	if err != nil {
+		t.Fatalf("Expected no error %v", err)
+	}
+	if val != 2 {
+		t.Fatalf("Expected 2, found %d", val)
+	}
+
+	_, err = cm.GetUniqueTest("bar")
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestUniqueEstimate(t *testing.T) {
+	t.Log("Testing unique.Estimate")
+
+	for _, n := range []int{100, 10000, 250000} {
+		cm := &CirconusMetrics{uniques: make(map[string]*Unique)}
+		u := cm.NewUnique("foo")
+
+		for i := 0; i < n; i++ {
+			u.Add(fmt.Sprintf("user-%d", i))
+			u.Add(fmt.Sprintf("user-%d", i))
+		}
+
+		est := u.Estimate()
+		errPct := math.Abs(float64(est)-float64(n)) / float64(n)
+		if errPct > 0.03 {
+			t.Fatalf("Expected estimate within 3%% of %d, got %d", n, est)
+		}
+	}
+}
+
+func TestRemoveUnique(t *testing.T) {
+	t.Log("Testing unique.RemoveUnique")
+
+	cm := &CirconusMetrics{uniques: make(map[string]*Unique)}
+
+	cm.AddUnique("foo", "bar")
+	cm.RemoveUnique("foo")
+
+	if _, ok := cm.uniques["foo"]; ok {
+		t.Fatal("Expected not to find foo")
+	}
+}
+
+func TestSnapUniques(t *testing.T) {
+	t.Log("Testing util.snapUniques")
+
+	cm := &CirconusMetrics{uniques: make(map[string]*Unique)}
+
+	cm.AddUnique("foo", "bar")
+
+	u := cm.snapUniques()
+	if v, ok := u["foo"]; !ok {
+		t.Fatal("Expected to find foo")
+	} else if v != 1 {
+		t.Fatalf("Expected 1, got %d", v)
+	}
+
+	u = cm.snapUniques()
+	if len(u) != 0 {
+		t.Fatalf("Expected reset, got %v", u)
+	}
+}
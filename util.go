@@ -16,6 +16,12 @@ func (m *CirconusMetrics) Reset() {
 	m.cfm.Lock()
 	defer m.cfm.Unlock()
 
+	m.udm.Lock()
+	defer m.udm.Unlock()
+
+	m.fcm.Lock()
+	defer m.fcm.Unlock()
+
 	m.gm.Lock()
 	defer m.gm.Unlock()
 
@@ -31,13 +37,30 @@ func (m *CirconusMetrics) Reset() {
 	m.tfm.Lock()
 	defer m.tfm.Unlock()
 
+	m.um.Lock()
+	defer m.um.Unlock()
+
+	m.tkm.Lock()
+	defer m.tkm.Unlock()
+
+	m.tsm.Lock()
+	defer m.tsm.Unlock()
+
 	m.counters = make(map[string]uint64)
 	m.counterFuncs = make(map[string]func() uint64)
+	m.upDownCounters = make(map[string]int64)
+	m.floatCounters = make(map[string]float64)
 	m.gauges = make(map[string]interface{})
+	for n, agg := range m.gaugeAggs {
+		m.gaugeAggs[n] = &gaugeAggregate{mode: agg.mode}
+	}
 	m.gaugeFuncs = make(map[string]func() int64)
 	m.histograms = make(map[string]*Histogram)
 	m.text = make(map[string]string)
 	m.textFuncs = make(map[string]func() string)
+	m.uniques = make(map[string]*Unique)
+	m.topKs = make(map[string]*TopK)
+	m.timestamped = make(map[uint64]*timestampedMetrics)
 }
 
 // snapshot returns a copy of the values of all registered counters and gauges.
@@ -72,6 +95,38 @@ func (m *CirconusMetrics) snapCounters() map[string]uint64 {
 	return c
 }
 
+func (m *CirconusMetrics) snapUpDownCounters() map[string]int64 {
+	m.udm.Lock()
+	defer m.udm.Unlock()
+
+	c := make(map[string]int64, len(m.upDownCounters))
+
+	for n, v := range m.upDownCounters {
+		c[n] = v
+	}
+	if m.resetUpDownCounters && len(c) > 0 {
+		m.upDownCounters = make(map[string]int64)
+	}
+
+	return c
+}
+
+func (m *CirconusMetrics) snapFloatCounters() map[string]float64 {
+	m.fcm.Lock()
+	defer m.fcm.Unlock()
+
+	c := make(map[string]float64, len(m.floatCounters))
+
+	for n, v := range m.floatCounters {
+		c[n] = v
+	}
+	if m.resetCounters && len(c) > 0 {
+		m.floatCounters = make(map[string]float64)
+	}
+
+	return c
+}
+
 func (m *CirconusMetrics) snapGauges() map[string]interface{} {
 	m.gm.Lock()
 	defer m.gm.Unlock()
@@ -81,6 +136,10 @@ func (m *CirconusMetrics) snapGauges() map[string]interface{} {
 	g := make(map[string]interface{}, len(m.gauges)+len(m.gaugeFuncs))
 
 	for n, v := range m.gauges {
+		if agg, ok := m.gaugeAggs[n]; ok {
+			agg.snapshot(n, v, g)
+			continue
+		}
 		g[n] = v
 	}
 	if m.resetGauges && len(g) > 0 {
@@ -112,6 +171,36 @@ func (m *CirconusMetrics) snapHistograms() map[string]*circonusllhist.Histogram
 	return h
 }
 
+func (m *CirconusMetrics) snapUniques() map[string]uint64 {
+	m.um.Lock()
+	defer m.um.Unlock()
+
+	u := make(map[string]uint64, len(m.uniques))
+
+	for n, unique := range m.uniques {
+		if est, ok := unique.snapshot(); ok {
+			u[n] = est
+		}
+	}
+
+	return u
+}
+
+func (m *CirconusMetrics) snapTopKs() map[string][]TopKItem {
+	m.tkm.Lock()
+	defer m.tkm.Unlock()
+
+	t := make(map[string][]TopKItem, len(m.topKs))
+
+	for n, tk := range m.topKs {
+		if items := tk.snapshot(); len(items) > 0 {
+			t[n] = items
+		}
+	}
+
+	return t
+}
+
 func (m *CirconusMetrics) snapText() map[string]string {
 	m.tm.Lock()
 	defer m.tm.Unlock()
@@ -17,6 +17,12 @@ func TestReset(t *testing.T) {
 	cm.counterFuncs = make(map[string]func() uint64)
 	cm.Increment("foo")
 
+	cm.upDownCounters = make(map[string]int64)
+	cm.AddUpDown("foo", -1)
+
+	cm.floatCounters = make(map[string]float64)
+	cm.AddFloat("foo", 1.5)
+
 	// cm.gauges = make(map[string]string)
 	cm.gauges = make(map[string]interface{})
 	cm.gaugeFuncs = make(map[string]func() int64)
@@ -51,6 +57,14 @@ func TestReset(t *testing.T) {
 		t.Errorf("Expected 0, found %d", len(cm.counters))
 	}
 
+	if len(cm.upDownCounters) != 0 {
+		t.Errorf("Expected 0, found %d", len(cm.upDownCounters))
+	}
+
+	if len(cm.floatCounters) != 0 {
+		t.Errorf("Expected 0, found %d", len(cm.floatCounters))
+	}
+
 	if len(cm.gauges) != 0 {
 		t.Errorf("Expected 0, found %d", len(cm.gauges))
 	}